@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	controller "github.com/getground/tech-tasks/backend/pkg/controller"
+	db "github.com/getground/tech-tasks/backend/pkg/db"
+	migrate "github.com/getground/tech-tasks/backend/pkg/migrate"
+	models "github.com/getground/tech-tasks/backend/pkg/models"
+)
+
+const importUsage = "usage: app import [-dry-run] [-event id] <plan.csv | plan.json>"
+
+// runImport imports the seating plan in the file named by the arguments and
+// prints the report of every row
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "check the plan without importing it")
+	event := flags.Int64("event", models.DefaultEvent, "id of the event to import into")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf(importUsage)
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf(importUsage)
+	}
+	if db.Driver() == memoryDriver {
+		return fmt.Errorf("DBDRIVER %s keeps no data to import into", memoryDriver)
+	}
+
+	file := flags.Arg(0)
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var plan controller.SeatingPlan
+	if strings.ToLower(filepath.Ext(file)) == ".csv" {
+		plan, err = controller.ParseSeatingPlanCSV(bytes.NewReader(data))
+	} else {
+		plan, err = controller.ParseSeatingPlanJSON(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	sqlDB, err := db.ConnectToDB()
+	if err != nil {
+		return err
+	}
+	defer db.CloseConnection(sqlDB)
+	migrator, err := migrate.New(sqlDB, db.Driver())
+	if err != nil {
+		return err
+	}
+	if _, err = migrator.Up(); err != nil {
+		return err
+	}
+	party := models.PartyModel{DB: sqlDB, Dialect: db.Driver()}
+	exists, err := party.DbCheckEventExists(*event)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return fmt.Errorf("event %d not found", *event)
+	}
+	app, err := newApp()
+	if err != nil {
+		return err
+	}
+	app.Party = party.DbForEvent(*event)
+
+	// the report lists every row also when the import fails
+	report, err, _ := controller.ImportSeatingPlan(app, plan, *dryRun)
+	out, jsonErr := json.MarshalIndent(report, "", "  ")
+	if jsonErr != nil {
+		return jsonErr
+	}
+	fmt.Println(string(out))
+	return err
+}
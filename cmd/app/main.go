@@ -4,38 +4,153 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 
 	controller "github.com/getground/tech-tasks/backend/pkg/controller"
 	db "github.com/getground/tech-tasks/backend/pkg/db"
+	memstore "github.com/getground/tech-tasks/backend/pkg/memstore"
+	migrate "github.com/getground/tech-tasks/backend/pkg/migrate"
 	models "github.com/getground/tech-tasks/backend/pkg/models"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
 )
 
+// DBDRIVER value that keeps all data in memory, no database is needed
+const memoryDriver = "memory"
+
 func main() {
-	// init mysql.
-	sqlDB, err := db.ConnectToDB()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	app, err := newApp()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.CloseConnection(sqlDB)
-	app := &controller.App{
-		Party: models.PartyModel{DB: sqlDB},
+	if db.Driver() == memoryDriver {
+		app.Party = memstore.New()
+	} else {
+		// init mysql, postgres or sqlite as selected by DBDRIVER.
+		sqlDB, err := db.ConnectToDB()
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.CloseConnection(sqlDB)
+		migrator, err := migrate.New(sqlDB, db.Driver())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err = migrator.Up(); err != nil {
+			log.Fatal(err)
+		}
+		party := models.PartyModel{DB: sqlDB, Dialect: db.Driver()}
+		// guests added before the search index existed
+		if _, err = party.DbIndexGuests(); err != nil {
+			log.Fatal(err)
+		}
+		app.Party = party
 	}
 	router := mux.NewRouter()
-	router.HandleFunc("/tables", app.AddTableHandler).Methods("POST")
-	router.HandleFunc("/guest_list/{name}", app.AddGuestListHandler).Methods("POST")
-	router.HandleFunc("/guest_list", app.GetGuestListHandler).Methods("GET")
-	router.HandleFunc("/guests", app.GetGuestsHandler).Methods("GET")
-	router.HandleFunc("/guests/{name}", app.UpdateGuestHandler).Methods("PUT")
-	router.HandleFunc("/guests/{name}", app.DeleteGuestHandler).Methods("DELETE")
-	router.HandleFunc("/seats_empty", app.GetEmptySeatsHandler).Methods("GET")
+	router.Use(controller.RequestIdMiddleware)
+	router.NotFoundHandler = http.HandlerFunc(controller.NotFoundHandler)
+	router.MethodNotAllowedHandler = http.HandlerFunc(controller.MethodNotAllowedHandler)
+	router.HandleFunc("/events", app.AddEventHandler).Methods("POST")
+	router.HandleFunc("/events", app.GetEventsHandler).Methods("GET")
+	router.HandleFunc("/events/{event_id}", app.GetEventHandler).Methods("GET")
+	// routes without an event prefix serve the default event
+	addPartyRoutes(router, app.DefaultEvent)
+	addPartyRoutes(router.PathPrefix("/events/{event_id}").Subrouter(), app.Event)
 	router.HandleFunc("/ping", handlerPing).Methods("GET")
-	if err = http.ListenAndServe(":3000", router); err != nil {
+	if err := http.ListenAndServe(":3000", router); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// addPartyRoutes registers the table and guest routes on router, event
+// adapts the handlers to the event they serve
+func addPartyRoutes(router *mux.Router, event func(func(*controller.App, http.ResponseWriter, *http.Request)) http.HandlerFunc) {
+	router.HandleFunc("/tables", event((*controller.App).AddTableHandler)).Methods("POST")
+	router.HandleFunc("/tables", event((*controller.App).GetTablesHandler)).Methods("GET")
+	router.HandleFunc("/tables/{id}", event((*controller.App).GetTableHandler)).Methods("GET")
+	router.HandleFunc("/tables/{id}", event((*controller.App).UpdateTableHandler)).Methods("PATCH")
+	router.HandleFunc("/tables/{id}", event((*controller.App).DeleteTableHandler)).Methods("DELETE")
+	router.HandleFunc("/guest_list", event((*controller.App).AddGuestListHandler)).Methods("POST")
+	router.HandleFunc("/guest_list/by-id/{id}", event((*controller.App).EditGuestListHandler)).Methods("PATCH")
+	router.HandleFunc("/guest_list/by-id/{id}", event((*controller.App).RemoveGuestListHandler)).Methods("DELETE")
+	router.HandleFunc("/guest_list/{name}", event((*controller.App).AddGuestListHandler)).Methods("POST")
+	router.HandleFunc("/guest_list/{name}", event((*controller.App).EditGuestListHandler)).Methods("PATCH")
+	router.HandleFunc("/guest_list/{name}", event((*controller.App).RemoveGuestListHandler)).Methods("DELETE")
+	router.HandleFunc("/guest_list", event((*controller.App).GetGuestListHandler)).Methods("GET")
+	router.HandleFunc("/guests", event((*controller.App).GetGuestsHandler)).Methods("GET")
+	router.HandleFunc("/guests/search", event((*controller.App).SearchGuestsHandler)).Methods("GET")
+	router.HandleFunc("/guests", event((*controller.App).WalkInHandler)).Methods("POST")
+	router.HandleFunc("/guests/by-id/{id}", event((*controller.App).GetGuestHandler)).Methods("GET")
+	router.HandleFunc("/guests/by-id/{id}", event((*controller.App).UpdateGuestHandler)).Methods("PUT")
+	router.HandleFunc("/guests/by-id/{id}", event((*controller.App).DeleteGuestHandler)).Methods("DELETE")
+	router.HandleFunc("/guests/{name}", event((*controller.App).UpdateGuestHandler)).Methods("PUT")
+	router.HandleFunc("/guests/{name}", event((*controller.App).DeleteGuestHandler)).Methods("DELETE")
+	router.HandleFunc("/seats_empty", event((*controller.App).GetEmptySeatsHandler)).Methods("GET")
+	router.HandleFunc("/table_moves", event((*controller.App).GetTableMovesHandler)).Methods("GET")
+	router.HandleFunc("/dwell_report", event((*controller.App).GetDwellReportHandler)).Methods("GET")
+	router.HandleFunc("/import", event((*controller.App).ImportHandler)).Methods("POST")
+	router.HandleFunc("/seating_plan", event((*controller.App).PlanSeatingHandler)).Methods("POST")
+	router.HandleFunc("/seating_plan/commit", event((*controller.App).CommitSeatingPlanHandler)).Methods("POST")
+	router.HandleFunc("/constraints", event((*controller.App).AddConstraintHandler)).Methods("POST")
+	router.HandleFunc("/constraints", event((*controller.App).GetConstraintsHandler)).Methods("GET")
+	router.HandleFunc("/constraints/{id}", event((*controller.App).DeleteConstraintHandler)).Methods("DELETE")
+	router.HandleFunc("/waitlist", event((*controller.App).AddToWaitlistHandler)).Methods("POST")
+	router.HandleFunc("/waitlist", event((*controller.App).GetWaitlistHandler)).Methods("GET")
+	router.HandleFunc("/waitlist/{id}", event((*controller.App).RemoveFromWaitlistHandler)).Methods("DELETE")
+}
+
+// newApp returns the app configured by the environment, the server and the
+// import command set its Party
+func newApp() (*controller.App, error) {
+	// SHAREDTABLES=true lets several guest parties share a table
+	app := &controller.App{SharedTables: os.Getenv("SHAREDTABLES") == "true"}
+	walkIn, err := walkInPolicy()
+	if err != nil {
+		return nil, err
+	}
+	app.WalkIn = walkIn
+	// WAITLISTORDER=priority serves the waiting parties by priority
+	app.WaitlistOrder = os.Getenv("WAITLISTORDER")
+	switch app.WaitlistOrder {
+	case "", controller.WaitlistFIFO, controller.WaitlistPriority:
+	default:
+		return nil, fmt.Errorf("WAITLISTORDER must be %s or %s", controller.WaitlistFIFO, controller.WaitlistPriority)
+	}
+	return app, nil
+}
+
+// walkInPolicy reads the walk-in policy from WALKINPOLICY, smallest_fit or
+// largest_free, and the seats kept in reserve from WALKINRESERVE
+func walkInPolicy() (controller.WalkInPolicy, error) {
+	policy := controller.WalkInPolicy{Policy: os.Getenv("WALKINPOLICY")}
+	switch policy.Policy {
+	case "", controller.SmallestFit, controller.LargestFree:
+	default:
+		return policy, fmt.Errorf("WALKINPOLICY must be %s or %s", controller.SmallestFit, controller.LargestFree)
+	}
+	if reserve := os.Getenv("WALKINRESERVE"); reserve != "" {
+		n, err := strconv.ParseInt(reserve, 10, 64)
+		if err != nil || n < 0 {
+			return policy, fmt.Errorf("WALKINRESERVE must be a number of seats")
+		}
+		policy.Reserve = n
+	}
+	return policy, nil
+}
+
 func handlerPing(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "pong\n")
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	db "github.com/getground/tech-tasks/backend/pkg/db"
+	migrate "github.com/getground/tech-tasks/backend/pkg/migrate"
+)
+
+const migrateUsage = "usage: app migrate up | down [steps] | status"
+
+// runMigrate runs the migrate subcommand with the given arguments
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+	if db.Driver() == memoryDriver {
+		return fmt.Errorf("DBDRIVER %s has no schema to migrate", memoryDriver)
+	}
+	sqlDB, err := db.ConnectToDB()
+	if err != nil {
+		return err
+	}
+	defer db.CloseConnection(sqlDB)
+	migrator, err := migrate.New(sqlDB, db.Driver())
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := migrator.Up()
+		for _, m := range done {
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %s", args[1])
+			}
+		}
+		done, err := migrator.Down(steps)
+		for _, m := range done {
+			fmt.Printf("rolled back %d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		if err := migrator.Verify(); err != nil {
+			return err
+		}
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if !s.AppliedAt.IsZero() {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	}
+	return fmt.Errorf(migrateUsage)
+}
@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	models "github.com/getground/tech-tasks/backend/pkg/models"
+)
+
+func constraintFromModel(c models.Constraint) Constraint {
+	constraint := Constraint{ID: c.Id, Kind: c.Kind, Table: c.Table, Tag: c.Tag}
+	if c.Name != "" {
+		constraint.Guests = append(constraint.Guests, c.Name)
+	}
+	if c.Other != "" {
+		constraint.Guests = append(constraint.Guests, c.Other)
+	}
+	return constraint
+}
+
+// add a seating constraint, the table of a table tag has to exist. Guests
+// can only be kept together on shared tables.
+func AddConstraint(app *App, constraint Constraint) (Constraint, error, int) {
+	if constraint.Kind == models.TOGETHER && !app.SharedTables {
+		return constraint, validationError([]FieldError{{Field: "kind", Message: "together needs shared tables, a table seats a single party"}}), http.StatusBadRequest
+	}
+	respCode := http.StatusOK
+	err := app.Party.DbTransaction(func(tx models.Party) error {
+		if err := tx.DbLockEvent(); err != nil {
+			return err
+		}
+		var err error
+		constraint, err, respCode = addConstraint(tx, constraint)
+		return err
+	})
+	if err != nil && respCode == http.StatusOK {
+		respCode = http.StatusInternalServerError
+	}
+	return constraint, err, respCode
+}
+
+func addConstraint(party models.Party, constraint Constraint) (Constraint, error, int) {
+	stored := models.Constraint{Kind: constraint.Kind, Table: constraint.Table, Tag: strings.ToLower(strings.TrimSpace(constraint.Tag))}
+	if len(constraint.Guests) > 0 {
+		stored.Name = models.DisplayName(constraint.Guests[0])
+	}
+	if len(constraint.Guests) > 1 {
+		stored.Other = models.DisplayName(constraint.Guests[1])
+	}
+	if stored.Table != 0 {
+		exists, err := party.DbLockTable(stored.Table)
+		if err != nil {
+			return constraint, err, http.StatusInternalServerError
+		}
+		if exists == 0 {
+			return constraint, newError(ErrUnknownTable, "Invalid table-id"), http.StatusBadRequest
+		}
+	}
+	id, err := party.DbAddConstraint(stored)
+	if err != nil {
+		return constraint, err, http.StatusInternalServerError
+	}
+	stored.Id = id
+	return constraintFromModel(stored), nil, http.StatusOK
+}
+
+func GetConstraints(app *App) ([]Constraint, error, int) {
+	constraints := []Constraint{}
+	stored, err := app.Party.DbGetConstraints()
+	if err != nil {
+		return constraints, err, http.StatusInternalServerError
+	}
+	for _, c := range stored {
+		constraints = append(constraints, constraintFromModel(c))
+	}
+	return constraints, nil, http.StatusOK
+}
+
+func DeleteConstraint(app *App, id int64) (error, int) {
+	deleted, err := app.Party.DbDeleteConstraint(id)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if deleted == 0 {
+		return newError(ErrConstraintNotFound, "Constraint %d not found", id), http.StatusNotFound
+	}
+	return nil, http.StatusOK
+}
+
+// tags of every table of constraints
+func tableTags(constraints []models.Constraint) map[int64]map[string]bool {
+	tags := map[int64]map[string]bool{}
+	for _, c := range constraints {
+		if c.Kind != models.TABLETAG {
+			continue
+		}
+		if tags[c.Table] == nil {
+			tags[c.Table] = map[string]bool{}
+		}
+		tags[c.Table][c.Tag] = true
+	}
+	return tags
+}
+
+// tables of the guests named name that are expected or present, other than
+// guest guestId
+func seatedGuests(party models.Party, name string, guestId int64) ([]models.Guests, error) {
+	var seated []models.Guests
+	guests, err := party.DbFindGuests(name)
+	if err != nil {
+		return seated, err
+	}
+	for _, guest := range guests {
+		if guest.Id == guestId {
+			continue
+		}
+		if guest.Status == models.ALLOTTED || guest.Status == models.CHECKEDIN {
+			seated = append(seated, guest)
+		}
+	}
+	return seated, nil
+}
+
+// constraints guest breaks when seated at its table, with a message for
+// every constraint broken. Guests of a together constraint that are not
+// seated yet break nothing.
+func constraintViolations(party models.Party, constraints []models.Constraint, guest models.Guests) ([]string, []int64, error) {
+	var messages []string
+	var ids []int64
+	key := models.NameKey(guest.Name)
+	tags := tableTags(constraints)
+	for _, c := range constraints {
+		other := c.Other
+		if models.NameKey(c.Other) == key {
+			other = c.Name
+		} else if models.NameKey(c.Name) != key {
+			continue
+		}
+		var message string
+		switch c.Kind {
+		case models.REQUIRES:
+			if !tags[guest.Table][c.Tag] {
+				message = fmt.Sprintf("%s needs a table tagged %s", guest.Name, c.Tag)
+			}
+		case models.TOGETHER, models.APART:
+			seated, err := seatedGuests(party, other, guest.Id)
+			if err != nil {
+				return messages, ids, err
+			}
+			for _, g := range seated {
+				if c.Kind == models.TOGETHER && g.Table != guest.Table {
+					message = fmt.Sprintf("%s must sit with %s at table %d", guest.Name, g.Name, g.Table)
+				}
+				if c.Kind == models.APART && g.Table == guest.Table {
+					message = fmt.Sprintf("%s must not share a table with %s", guest.Name, g.Name)
+				}
+			}
+		}
+		if message != "" {
+			messages = append(messages, message)
+			ids = append(ids, c.Id)
+		}
+	}
+	return messages, ids, nil
+}
+
+// check that the guest of guestList breaks no constraint at its table.
+// Without shared tables guests kept together cannot share a table, their
+// constraints are only reported as warnings of the guest list.
+func checkConstraints(party models.Party, guestList GuestList, sharedTables bool) (error, int) {
+	stored, err := party.DbGetConstraints()
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	var constraints []models.Constraint
+	for _, c := range stored {
+		if c.Kind != models.TOGETHER || sharedTables {
+			constraints = append(constraints, c)
+		}
+	}
+	guest := models.Guests{Id: guestList.ID, Name: guestList.Name, Table: guestList.Table}
+	messages, ids, err := constraintViolations(party, constraints, guest)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if messages != nil {
+		return &Error{
+			Code:    ErrConstraintViolated,
+			Message: "Cannot allot table. " + strings.Join(messages, ", "),
+			Extra:   map[string]interface{}{"constraint_ids": ids},
+		}, http.StatusConflict
+	}
+	return nil, http.StatusOK
+}
@@ -1,213 +1,1340 @@
 package controller
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	models "github.com/getground/tech-tasks/backend/pkg/models"
 	_ "github.com/go-sql-driver/mysql"
+	"math"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 )
 
 type App struct {
-	Party interface {
-		DbAddTable(int64) (int64, error)
-		DbCheckTableExists(int64) (int64, error)
-		DbAddGuestList(models.Guests) error
-		DbUpdateGuestStatus(string, string) error
-		DbUpdateGuestList(models.Guests) error
-		DbGetGuestInTable(int64) (string, error)
-		DbGetGuestStatus(string) (string, error)
-		DbGetCapacitySum() (int64, error)
-		DbGetAccompanyingGuestsSum(string) (int64, int64, error)
-		DbGetGuestList() ([]models.Guests, error)
-		DbGetArrivedGuests() ([]models.Guests, error)
-		DbGetTableCapacity(int64) (int64, error)
-		DbGetTableIdOfGuest(string) (int64, error)
-		DbCheckGuestExists(string) (int64, error)
-		DbIsTablesEmpty() (bool, error)
-		DbIsGuestsEmpty(string) (bool, error)
+	Party models.Party
+	// SharedTables lets several guest parties share a table as long as
+	// their seats fit its capacity
+	SharedTables bool
+	// Now returns the current time of the reports, time.Now when nil
+	Now func() time.Time
+	// WalkIn chooses the tables of walk-in guests
+	WalkIn WalkInPolicy
+	// WaitlistOrder is the order the waiting parties get tables in,
+	// WaitlistFIFO when empty
+	WaitlistOrder string
+}
+
+func (app *App) now() time.Time {
+	if app.Now == nil {
+		return time.Now()
 	}
+	return app.Now()
 }
 
+// add a table and seat the waiting parties that fit on it
 func AddTable(app *App, table Table) (Table, error, int) {
-	id, err := app.Party.DbAddTable(table.Capacity)
-	if err != nil {
-		return table, err, http.StatusInternalServerError
+	respCode := http.StatusOK
+	err := app.Party.DbTransaction(func(tx models.Party) error {
+		if err := tx.DbLockEvent(); err != nil {
+			return err
+		}
+		id, err := tx.DbAddTable(table.Capacity)
+		if err != nil {
+			return err
+		}
+		table.ID = id
+		err, respCode = processWaitlist(tx, app.SharedTables, app.WaitlistOrder)
+		return err
+	})
+	if err != nil && respCode == http.StatusOK {
+		respCode = http.StatusInternalServerError
 	}
-	table.ID = id
-	return table, nil, http.StatusOK
+	return table, err, respCode
+}
+
+func tableOccupancy(table models.Table, guests []models.Guests) TableOccupancy {
+	occupancy := TableOccupancy{
+		ID:       table.Id,
+		Capacity: table.Capacity,
+		Guests:   []TableGuest{},
+	}
+	for _, guest := range guests {
+		if guest.Table != table.Id {
+			continue
+		}
+		occupancy.Guests = append(occupancy.Guests, TableGuest{
+			ID:                 guest.Id,
+			Name:               guest.Name,
+			AccompanyingGuests: guest.AccompanyingGuests,
+			Status:             guest.Status,
+		})
+		switch guest.Status {
+		case models.CHECKEDIN:
+			occupancy.Occupied += guest.AccompanyingGuests + 1
+			occupancy.Allotted += guest.AccompanyingGuests + 1
+		case models.ALLOTTED:
+			occupancy.Allotted += guest.AccompanyingGuests + 1
+		}
+	}
+	return occupancy
 }
 
-func GetGuestList(app *App) ([]GuestList, error, int) {
-	var guestList []GuestList
+func GetTables(app *App) ([]TableOccupancy, error, int) {
+	tableList := []TableOccupancy{}
+	tables, err := app.Party.DbGetTables()
+	if err != nil {
+		return tableList, err, http.StatusInternalServerError
+	}
 	guests, err := app.Party.DbGetGuestList()
 	if err != nil {
-		return guestList, err, http.StatusInternalServerError
+		return tableList, err, http.StatusInternalServerError
+	}
+	for _, table := range tables {
+		tableList = append(tableList, tableOccupancy(table, guests))
+	}
+	return tableList, nil, http.StatusOK
+}
+
+func GetTable(app *App, id int64) (TableOccupancy, error, int) {
+	return getTable(app.Party, id)
+}
+
+func getTable(party models.Party, id int64) (TableOccupancy, error, int) {
+	var occupancy TableOccupancy
+	exists, err := party.DbCheckTableExists(id)
+	if err != nil {
+		return occupancy, err, http.StatusInternalServerError
+	}
+	if exists == 0 {
+		return occupancy, newError(ErrTableNotFound, "Table %d not found", id), http.StatusNotFound
+	}
+	capacity, err := party.DbGetTableCapacity(id)
+	if err != nil {
+		return occupancy, err, http.StatusInternalServerError
+	}
+	guests, err := party.DbGetGuestsInTable(id)
+	if err != nil {
+		return occupancy, err, http.StatusInternalServerError
+	}
+	return tableOccupancy(models.Table{Id: id, Capacity: capacity}, guests), nil, http.StatusOK
+}
+
+// change the capacity of a table, the table must still seat every party
+// allotted to it. Waiting parties get the seats a larger table frees.
+func UpdateTable(app *App, table Table) (TableOccupancy, error, int) {
+	var occupancy TableOccupancy
+	respCode := http.StatusOK
+	err := app.Party.DbTransaction(func(tx models.Party) error {
+		if err := tx.DbLockEvent(); err != nil {
+			return err
+		}
+		var err error
+		occupancy, err, respCode = updateTable(tx, table)
+		if err == nil {
+			err, respCode = processWaitlist(tx, app.SharedTables, app.WaitlistOrder)
+		}
+		return err
+	})
+	if err != nil && respCode == http.StatusOK {
+		respCode = http.StatusInternalServerError
+	}
+	return occupancy, err, respCode
+}
+
+func updateTable(party models.Party, table Table) (TableOccupancy, error, int) {
+	var occupancy TableOccupancy
+	exists, err := party.DbLockTable(table.ID)
+	if err != nil {
+		return occupancy, err, http.StatusInternalServerError
+	}
+	if exists == 0 {
+		return occupancy, newError(ErrTableNotFound, "Table %d not found", table.ID), http.StatusNotFound
+	}
+	occupancy, err, respCode := getTable(party, table.ID)
+	if err != nil {
+		return occupancy, err, respCode
+	}
+	if table.Capacity < occupancy.Allotted {
+		return occupancy, newError(ErrTableInUse, "Cannot resize table. %d seats are allotted", occupancy.Allotted), http.StatusConflict
+	}
+	if err = party.DbUpdateTableCapacity(table.ID, table.Capacity); err != nil {
+		return occupancy, err, http.StatusInternalServerError
+	}
+	occupancy.Capacity = table.Capacity
+	return occupancy, nil, http.StatusOK
+}
+
+// delete a table no guest was ever allotted to
+func DeleteTable(app *App, id int64) (error, int) {
+	respCode := http.StatusOK
+	err := app.Party.DbTransaction(func(tx models.Party) error {
+		if err := tx.DbLockEvent(); err != nil {
+			return err
+		}
+		var err error
+		err, respCode = deleteTable(tx, id)
+		return err
+	})
+	if err != nil && respCode == http.StatusOK {
+		respCode = http.StatusInternalServerError
+	}
+	return err, respCode
+}
+
+func deleteTable(party models.Party, id int64) (error, int) {
+	exists, err := party.DbLockTable(id)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if exists == 0 {
+		return newError(ErrTableNotFound, "Table %d not found", id), http.StatusNotFound
+	}
+	gname, err := party.DbGetGuestInTable(id)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if gname != "" {
+		return newError(ErrTableInUse, "Cannot delete table. Table is allotted to %s", gname), http.StatusConflict
+	}
+	if err = party.DbDeleteTable(id); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
+// page of the guest list selected by query, Next is set when more guests
+// follow the page. Expected and present guests are warned of the
+// constraints their seating breaks.
+func GetGuestList(app *App, query models.GuestQuery) (GuestListPage, error, int) {
+	var page GuestListPage
+	guests, next, err := queryGuests(app.Party, query)
+	if err != nil {
+		return page, err, http.StatusInternalServerError
+	}
+	page.Next = next
+	companions, err := companionsOf(app.Party, guests)
+	if err != nil {
+		return page, err, http.StatusInternalServerError
+	}
+	constraints, err := app.Party.DbGetConstraints()
+	if err != nil {
+		return page, err, http.StatusInternalServerError
 	}
 	for _, guest := range guests {
 		var gl GuestList
+		gl.ID = guest.Id
 		gl.Name = guest.Name
 		gl.AccompanyingGuests = guest.AccompanyingGuests
 		gl.Table = guest.Table
-		guestList = append(guestList, gl)
+		gl.Status = guest.Status
+		gl.Companions = companions[guest.Id]
+		if guest.Status == models.ALLOTTED || guest.Status == models.CHECKEDIN {
+			gl.Warnings, _, err = constraintViolations(app.Party, constraints, guest)
+			if err != nil {
+				return page, err, http.StatusInternalServerError
+			}
+		}
+		page.Guests = append(page.Guests, gl)
+	}
+	return page, nil, http.StatusOK
+}
+
+// guests of query and the cursor of the next page, one guest more than the
+// limit is read to know whether there is a next page
+func queryGuests(party models.Party, query models.GuestQuery) ([]models.Guests, string, error) {
+	limit := query.Limit
+	if limit > 0 {
+		query.Limit++
+	}
+	guests, err := party.DbQueryGuests(query)
+	if err != nil || limit == 0 || len(guests) <= limit {
+		return guests, "", err
 	}
-	return guestList, err, http.StatusOK
+	guests = guests[:limit]
+	return guests, encodeCursor(query, guests[limit-1]), nil
 }
 
-func GetGuests(app *App) ([]ArrivedGuests, error, int) {
-	var arrGuests []ArrivedGuests
-	guests, err := app.Party.DbGetArrivedGuests()
+// lowest score of a fuzzy match returned by a guest search
+const minSearchScore = 0.4
+
+// guests matching the search key query best, the limit candidates with the
+// highest score and by name and id when the scores are equal
+func SearchGuests(app *App, query string, limit int) ([]SearchResult, error, int) {
+	results := []SearchResult{}
+	guests, err := app.Party.DbSearchGuests(query, models.SearchCandidates)
+	if err != nil {
+		return results, err, http.StatusInternalServerError
+	}
+	for _, guest := range guests {
+		score := models.SearchScore(query, models.SearchKey(guest.Name))
+		if score < minSearchScore {
+			continue
+		}
+		results = append(results, SearchResult{
+			ID:                 guest.Id,
+			Name:               guest.Name,
+			Table:              guest.Table,
+			AccompanyingGuests: guest.AccompanyingGuests,
+			Status:             guest.Status,
+			Score:              math.Round(score*100) / 100,
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Name != results[j].Name {
+			return results[i].Name < results[j].Name
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil, http.StatusOK
+}
+
+// page of the arrived guests selected by query with their presence and all
+// their visits
+func GetGuests(app *App, query models.GuestQuery) (ArrivedGuestsPage, error, int) {
+	var page ArrivedGuestsPage
+	if len(query.Statuses) == 0 {
+		query.Statuses = []string{models.CHECKEDIN, models.CHECKEDOUT}
+	}
+	guests, next, err := queryGuests(app.Party, query)
+	if err != nil {
+		return page, err, http.StatusInternalServerError
+	}
+	page.Next = next
+	visits, err := visitsOf(app.Party, guests)
 	if err != nil {
-		return arrGuests, err, http.StatusInternalServerError
+		return page, err, http.StatusInternalServerError
+	}
+	companions, err := companionsOf(app.Party, guests)
+	if err != nil {
+		return page, err, http.StatusInternalServerError
 	}
 	for _, guest := range guests {
 		var ag ArrivedGuests
+		ag.ID = guest.Id
 		ag.Name = guest.Name
+		ag.Companions = companions[guest.Id]
 		ag.AccompanyingGuests = guest.AccompanyingGuests
 		ag.TimeArrived = guest.TimeArrived
-		arrGuests = append(arrGuests, ag)
+		if !guest.TimeLeft.IsZero() {
+			timeLeft := guest.TimeLeft
+			ag.TimeLeft = &timeLeft
+		}
+		ag.Present = guest.Status == models.CHECKEDIN
+		ag.Visits = []Visit{}
+		for _, v := range visits[guest.Id] {
+			visit := Visit{TimeArrived: v.TimeArrived, AccompanyingGuests: v.AccompanyingGuests}
+			if !v.TimeLeft.IsZero() {
+				timeLeft := v.TimeLeft
+				visit.TimeLeft = &timeLeft
+			}
+			ag.Visits = append(ag.Visits, visit)
+		}
+		page.Guests = append(page.Guests, ag)
 	}
 
-	return arrGuests, nil, http.StatusOK
+	return page, nil, http.StatusOK
 }
 
-func AddGuestList(app *App, guestList GuestList) (GuestName, error, int) {
-	var guestName GuestName
-
-	exists, err := app.Party.DbCheckTableExists(guestList.Table)
+// visits of guests by the id of the guest
+func visitsOf(party models.Party, guests []models.Guests) (map[int64][]models.Visit, error) {
+	visits := map[int64][]models.Visit{}
+	if len(guests) == 0 {
+		return visits, nil
+	}
+	var ids []int64
+	for _, guest := range guests {
+		ids = append(ids, guest.Id)
+	}
+	stored, err := party.DbGetVisits(ids...)
 	if err != nil {
-		return guestName, err, http.StatusInternalServerError
+		return visits, err
 	}
-	if exists == 0 {
-		return guestName, fmt.Errorf("Invalid table-id"), http.StatusBadRequest
+	for _, v := range stored {
+		visits[v.GuestId] = append(visits[v.GuestId], v)
 	}
+	return visits, nil
+}
 
-	exists, err = app.Party.DbCheckGuestExists(guestList.Name)
+// report of how long guests stay. Average and median are taken over the
+// completed visits, the longest stayers are the limit guests with the most
+// time at the party, counting the current visit of present guests until now.
+func GetDwellReport(app *App, limit int64) (DwellReport, error, int) {
+	report := DwellReport{LongestStayers: []GuestStay{}, Present: []PresentGuest{}}
+	visits, err := app.Party.DbGetVisits()
 	if err != nil {
-		return guestName, err, http.StatusInternalServerError
+		return report, err, http.StatusInternalServerError
 	}
-	if exists > 0 {
-		return guestName, fmt.Errorf("Guest %s already added", guestList.Name), http.StatusBadRequest
+	now := app.now()
+	var stays []time.Duration
+	var total time.Duration
+	guestStays := map[int64]time.Duration{}
+	names := map[int64]string{}
+	var ids []int64
+	for _, v := range visits {
+		if _, ok := guestStays[v.GuestId]; !ok {
+			ids = append(ids, v.GuestId)
+			names[v.GuestId] = v.Name
+		}
+		if v.TimeLeft.IsZero() {
+			stay := now.Sub(v.TimeArrived)
+			guestStays[v.GuestId] += stay
+			report.Present = append(report.Present, PresentGuest{
+				ID:          v.GuestId,
+				Name:        v.Name,
+				TimeArrived: v.TimeArrived,
+				StaySeconds: int64(stay / time.Second),
+			})
+			continue
+		}
+		stay := v.TimeLeft.Sub(v.TimeArrived)
+		guestStays[v.GuestId] += stay
+		stays = append(stays, stay)
+		total += stay
 	}
 
-	capacity, err := app.Party.DbGetTableCapacity(guestList.Table)
-	if err != nil {
-		return guestName, err, http.StatusInternalServerError
+	if len(stays) > 0 {
+		report.Visits = int64(len(stays))
+		report.AverageStay = int64(total / time.Duration(len(stays)) / time.Second)
+		sort.Slice(stays, func(i, j int) bool { return stays[i] < stays[j] })
+		median := stays[len(stays)/2]
+		if len(stays)%2 == 0 {
+			median = (stays[len(stays)/2-1] + median) / 2
+		}
+		report.MedianStay = int64(median / time.Second)
 	}
 
-	if guestList.AccompanyingGuests+1 > capacity {
-		return guestName, fmt.Errorf("Cannot allot table. Table capacity is %d", capacity), http.StatusBadRequest
+	// longest first, guests with the same stay by name and id
+	sort.SliceStable(ids, func(i, j int) bool {
+		if guestStays[ids[i]] != guestStays[ids[j]] {
+			return guestStays[ids[i]] > guestStays[ids[j]]
+		}
+		if names[ids[i]] != names[ids[j]] {
+			return names[ids[i]] < names[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	for _, id := range ids {
+		if int64(len(report.LongestStayers)) == limit {
+			break
+		}
+		report.LongestStayers = append(report.LongestStayers, GuestStay{
+			ID:          id,
+			Name:        names[id],
+			StaySeconds: int64(guestStays[id] / time.Second),
+		})
+	}
+	return report, nil, http.StatusOK
+}
+
+// allot the table to guest within a single transaction, the table row stays
+// locked until the guest is added so concurrent allotments are serialized.
+// With uniqueName the guest is only added when no other guest has the name.
+func AddGuestList(app *App, guestList GuestList, uniqueName bool) (GuestName, error, int) {
+	var guestName GuestName
+	respCode := http.StatusOK
+	err := app.Party.DbTransaction(func(tx models.Party) error {
+		if err := tx.DbLockEvent(); err != nil {
+			return err
+		}
+		var err error
+		guestName, err, respCode = addGuestList(tx, guestList, uniqueName, app.SharedTables)
+		return err
+	})
+	if err != nil && respCode == http.StatusOK {
+		respCode = http.StatusInternalServerError
 	}
+	return guestName, err, respCode
+}
+
+func addGuestList(party models.Party, guestList GuestList, uniqueName bool, sharedTables bool) (GuestName, error, int) {
+	var guestName GuestName
 
-	gname, err := app.Party.DbGetGuestInTable(guestList.Table)
+	exists, err := party.DbLockTable(guestList.Table)
 	if err != nil {
 		return guestName, err, http.StatusInternalServerError
 	}
+	if exists == 0 {
+		return guestName, newError(ErrUnknownTable, "Invalid table-id"), http.StatusBadRequest
+	}
 
-	if gname != "" {
-		return guestName, fmt.Errorf("Table already allotted to %s", gname), http.StatusBadRequest
+	if uniqueName {
+		exists, err = party.DbCheckGuestExists(guestList.Name)
+		if err != nil {
+			return guestName, err, http.StatusInternalServerError
+		}
+		if exists > 0 {
+			return guestName, newError(ErrGuestExists, "Guest %s already added", guestList.Name), http.StatusConflict
+		}
+	}
+
+	// the named companions are counted unless more accompanying guests are
+	companions := companionsToModel(guestList.Companions)
+	if guestList.AccompanyingGuests < int64(len(companions)) {
+		guestList.AccompanyingGuests = int64(len(companions))
+	}
+
+	if err, respCode := checkTableAvailable(party, guestList, sharedTables); err != nil {
+		return guestName, err, respCode
+	}
+	if err, respCode := checkConstraints(party, guestList, sharedTables); err != nil {
+		return guestName, err, respCode
 	}
 
 	var guests models.Guests
 	guests.Table = guestList.Table
 	guests.Name = guestList.Name
 	guests.AccompanyingGuests = guestList.AccompanyingGuests
-	if err = app.Party.DbAddGuestList(guests); err != nil {
+	id, err := party.DbAddGuestList(guests)
+	if err != nil {
 		return guestName, err, http.StatusInternalServerError
 	}
+	if len(companions) > 0 {
+		if err = party.DbSetCompanions(id, companions); err != nil {
+			return guestName, err, http.StatusInternalServerError
+		}
+	}
+	guestName.ID = id
 	guestName.Name = guestList.Name
 	return guestName, nil, http.StatusOK
 }
 
+// check that the locked table of guestList can seat the party and is not
+// allotted to another expected or present guest than guestList.ID, which is
+// 0 for a guest not added yet. Shared tables only need enough free seats for
+// the party.
+func checkTableAvailable(party models.Party, guestList GuestList, sharedTables bool) (error, int) {
+	capacity, err := party.DbGetTableCapacity(guestList.Table)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+
+	if guestList.AccompanyingGuests+1 > capacity {
+		return newError(ErrTableCapacity, "Cannot allot table. Table capacity is %d", capacity), http.StatusConflict
+	}
+
+	if sharedTables {
+		seats, err := seatsOfOtherParties(party, guestList.Table, guestList.ID)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+		if seats+guestList.AccompanyingGuests+1 > capacity {
+			return newError(ErrTableCapacity, "Cannot allot table. %d of %d seats are free", capacity-seats, capacity), http.StatusConflict
+		}
+		return nil, http.StatusOK
+	}
+
+	guests, err := party.DbGetGuestsInTable(guestList.Table)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+
+	// parties that left or will not come free the table
+	for _, guest := range guests {
+		if guest.Id == guestList.ID {
+			continue
+		}
+		if guest.Status == models.ALLOTTED || guest.Status == models.CHECKEDIN {
+			return newError(ErrTableAllotted, "Table already allotted to %s", guest.Name), http.StatusConflict
+		}
+	}
+	return nil, http.StatusOK
+}
+
+// seats allotted on table id to the parties other than guest guestId which
+// are expected or present
+func seatsOfOtherParties(party models.Party, id int64, guestId int64) (int64, error) {
+	var seats int64
+	guests, err := party.DbGetGuestsInTable(id)
+	if err != nil {
+		return seats, err
+	}
+	for _, guest := range guests {
+		if guest.Id == guestId {
+			continue
+		}
+		if guest.Status == models.ALLOTTED || guest.Status == models.CHECKEDIN {
+			seats += guest.AccompanyingGuests + 1
+		}
+	}
+	return seats, nil
+}
+
+// companions as they are stored, with display names and trimmed notes
+func companionsToModel(companions []Companion) []models.Companion {
+	var stored []models.Companion
+	for _, c := range companions {
+		stored = append(stored, models.Companion{
+			Name:          models.DisplayName(c.Name),
+			Dietary:       strings.TrimSpace(c.Dietary),
+			Accessibility: strings.TrimSpace(c.Accessibility),
+		})
+	}
+	return stored
+}
+
+func companionFromModel(c models.Companion) Companion {
+	return Companion{Name: c.Name, Dietary: c.Dietary, Accessibility: c.Accessibility, Arrived: c.Arrived}
+}
+
+// companions of guests by the id of the guest
+func companionsOf(party models.Party, guests []models.Guests) (map[int64][]Companion, error) {
+	companions := map[int64][]Companion{}
+	if len(guests) == 0 {
+		return companions, nil
+	}
+	var ids []int64
+	for _, guest := range guests {
+		ids = append(ids, guest.Id)
+	}
+	stored, err := party.DbGetCompanions(ids...)
+	if err != nil {
+		return companions, err
+	}
+	for _, c := range stored {
+		companions[c.GuestId] = append(companions[c.GuestId], companionFromModel(c))
+	}
+	return companions, nil
+}
+
+// mark the companions named in arrived as arrived and the others as not,
+// names of no companion of the guest are refused
+func markArrived(companions []models.Companion, arrived []string) []FieldError {
+	index := map[string]int{}
+	for i := range companions {
+		companions[i].Arrived = false
+		index[models.NameKey(companions[i].Name)] = i
+	}
+	var details []FieldError
+	for i, name := range arrived {
+		j, ok := index[models.NameKey(name)]
+		if !ok {
+			field := fmt.Sprintf("companions_arrived[%d]", i)
+			details = append(details, FieldError{Field: field, Message: field + " is not a companion of the guest"})
+			continue
+		}
+		companions[j].Arrived = true
+	}
+	return details
+}
+
+// find the guest ref refers to. A name shared by several guests does not
+// refer to any of them, the guests have to be referred to by id.
+func findGuest(party models.Party, ref GuestRef) (models.Guests, error, int) {
+	if ref.ID != 0 {
+		guest, err := party.DbGetGuest(ref.ID)
+		if err == sql.ErrNoRows {
+			return guest, newError(ErrGuestNotFound, "Guest %d is not present in Guestlist", ref.ID), http.StatusNotFound
+		}
+		if err != nil {
+			return guest, err, http.StatusInternalServerError
+		}
+		return guest, nil, http.StatusOK
+	}
+	guests, err := party.DbFindGuests(ref.Name)
+	if err != nil {
+		return models.Guests{}, err, http.StatusInternalServerError
+	}
+	if len(guests) == 0 {
+		return models.Guests{}, newError(ErrGuestNotFound, "Guest %s is not present in Guestlist", ref.Name), http.StatusNotFound
+	}
+	if len(guests) > 1 {
+		var ids []int64
+		for _, guest := range guests {
+			ids = append(ids, guest.Id)
+		}
+		return models.Guests{}, &Error{
+			Code:    ErrGuestAmbiguous,
+			Message: fmt.Sprintf("Request failed, %d guests are named %s, refer to the guest by id", len(guests), ref.Name),
+			Extra:   map[string]interface{}{"guest_ids": ids},
+		}, http.StatusConflict
+	}
+	return guests[0], nil, http.StatusOK
+}
+
+// get a guest on the guest list by id
+func GetGuest(app *App, ref GuestRef) (GuestList, error, int) {
+	var guestList GuestList
+	guest, err, respCode := findGuest(app.Party, ref)
+	if err != nil {
+		return guestList, err, respCode
+	}
+	guestList.ID = guest.Id
+	guestList.Name = guest.Name
+	guestList.Table = guest.Table
+	guestList.AccompanyingGuests = guest.AccompanyingGuests
+	guestList.Status = guest.Status
+	companions, err := companionsOf(app.Party, []models.Guests{guest})
+	if err != nil {
+		return guestList, err, http.StatusInternalServerError
+	}
+	guestList.Companions = companions[guest.Id]
+	return guestList, nil, http.StatusOK
+}
+
+// get a guest that has not arrived yet, the guest list entry of arrived
+// guests cannot be changed
+func getAllottedGuest(party models.Party, ref GuestRef) (models.Guests, error, int) {
+	guest, err, respCode := findGuest(party, ref)
+	if err != nil {
+		return guest, err, respCode
+	}
+	if guest.Status != models.ALLOTTED {
+		return guest, newError(ErrGuestArrived, "Request failed, guest already %s", guest.Status), http.StatusConflict
+	}
+	return guest, nil, http.StatusOK
+}
+
+// change the table and/or the accompanying guests of a guest on the guest
+// list, with the same checks as when the table was allotted, or mark the
+// guest as no-show or cancelled. Waiting parties get the seats freed.
+func EditGuestList(app *App, ref GuestRef, update GuestListUpdate) (GuestList, error, int) {
+	var guestList GuestList
+	respCode := http.StatusOK
+	err := app.Party.DbTransaction(func(tx models.Party) error {
+		if err := tx.DbLockEvent(); err != nil {
+			return err
+		}
+		var err error
+		guestList, err, respCode = editGuestList(tx, ref, update, app.SharedTables)
+		if err == nil {
+			err, respCode = processWaitlist(tx, app.SharedTables, app.WaitlistOrder)
+		}
+		return err
+	})
+	if err != nil && respCode == http.StatusOK {
+		respCode = http.StatusInternalServerError
+	}
+	return guestList, err, respCode
+}
+
+func editGuestList(party models.Party, ref GuestRef, update GuestListUpdate, sharedTables bool) (GuestList, error, int) {
+	var guestList GuestList
+	if update.Status != nil {
+		return changeGuestStatus(party, ref, *update.Status)
+	}
+	guest, err, respCode := getAllottedGuest(party, ref)
+	if err != nil {
+		return guestList, err, respCode
+	}
+
+	companions, err := party.DbGetCompanions(guest.Id)
+	if err != nil {
+		return guestList, err, http.StatusInternalServerError
+	}
+
+	guestList.ID = guest.Id
+	guestList.Name = guest.Name
+	guestList.Table = guest.Table
+	guestList.AccompanyingGuests = guest.AccompanyingGuests
+	if update.Table != nil {
+		guestList.Table = *update.Table
+	}
+	if update.Companions != nil {
+		companions = companionsToModel(*update.Companions)
+	}
+	if update.AccompanyingGuests != nil {
+		guestList.AccompanyingGuests = *update.AccompanyingGuests
+		if details := checkCompanionCount(guestList.AccompanyingGuests, len(companions)); details != nil {
+			return guestList, validationError(details), http.StatusBadRequest
+		}
+	} else if guestList.AccompanyingGuests < int64(len(companions)) {
+		guestList.AccompanyingGuests = int64(len(companions))
+	}
+	for _, c := range companions {
+		guestList.Companions = append(guestList.Companions, companionFromModel(c))
+	}
+
+	// lock in id order so that concurrent moves cannot deadlock
+	lockIds := []int64{guest.Table, guestList.Table}
+	if lockIds[0] > lockIds[1] {
+		lockIds[0], lockIds[1] = lockIds[1], lockIds[0]
+	}
+	for _, id := range lockIds {
+		exists, err := party.DbLockTable(id)
+		if err != nil {
+			return guestList, err, http.StatusInternalServerError
+		}
+		if exists == 0 {
+			return guestList, newError(ErrUnknownTable, "Invalid table-id"), http.StatusBadRequest
+		}
+	}
+
+	if err, respCode := checkTableAvailable(party, guestList, sharedTables); err != nil {
+		return guestList, err, respCode
+	}
+	if err, respCode := checkConstraints(party, guestList, sharedTables); err != nil {
+		return guestList, err, respCode
+	}
+
+	guest.Table = guestList.Table
+	guest.AccompanyingGuests = guestList.AccompanyingGuests
+	if err = party.DbUpdateGuestAllotment(guest); err != nil {
+		return guestList, err, http.StatusInternalServerError
+	}
+	if update.Companions != nil {
+		if err = party.DbSetCompanions(guest.Id, companions); err != nil {
+			return guestList, err, http.StatusInternalServerError
+		}
+	}
+	return guestList, nil, http.StatusOK
+}
+
+// move a guest to status with the table of the guest locked, the state
+// machine decides whether the guest can take the status
+func changeGuestStatus(party models.Party, ref GuestRef, status string) (GuestList, error, int) {
+	var guestList GuestList
+	guest, err, respCode := findGuest(party, ref)
+	if err != nil {
+		return guestList, err, respCode
+	}
+	if _, err = party.DbLockTable(guest.Table); err != nil {
+		return guestList, err, http.StatusInternalServerError
+	}
+	current, err := party.DbGetGuestStatus(guest.Id)
+	if err != nil {
+		return guestList, err, http.StatusInternalServerError
+	}
+	if err := models.GuestStatus.Check(current, status); err != nil {
+		return guestList, statusError(err.(*models.TransitionError)), http.StatusConflict
+	}
+	if err = party.DbUpdateGuestStatus(guest.Id, status); err != nil {
+		err, respCode := transitionError(err)
+		return guestList, err, respCode
+	}
+	guestList.ID = guest.Id
+	guestList.Name = guest.Name
+	guestList.Table = guest.Table
+	guestList.AccompanyingGuests = guest.AccompanyingGuests
+	guestList.Status = status
+	return guestList, nil, http.StatusOK
+}
+
+// remove a guest that has not arrived yet, is a no-show or cancelled from
+// the guest list, waiting parties get the seats freed
+func RemoveGuestList(app *App, ref GuestRef) (error, int) {
+	respCode := http.StatusOK
+	err := app.Party.DbTransaction(func(tx models.Party) error {
+		if err := tx.DbLockEvent(); err != nil {
+			return err
+		}
+		var err error
+		err, respCode = removeGuestList(tx, ref)
+		if err == nil {
+			err, respCode = processWaitlist(tx, app.SharedTables, app.WaitlistOrder)
+		}
+		return err
+	})
+	if err != nil && respCode == http.StatusOK {
+		respCode = http.StatusInternalServerError
+	}
+	return err, respCode
+}
+
+func removeGuestList(party models.Party, ref GuestRef) (error, int) {
+	guest, err, respCode := findGuest(party, ref)
+	if err != nil {
+		return err, respCode
+	}
+	// guests who will not come can be removed to free their table
+	switch guest.Status {
+	case models.ALLOTTED, models.NOSHOW, models.CANCELLED:
+	default:
+		return newError(ErrGuestArrived, "Request failed, guest already %s", guest.Status), http.StatusConflict
+	}
+	if _, err = party.DbLockTable(guest.Table); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if err = party.DbDeleteGuest(guest.Id); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	return nil, http.StatusOK
+}
+
 // update status of guest in db to checked-in
 // update accompanying guests if capacity is there for table
-// update arrived time in db
-func UpdateGuestList(app *App, guestList GuestList) (GuestName, error, int) {
+// or move the party to another table when checkIn asks to
+// mark the companions who arrived with the guest
+// update arrived time in db and record the visit
+// all of the above runs in a single transaction with the table row locked.
+// Guests who checked-out can check-in again, every stay is a new visit.
+func UpdateGuestList(app *App, ref GuestRef, checkIn CheckIn) (GuestName, error, int) {
 	var guestName GuestName
-	exists, err := app.Party.DbCheckGuestExists(guestList.Name)
-	if exists == 0 {
-		return guestName, fmt.Errorf("Guest %s is not present in Guestlist", guestList.Name), http.StatusBadRequest
+	respCode := http.StatusOK
+	err := app.Party.DbTransaction(func(tx models.Party) error {
+		if err := tx.DbLockEvent(); err != nil {
+			return err
+		}
+		var err error
+		guestName, err, respCode = updateGuestList(tx, ref, checkIn, app.SharedTables)
+		if err == nil {
+			err, respCode = processWaitlist(tx, app.SharedTables, app.WaitlistOrder)
+		}
+		return err
+	})
+	if err != nil && respCode == http.StatusOK {
+		respCode = http.StatusInternalServerError
 	}
+	return guestName, err, respCode
+}
 
-	id, err := app.Party.DbGetTableIdOfGuest(guestList.Name)
+func updateGuestList(party models.Party, ref GuestRef, checkIn CheckIn, sharedTables bool) (GuestName, error, int) {
+	var guestName GuestName
+	stored, err, respCode := findGuest(party, ref)
 	if err != nil {
+		return guestName, err, respCode
+	}
+	id := stored.Table
+
+	if _, err = party.DbLockTable(id); err != nil {
 		return guestName, err, http.StatusInternalServerError
 	}
 
-	capacity, err := app.Party.DbGetTableCapacity(id)
+	status, err := party.DbGetGuestStatus(stored.Id)
 	if err != nil {
 		return guestName, err, http.StatusInternalServerError
 	}
+	if err := models.GuestStatus.Check(status, models.CHECKEDIN); err != nil {
+		return guestName, statusError(err.(*models.TransitionError)), http.StatusConflict
+	}
 
-	if guestList.AccompanyingGuests+1 > capacity {
-		return guestName, fmt.Errorf("Cannot update number of accompanying guests. Table capacity is %d", capacity), http.StatusBadRequest
+	companions, err := party.DbGetCompanions(stored.Id)
+	if err != nil {
+		return guestName, err, http.StatusInternalServerError
+	}
+	if details := markArrived(companions, checkIn.CompanionsArrived); details != nil {
+		return guestName, validationError(details), http.StatusBadRequest
+	}
+	if checkIn.AccompanyingGuests < int64(len(checkIn.CompanionsArrived)) {
+		checkIn.AccompanyingGuests = int64(len(checkIn.CompanionsArrived))
+	}
+
+	if err, respCode := checkCheckInSeats(party, id, stored.Id, checkIn.AccompanyingGuests); err != nil {
+		if checkIn.Reassign == "" || !errors.Is(err, ErrTableCapacity) {
+			return guestName, err, respCode
+		}
+		stored.AccompanyingGuests = checkIn.AccompanyingGuests
+		moved, err, respCode := reassignTable(party, stored, checkIn.Reassign, sharedTables, err)
+		if err != nil {
+			return guestName, err, respCode
+		}
+		guestName.Table = moved
+		guestName.MovedFrom = id
 	}
 
 	var guest models.Guests
-	guest.Name = guestList.Name
-	guest.AccompanyingGuests = guestList.AccompanyingGuests
+	guest.Id = stored.Id
+	guest.AccompanyingGuests = checkIn.AccompanyingGuests
 	guest.Status = models.CHECKEDIN
-	if err = app.Party.DbUpdateGuestList(guest); err != nil {
+	if err = party.DbUpdateGuestList(guest); err != nil {
+		err, respCode := transitionError(err)
+		return guestName, err, respCode
+	}
+	if len(companions) > 0 {
+		if err = party.DbSetCompanions(guest.Id, companions); err != nil {
+			return guestName, err, http.StatusInternalServerError
+		}
+	}
+	if err = party.DbAddVisit(guest.Id); err != nil {
 		return guestName, err, http.StatusInternalServerError
 	}
-	guestName.Name = guestList.Name
+	guestName.ID = stored.Id
+	guestName.Name = stored.Name
 	return guestName, nil, http.StatusOK
 
 }
 
-// Update status of guest in db to checked-out
-func DeleteGuest(app *App, name string) (error, int) {
-	exists, err := app.Party.DbCheckGuestExists(name)
+// check that the locked table id seats the party of guest guestId with
+// accompanyingGuests next to the other parties at the table
+func checkCheckInSeats(party models.Party, id int64, guestId int64, accompanyingGuests int64) (error, int) {
+	capacity, err := party.DbGetTableCapacity(id)
 	if err != nil {
 		return err, http.StatusInternalServerError
 	}
-	if exists == 0 {
-		return fmt.Errorf("Guest %s is not present in Guestlist", name), http.StatusBadRequest
+
+	if accompanyingGuests+1 > capacity {
+		return newError(ErrTableCapacity, "Cannot update number of accompanying guests. Table capacity is %d", capacity), http.StatusConflict
 	}
 
-	status, err := app.Party.DbGetGuestStatus(name)
+	// other parties can only be seated on shared tables
+	seats, err := seatsOfOtherParties(party, id, guestId)
 	if err != nil {
 		return err, http.StatusInternalServerError
 	}
+	if seats+accompanyingGuests+1 > capacity {
+		return newError(ErrTableCapacity, "Cannot update number of accompanying guests. %d of %d seats are free", capacity-seats, capacity), http.StatusConflict
+	}
+	return nil, http.StatusOK
+}
+
+// find another table for the party of guest that grew beyond the seats of
+// its table at check-in, refused is the error of its own table. With
+// ReassignMove the party is moved to the smallest table it fits on and the
+// move is recorded, with ReassignSuggest the check-in is refused with the
+// tables the party fits on.
+func reassignTable(party models.Party, guest models.Guests, reassign string, sharedTables bool, refused error) (int64, error, int) {
+	guestList := GuestList{ID: guest.Id, Name: guest.Name, AccompanyingGuests: guest.AccompanyingGuests}
+	if reassign == ReassignSuggest {
+		candidates, err := freeTables(party, guestList, WalkInPolicy{}, sharedTables)
+		if err != nil {
+			return 0, err, http.StatusInternalServerError
+		}
+		var tables []TableSeats
+		for _, table := range candidates {
+			guestList.Table = table.ID
+			err, respCode := checkConstraints(party, guestList, sharedTables)
+			if errors.Is(err, ErrConstraintViolated) {
+				continue
+			}
+			if err != nil {
+				return 0, err, respCode
+			}
+			tables = append(tables, table)
+		}
+		e := *refused.(*Error)
+		if tables != nil {
+			e.Extra = map[string]interface{}{"candidate_tables": tables}
+		}
+		return 0, &e, http.StatusConflict
+	}
+
+	table, err, respCode := chooseTable(party, guestList, WalkInPolicy{}, sharedTables)
+	if err != nil {
+		return 0, err, respCode
+	}
+	if table == 0 {
+		return 0, refused, http.StatusConflict
+	}
+	move := models.TableMove{GuestId: guest.Id, Name: guest.Name, From: guest.Table, To: table, AccompanyingGuests: guest.AccompanyingGuests}
+	guest.Table = table
+	if err = party.DbUpdateGuestAllotment(guest); err != nil {
+		return 0, err, http.StatusInternalServerError
+	}
+	if err = party.DbAddTableMove(move); err != nil {
+		return 0, err, http.StatusInternalServerError
+	}
+	return table, nil, http.StatusOK
+}
 
-	if status == models.ALLOTTED {
-		return fmt.Errorf("Request failed, guest not checked-in"), http.StatusBadRequest
-	} else if status == models.CHECKEDOUT {
-		return fmt.Errorf("Request failed, guest already checked-out"), http.StatusBadRequest
+// table moves of the event in the order they were made
+func GetTableMoves(app *App) ([]TableMove, error, int) {
+	moves := []TableMove{}
+	stored, err := app.Party.DbGetTableMoves()
+	if err != nil {
+		return moves, err, http.StatusInternalServerError
 	}
+	for _, m := range stored {
+		moves = append(moves, TableMove{
+			GuestID:            m.GuestId,
+			Name:               m.Name,
+			From:               m.From,
+			To:                 m.To,
+			AccompanyingGuests: m.AccompanyingGuests,
+			TimeMoved:          m.TimeMoved,
+		})
+	}
+	return moves, nil, http.StatusOK
+}
 
-	if err := app.Party.DbUpdateGuestStatus(name, models.CHECKEDOUT); err != nil {
+// Update status of guest in db to checked-out and end the visit of the
+// guest, waiting parties get the seats freed
+func DeleteGuest(app *App, ref GuestRef) (error, int) {
+	respCode := http.StatusOK
+	err := app.Party.DbTransaction(func(tx models.Party) error {
+		if err := tx.DbLockEvent(); err != nil {
+			return err
+		}
+		var err error
+		err, respCode = deleteGuest(tx, ref)
+		if err == nil {
+			err, respCode = processWaitlist(tx, app.SharedTables, app.WaitlistOrder)
+		}
+		return err
+	})
+	if err != nil && respCode == http.StatusOK {
+		respCode = http.StatusInternalServerError
+	}
+	return err, respCode
+}
+
+func deleteGuest(party models.Party, ref GuestRef) (error, int) {
+	guest, err, respCode := findGuest(party, ref)
+	if err != nil {
+		return err, respCode
+	}
+
+	id, err := party.DbGetTableIdOfGuest(guest.Id)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if _, err = party.DbLockTable(id); err != nil {
+		return err, http.StatusInternalServerError
+	}
+
+	status, err := party.DbGetGuestStatus(guest.Id)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+
+	if err := models.GuestStatus.Check(status, models.CHECKEDOUT); err != nil {
+		return statusError(err.(*models.TransitionError)), http.StatusConflict
+	}
+
+	if err := party.DbUpdateGuestStatus(guest.Id, models.CHECKEDOUT); err != nil {
+		return transitionError(err)
+	}
+	if err := party.DbEndVisit(guest.Id); err != nil {
 		return err, http.StatusInternalServerError
 	}
 	return nil, http.StatusOK
 }
 
-// sum of capacity of all tables -
-// (no.of guests arrived + sum of accompanying guests of arrived guests)
+// free seats of every table and totals of all tables. The seats of a table
+// are reserved for the allotted parties and occupied by the checked-in
+// parties, the remaining seats are free on shared tables and on tables no
+// party was allotted to.
 func GetEmptySeats(app *App) (EmptySeats, error, int) {
-	var emptySeats EmptySeats
-	empty, err := app.Party.DbIsTablesEmpty()
+	emptySeats := EmptySeats{Tables: []TableSeats{}}
+	tables, err := app.Party.DbGetTableSeats()
 	if err != nil {
 		return emptySeats, err, http.StatusInternalServerError
 	}
-	if empty {
-		return emptySeats, nil, http.StatusOK
+	for _, table := range tables {
+		seats := TableSeats{
+			ID:       table.Id,
+			Capacity: table.Capacity,
+			Reserved: table.AllottedSeats,
+			Occupied: table.CheckedInSeats,
+		}
+		seats.Free = freeSeats(table, app.SharedTables)
+		emptySeats.Tables = append(emptySeats.Tables, seats)
+		emptySeats.SeatsEmpty += seats.Free
+		emptySeats.Capacity += seats.Capacity
+		emptySeats.Reserved += seats.Reserved
+		emptySeats.Occupied += seats.Occupied
+		emptySeats.Guests.Allotted.Parties += table.AllottedParties
+		emptySeats.Guests.Allotted.Seats += table.AllottedSeats
+		emptySeats.Guests.CheckedIn.Parties += table.CheckedInParties
+		emptySeats.Guests.CheckedIn.Seats += table.CheckedInSeats
+		emptySeats.Guests.CheckedOut.Parties += table.CheckedOutParties
+		emptySeats.Guests.CheckedOut.Seats += table.CheckedOutSeats
+		emptySeats.Guests.NoShow.Parties += table.NoShowParties
+		emptySeats.Guests.NoShow.Seats += table.NoShowSeats
+		emptySeats.Guests.Cancelled.Parties += table.CancelledParties
+		emptySeats.Guests.Cancelled.Seats += table.CancelledSeats
 	}
-	capacity, err := app.Party.DbGetCapacitySum()
+	return emptySeats, nil, http.StatusOK
+}
+
+// seats of table a new party can take: the seats not reserved or occupied
+// on shared tables and on tables no expected or present party is allotted to
+func freeSeats(table models.TableSeats, sharedTables bool) int64 {
+	parties := table.AllottedParties + table.CheckedInParties
+	if !sharedTables && parties > 0 {
+		return 0
+	}
+	return table.Capacity - table.AllottedSeats - table.CheckedInSeats
+}
+
+// add the tables and guests of a seating plan within a single transaction.
+// Every row is checked like a single table or guest would be and all rows are
+// reported. Nothing is added when a row fails or on a dry run, otherwise
+// waiting parties get the seats of the tables added.
+func ImportSeatingPlan(app *App, plan SeatingPlan, dryRun bool) (ImportReport, error, int) {
+	var report ImportReport
+	respCode := http.StatusOK
+	err := app.Party.DbTransaction(func(tx models.Party) error {
+		if err := tx.DbLockEvent(); err != nil {
+			return err
+		}
+		var err error
+		report, err, respCode = importSeatingPlan(tx, plan, app.SharedTables)
+		if err == nil && dryRun {
+			return errDryRun
+		}
+		if err == nil {
+			err, respCode = processWaitlist(tx, app.SharedTables, app.WaitlistOrder)
+		}
+		return err
+	})
+	report.DryRun = dryRun
+	if err == errDryRun {
+		return report, nil, http.StatusOK
+	}
+	if err != nil && respCode == http.StatusOK {
+		respCode = http.StatusInternalServerError
+	}
+	return report, err, respCode
+}
+
+// rolls back the transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+func importSeatingPlan(party models.Party, plan SeatingPlan, sharedTables bool) (ImportReport, error, int) {
+	report := ImportReport{Rows: []ImportRow{}}
+	failed := 0
+	rowError := func(row ImportRow, err error) {
+		e := err.(*Error)
+		row.Code = e.Code.code
+		row.Message = e.Message
+		row.Details = e.Details
+		report.Rows = append(report.Rows, row)
+		failed++
+	}
+
+	// tables are added first so that guests can refer to them by their
+	// number in the plan
+	tableIds := map[int64]int64{}
+	for _, t := range plan.Tables {
+		row := ImportRow{Row: t.Row, Kind: "table"}
+		details, err := validateCapacity(t.Capacity)
+		if err != nil {
+			return report, err, http.StatusInternalServerError
+		}
+		details = append(t.errs, details...)
+		if _, ok := tableIds[t.Table]; ok && t.Table != 0 {
+			details = append(details, FieldError{Field: "table", Message: fmt.Sprintf("table %d is imported more than once", t.Table)})
+		}
+		if details != nil {
+			rowError(row, validationError(details))
+			continue
+		}
+		id, err := party.DbAddTable(t.Capacity)
+		if err != nil {
+			return report, err, http.StatusInternalServerError
+		}
+		if t.Table != 0 {
+			tableIds[t.Table] = id
+		}
+		row.Table = id
+		report.Rows = append(report.Rows, row)
+		report.Tables++
+	}
+
+	for _, g := range plan.Guests {
+		guestList := GuestList{
+			Name:               models.DisplayName(g.Name),
+			Table:              g.Table,
+			AccompanyingGuests: g.AccompanyingGuests,
+		}
+		row := ImportRow{Row: g.Row, Kind: "guest", Name: guestList.Name}
+		details, err := validateName(guestList.Name)
+		if err != nil {
+			return report, err, http.StatusInternalServerError
+		}
+		more, err := validateGuestList(guestList)
+		if err != nil {
+			return report, err, http.StatusInternalServerError
+		}
+		details = append(append(g.errs, details...), more...)
+		if details != nil {
+			rowError(row, validationError(details))
+			continue
+		}
+		if id, ok := tableIds[g.Table]; ok {
+			guestList.Table = id
+		}
+		guestName, err, respCode := addGuestList(party, guestList, true, sharedTables)
+		if err != nil {
+			var e *Error
+			if !errors.As(err, &e) {
+				return report, err, respCode
+			}
+			rowError(row, e)
+			continue
+		}
+		row.Table = guestList.Table
+		row.GuestID = guestName.ID
+		report.Rows = append(report.Rows, row)
+		report.Guests++
+	}
+
+	if failed > 0 {
+		return report, &Error{
+			Code:    ErrImportFailed,
+			Message: fmt.Sprintf("Import failed, %d of %d rows are invalid", failed, len(report.Rows)),
+			Extra:   map[string]interface{}{"rows": report.Rows},
+		}, http.StatusBadRequest
+	}
+	return report, nil, http.StatusOK
+}
+
+func eventFromModel(e models.Event) Event {
+	event := Event{ID: e.Id, Name: e.Name, Venue: e.Venue}
+	if !e.Date.IsZero() {
+		event.Date = e.Date.Format(dateLayout)
+	}
+	return event
+}
+
+func AddEvent(app *App, event Event) (Event, error, int) {
+	var e models.Event
+	e.Name = event.Name
+	e.Venue = event.Venue
+	if event.Date != "" {
+		date, err := time.Parse(dateLayout, event.Date)
+		if err != nil {
+			return event, err, http.StatusInternalServerError
+		}
+		e.Date = date
+	}
+	id, err := app.Party.DbAddEvent(e)
 	if err != nil {
-		return emptySeats, err, http.StatusInternalServerError
+		return event, err, http.StatusInternalServerError
 	}
-	emptySeats.SeatsEmpty = capacity
-	empty, err = app.Party.DbIsGuestsEmpty(models.CHECKEDIN)
+	event.ID = id
+	return event, nil, http.StatusOK
+}
+
+func GetEvents(app *App) ([]Event, error, int) {
+	eventList := []Event{}
+	events, err := app.Party.DbGetEvents()
 	if err != nil {
-		return emptySeats, err, http.StatusInternalServerError
+		return eventList, err, http.StatusInternalServerError
 	}
-	if empty {
-		return emptySeats, nil, http.StatusOK
+	for _, e := range events {
+		eventList = append(eventList, eventFromModel(e))
 	}
-	accompGuests, guestsCount, err := app.Party.DbGetAccompanyingGuestsSum(models.CHECKEDIN)
+	return eventList, nil, http.StatusOK
+}
+
+// get an event together with the statistics of its tables and guests
+func GetEvent(app *App, id int64) (EventDetails, error, int) {
+	var details EventDetails
+	exists, err := app.Party.DbCheckEventExists(id)
 	if err != nil {
-		return emptySeats, err, http.StatusInternalServerError
+		return details, err, http.StatusInternalServerError
 	}
-	emptySeats.SeatsEmpty -= (accompGuests + guestsCount)
-	return emptySeats, nil, http.StatusOK
+	if exists == 0 {
+		return details, newError(ErrEventNotFound, "Event %d not found", id), http.StatusNotFound
+	}
+	e, err := app.Party.DbGetEvent(id)
+	if err != nil {
+		return details, err, http.StatusInternalServerError
+	}
+	details.Event = eventFromModel(e)
+
+	emptySeats, err, respCode := GetEmptySeats(&App{Party: app.Party.DbForEvent(id), SharedTables: app.SharedTables})
+	if err != nil {
+		return details, err, respCode
+	}
+	details.Statistics.Tables = int64(len(emptySeats.Tables))
+	details.Statistics.Capacity = emptySeats.Capacity
+	details.Statistics.Allotted = emptySeats.Guests.Allotted.Parties
+	details.Statistics.CheckedIn = emptySeats.Guests.CheckedIn.Parties
+	details.Statistics.CheckedOut = emptySeats.Guests.CheckedOut.Parties
+	details.Statistics.NoShow = emptySeats.Guests.NoShow.Parties
+	details.Statistics.Cancelled = emptySeats.Guests.Cancelled.Parties
+	details.Statistics.Guests = details.Statistics.Allotted + details.Statistics.CheckedIn + details.Statistics.CheckedOut +
+		details.Statistics.NoShow + details.Statistics.Cancelled
+	details.Statistics.SeatsEmpty = emptySeats.SeatsEmpty
+	return details, nil, http.StatusOK
 }
@@ -0,0 +1,203 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	models "github.com/getground/tech-tasks/backend/pkg/models"
+)
+
+// ErrorCode is a stable, machine readable error code sent to clients in the
+// code field of error responses.
+type ErrorCode struct {
+	code string
+}
+
+func (c *ErrorCode) Error() string {
+	return c.code
+}
+
+// Sentinel errors of the controller functions, test for them with errors.Is.
+var (
+	ErrInternal            = &ErrorCode{"internal_error"}
+	ErrNotFound            = &ErrorCode{"not_found"}
+	ErrMethodNotAllowed    = &ErrorCode{"method_not_allowed"}
+	ErrInvalidJSON         = &ErrorCode{"invalid_json"}
+	ErrInvalidCSV          = &ErrorCode{"invalid_csv"}
+	ErrValidation          = &ErrorCode{"validation_failed"}
+	ErrInvalidTableId      = &ErrorCode{"invalid_table_id"}
+	ErrUnknownTable        = &ErrorCode{"unknown_table"}
+	ErrTableNotFound       = &ErrorCode{"table_not_found"}
+	ErrTableCapacity       = &ErrorCode{"table_capacity_exceeded"}
+	ErrTableAllotted       = &ErrorCode{"table_allotted"}
+	ErrTableInUse          = &ErrorCode{"table_in_use"}
+	ErrInvalidGuestId      = &ErrorCode{"invalid_guest_id"}
+	ErrGuestNotFound       = &ErrorCode{"guest_not_found"}
+	ErrGuestExists         = &ErrorCode{"guest_exists"}
+	ErrGuestAmbiguous      = &ErrorCode{"guest_name_ambiguous"}
+	ErrGuestArrived        = &ErrorCode{"guest_arrived"}
+	ErrGuestNotCheckedIn   = &ErrorCode{"guest_not_checked_in"}
+	ErrGuestCheckedIn      = &ErrorCode{"guest_checked_in"}
+	ErrInvalidTransition   = &ErrorCode{"invalid_status_transition"}
+	ErrGuestCheckedOut     = &ErrorCode{"guest_checked_out"}
+	ErrInvalidEventId      = &ErrorCode{"invalid_event_id"}
+	ErrEventNotFound       = &ErrorCode{"event_not_found"}
+	ErrImportFailed        = &ErrorCode{"import_failed"}
+	ErrInvalidConstraintId = &ErrorCode{"invalid_constraint_id"}
+	ErrConstraintNotFound  = &ErrorCode{"constraint_not_found"}
+	ErrConstraintViolated  = &ErrorCode{"constraint_violated"}
+	ErrNoTableAvailable    = &ErrorCode{"no_table_available"}
+	ErrInvalidWaitlistId   = &ErrorCode{"invalid_waitlist_id"}
+	ErrWaitlistNotFound    = &ErrorCode{"waitlist_entry_not_found"}
+)
+
+// FieldError describes why the value of a request field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// StatusTransition is a change of the status of a guest
+type StatusTransition struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Error is returned by the controller functions for failures the client can
+// act on. It wraps one of the sentinel error codes. Extra carries the data a
+// kind of failure adds, e.g. the guests an ambiguous name refers to, keyed by
+// its name in the response.
+type Error struct {
+	Code    *ErrorCode
+	Message string
+	Details []FieldError
+	Extra   map[string]interface{}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Code
+}
+
+func newError(code *ErrorCode, format string, args ...interface{}) error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func validationError(details []FieldError) error {
+	var messages []string
+	for _, d := range details {
+		messages = append(messages, d.Message)
+	}
+	return &Error{Code: ErrValidation, Message: strings.Join(messages, ", "), Details: details}
+}
+
+// statusError returns the error of a change of guest status the state
+// machine does not allow. The common mistakes keep their own codes.
+func statusError(e *models.TransitionError) error {
+	err := &Error{
+		Code:    ErrInvalidTransition,
+		Message: fmt.Sprintf("Request failed, guest status cannot change from %s to %s", e.From, e.To),
+		Extra:   map[string]interface{}{"transition": StatusTransition{From: e.From, To: e.To}},
+	}
+	switch {
+	case e.To == models.CHECKEDOUT && e.From == models.CHECKEDOUT:
+		err.Code = ErrGuestCheckedOut
+		err.Message = "Request failed, guest already checked-out"
+	case e.To == models.CHECKEDOUT:
+		err.Code = ErrGuestNotCheckedIn
+		err.Message = "Request failed, guest not checked-in"
+	case e.To == models.CHECKEDIN && e.From == models.CHECKEDIN:
+		err.Code = ErrGuestCheckedIn
+		err.Message = "Request failed, guest already checked-in"
+	}
+	return err
+}
+
+// transitionError converts the *models.TransitionError returned by the
+// storage to the error sent to the client, sql.ErrNoRows is returned for a
+// guest that no longer exists
+func transitionError(err error) (error, int) {
+	var e *models.TransitionError
+	if errors.As(err, &e) {
+		return statusError(e), http.StatusConflict
+	}
+	if err == sql.ErrNoRows {
+		return newError(ErrGuestNotFound, "Request failed, guest is not present in Guestlist"), http.StatusNotFound
+	}
+	return err, http.StatusInternalServerError
+}
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Details   []FieldError           `json:"details,omitempty"`
+	Extra     map[string]interface{} `json:"extra,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+}
+
+func sendErrorResponse(w http.ResponseWriter, r *http.Request, err error, responseCode int) {
+	fmt.Println(err.Error())
+	resp := ErrorResponse{
+		Code:      ErrInternal.code,
+		Message:   "Internal server error",
+		RequestID: requestId(r),
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		resp.Code = e.Code.code
+		resp.Message = e.Message
+		resp.Details = e.Details
+		resp.Extra = e.Extra
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(responseCode)
+	json.NewEncoder(w).Encode(resp)
+}
+
+type requestIdKey struct{}
+
+const requestIdHeader = "X-Request-Id"
+
+// RequestIdMiddleware tags every request with the id sent by the client in
+// the X-Request-Id header or a new random id, and echoes it in the response.
+func RequestIdMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIdHeader)
+		if id == "" {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set(requestIdHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIdKey{}, id)))
+	})
+}
+
+func requestId(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIdKey{}).(string); ok {
+		return id
+	}
+	return r.Header.Get(requestIdHeader)
+}
+
+// http handler for unknown routes
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	sendErrorResponse(w, r, newError(ErrNotFound, "%s not found", r.URL.Path), http.StatusNotFound)
+}
+
+// http handler for known routes requested with an unsupported method
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	sendErrorResponse(w, r, newError(ErrMethodNotAllowed, "Method %s not allowed on %s", r.Method, r.URL.Path),
+		http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"encoding/csv"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formats of the list responses
+const (
+	formatJSON  = "json"
+	formatCSV   = "csv"
+	formatExcel = "excel"
+	formatHTML  = "html"
+)
+
+// media types of the formats in the order they are preferred when the
+// Accept header allows any of them
+var exportMediaTypes = []struct {
+	mediaType string
+	format    string
+}{
+	{"application/json", formatJSON},
+	{"text/csv", formatCSV},
+	{"application/vnd.ms-excel", formatExcel},
+	{"text/html", formatHTML},
+}
+
+// rows of a printed page of the html format
+const printRowsPerPage = 25
+
+// exportFormat returns the format requested by the format query parameter or
+// else by the Accept header. JSON is sent when neither names a known format.
+func exportFormat(r *http.Request) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch format {
+		case formatJSON, formatCSV, formatExcel, formatHTML:
+			return format, nil
+		}
+		details := []FieldError{{Field: "format", Message: "format must be one of [json csv excel html]"}}
+		return "", validationError(details)
+	}
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		params := strings.Split(accepted, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		refused := false
+		for _, param := range params[1:] {
+			if q := strings.ReplaceAll(param, " ", ""); q == "q=0" || q == "q=0.0" {
+				refused = true
+			}
+		}
+		if refused {
+			continue
+		}
+		for _, m := range exportMediaTypes {
+			if m.mediaType == mediaType {
+				return m.format, nil
+			}
+		}
+	}
+	return formatJSON, nil
+}
+
+// export is a list response as rows of text. Check adds an empty column to
+// tick off the rows of the printed list.
+type export struct {
+	name   string
+	title  string
+	header []string
+	rows   [][]string
+	check  string
+}
+
+// time layout of the exports, Excel recognizes dates without a zone
+func exportTimeLayout(format string) string {
+	if format == formatExcel {
+		return "2006-01-02 15:04:05"
+	}
+	return time.RFC3339
+}
+
+func exportTime(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(layout)
+}
+
+// guest list with a column to tick off arriving guests
+func guestListExport(guestList []GuestList) export {
+	e := export{
+		name:   "guest_list",
+		title:  "Guest list",
+		header: []string{"id", "name", "table", "accompanying_guests", "status"},
+		check:  "arrived",
+	}
+	for _, g := range guestList {
+		e.rows = append(e.rows, []string{
+			strconv.FormatInt(g.ID, 10),
+			g.Name,
+			strconv.FormatInt(g.Table, 10),
+			strconv.FormatInt(g.AccompanyingGuests, 10),
+			g.Status,
+		})
+	}
+	return e
+}
+
+// every visit of the arrived guests in the order of arrival
+func guestsExport(guests []ArrivedGuests, layout string) export {
+	e := export{
+		name:   "guests",
+		title:  "Arrivals",
+		header: []string{"id", "name", "accompanying_guests", "time_arrived", "time_left"},
+	}
+	type row struct {
+		id    int64
+		name  string
+		visit Visit
+	}
+	var visits []row
+	for _, g := range guests {
+		for _, v := range g.Visits {
+			visits = append(visits, row{g.ID, g.Name, v})
+		}
+	}
+	sort.SliceStable(visits, func(i, j int) bool {
+		if !visits[i].visit.TimeArrived.Equal(visits[j].visit.TimeArrived) {
+			return visits[i].visit.TimeArrived.Before(visits[j].visit.TimeArrived)
+		}
+		if visits[i].name != visits[j].name {
+			return visits[i].name < visits[j].name
+		}
+		return visits[i].id < visits[j].id
+	})
+	for _, v := range visits {
+		timeLeft := ""
+		if v.visit.TimeLeft != nil {
+			timeLeft = exportTime(*v.visit.TimeLeft, layout)
+		}
+		e.rows = append(e.rows, []string{
+			strconv.FormatInt(v.id, 10),
+			v.name,
+			strconv.FormatInt(v.visit.AccompanyingGuests, 10),
+			exportTime(v.visit.TimeArrived, layout),
+			timeLeft,
+		})
+	}
+	return e
+}
+
+// tables by id with the names of their guests
+func tablesExport(tables []TableOccupancy) export {
+	e := export{
+		name:   "tables",
+		title:  "Tables",
+		header: []string{"id", "capacity", "seats_allotted", "seats_occupied", "guests"},
+	}
+	sorted := append([]TableOccupancy(nil), tables...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	for _, t := range sorted {
+		var names []string
+		for _, g := range t.Guests {
+			names = append(names, g.Name)
+		}
+		e.rows = append(e.rows, []string{
+			strconv.FormatInt(t.ID, 10),
+			strconv.FormatInt(t.Capacity, 10),
+			strconv.FormatInt(t.Allotted, 10),
+			strconv.FormatInt(t.Occupied, 10),
+			strings.Join(names, "; "),
+		})
+	}
+	return e
+}
+
+// sendExport writes e in the csv, excel or html format
+func sendExport(w http.ResponseWriter, format string, e export) {
+	if format == formatHTML {
+		sendHTML(w, e)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+e.name+`.csv"`)
+	w.WriteHeader(http.StatusOK)
+	if format == formatExcel {
+		// the byte order mark makes Excel read the file as UTF-8
+		w.Write([]byte("\ufeff"))
+	}
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = format == formatExcel
+	writer.Write(e.header)
+	for _, row := range e.rows {
+		if format == formatExcel {
+			row = excelRow(row)
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+}
+
+// excelRow quotes the cells Excel would run as a formula
+func excelRow(row []string) []string {
+	quoted := make([]string, len(row))
+	for i, cell := range row {
+		if cell != "" && strings.ContainsAny(cell[:1], "=+-@") {
+			cell = "'" + cell
+		}
+		quoted[i] = cell
+	}
+	return quoted
+}
+
+var printTemplate = template.Must(template.New("print").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #000; padding: 4px 8px; text-align: left; }
+section { page-break-after: always; }
+section:last-child { page-break-after: auto; }
+</style>
+</head>
+<body>
+{{- range .Pages}}
+<section>
+<h1>{{$.Title}}</h1>
+<p>Page {{.Number}} of {{len $.Pages}}</p>
+<table>
+<thead><tr>{{range $.Header}}<th>{{.}}</th>{{end}}{{if $.Check}}<th>{{$.Check}}</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr>{{range .}}<td>{{.}}</td>{{end}}{{if $.Check}}<td></td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+type printPage struct {
+	Number int
+	Rows   [][]string
+}
+
+// sendHTML writes e as a printable html page split in pages of
+// printRowsPerPage rows
+func sendHTML(w http.ResponseWriter, e export) {
+	pages := []printPage{{Number: 1}}
+	for i, row := range e.rows {
+		if i > 0 && i%printRowsPerPage == 0 {
+			pages = append(pages, printPage{Number: len(pages) + 1})
+		}
+		pages[len(pages)-1].Rows = append(pages[len(pages)-1].Rows, row)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	printTemplate.Execute(w, struct {
+		Title  string
+		Header []string
+		Check  string
+		Pages  []printPage
+	}{e.title, e.header, e.check, pages})
+}
@@ -1,47 +1,90 @@
 package controller
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
 	_ "github.com/go-sql-driver/mysql"
+	models "github.com/getground/tech-tasks/backend/pkg/models"
 	"github.com/gorilla/mux"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
-func sendErrorResponse(w http.ResponseWriter, err error, responseCode int) {
-	fmt.Println(err.Error())
-	w.WriteHeader(responseCode)
-	fmt.Fprintf(w, "%s", err.Error())
-}
-
 // http handler to add a table
 func (app *App) AddTableHandler(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		sendErrorResponse(w, err, http.StatusInternalServerError)
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	var table Table
 	err = json.Unmarshal(body, &table)
 	if err != nil {
-		sendErrorResponse(w, err, http.StatusInternalServerError)
+		sendErrorResponse(w, r, newError(ErrInvalidJSON, "Invalid JSON body: %v", err), http.StatusBadRequest)
 		return
 	}
-	errs, err := validateCapacity(table.Capacity)
+	details, err := validateCapacity(table.Capacity)
 	if err != nil {
-		sendErrorResponse(w, err, http.StatusInternalServerError)
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	if errs != "" {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "%s", errs)
+	if details != nil {
+		sendErrorResponse(w, r, validationError(details), http.StatusBadRequest)
 		return
 	}
 	table, err, respCode := AddTable(app, table)
 	if err != nil {
-		sendErrorResponse(w, err, respCode)
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(table)
+}
+
+// table id from the request path
+func tableId(r *http.Request) (int64, error) {
+	params := mux.Vars(r)
+	id, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil || id <= 0 {
+		return 0, newError(ErrInvalidTableId, "Invalid table-id")
+	}
+	return id, nil
+}
+
+// http handler to get all tables
+func (app *App) GetTablesHandler(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+	tables, err, respCode := GetTables(app)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	if format != formatJSON {
+		sendExport(w, format, tablesExport(tables))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tables)
+}
+
+// http handler to get a table
+func (app *App) GetTableHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := tableId(r)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+	table, err, respCode := GetTable(app, id)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -49,69 +92,194 @@ func (app *App) AddTableHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(table)
 }
 
+// http handler to change the capacity of a table
+func (app *App) UpdateTableHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := tableId(r)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	var table Table
+	err = json.Unmarshal(body, &table)
+	if err != nil {
+		sendErrorResponse(w, r, newError(ErrInvalidJSON, "Invalid JSON body: %v", err), http.StatusBadRequest)
+		return
+	}
+	details, err := validateCapacity(table.Capacity)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if details != nil {
+		sendErrorResponse(w, r, validationError(details), http.StatusBadRequest)
+		return
+	}
+	table.ID = id
+	occupancy, err, respCode := UpdateTable(app, table)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(occupancy)
+}
+
+// http handler to delete a table
+func (app *App) DeleteTableHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := tableId(r)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+	err, respCode := DeleteTable(app, id)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // http handler to get guest list
 func (app *App) GetGuestListHandler(w http.ResponseWriter, r *http.Request) {
-	guestList, err, respCode := GetGuestList(app)
+	format, err := exportFormat(r)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+	query, err := guestQuery(r, guestListStatuses, []string{models.SortByName, models.SortByTable}, format)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+	page, err, respCode := GetGuestList(app, query)
 	if err != nil {
-		sendErrorResponse(w, err, respCode)
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	setNextLink(w, r, page.Next)
+	if format != formatJSON {
+		sendExport(w, format, guestListExport(page.Guests))
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(guestList)
+	json.NewEncoder(w).Encode(page.Guests)
 }
 
 // http handler to get arrived guests
 func (app *App) GetGuestsHandler(w http.ResponseWriter, r *http.Request) {
-	guests, err, respCode := GetGuests(app)
+	format, err := exportFormat(r)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+	query, err := guestQuery(r, []string{models.CHECKEDIN, models.CHECKEDOUT},
+		[]string{models.SortByName, models.SortByTable, models.SortByTimeArrived}, format)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+	page, err, respCode := GetGuests(app, query)
 	if err != nil {
-		sendErrorResponse(w, err, respCode)
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	setNextLink(w, r, page.Next)
+	if format != formatJSON {
+		sendExport(w, format, guestsExport(page.Guests, exportTimeLayout(format)))
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(guests)
+	json.NewEncoder(w).Encode(page.Guests)
 }
 
-// http hander to allot a table to guest
-func (app *App) AddGuestListHandler(w http.ResponseWriter, r *http.Request) {
-	body, err := ioutil.ReadAll(r.Body)
+// http handler to search the guest list by name, q is matched by prefix
+// and fuzzily ignoring case and accents
+func (app *App) SearchGuestsHandler(w http.ResponseWriter, r *http.Request) {
+	query, limit, err := searchQuery(r)
 	if err != nil {
-		sendErrorResponse(w, err, http.StatusInternalServerError)
+		sendErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
-	params := mux.Vars(r)
-	name := strings.ToLower(params["name"])
-	errs, err := validateName(name)
+	results, err, respCode := SearchGuests(app, query, limit)
 	if err != nil {
-		sendErrorResponse(w, err, http.StatusInternalServerError)
+		sendErrorResponse(w, r, err, respCode)
 		return
 	}
-	if errs != "" {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "%s", errs)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(results)
+}
+
+// guest referred to by the request path, by the id path variable of the
+// by-id routes and else by the name path variable
+func guestRef(r *http.Request) (GuestRef, error, int) {
+	params := mux.Vars(r)
+	if value, ok := params["id"]; ok {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			return GuestRef{}, newError(ErrInvalidGuestId, "Invalid guest-id"), http.StatusBadRequest
+		}
+		return GuestRef{ID: id}, nil, http.StatusOK
+	}
+	name := models.DisplayName(params["name"])
+	details, err := validateName(name)
+	if err != nil {
+		return GuestRef{}, err, http.StatusInternalServerError
+	}
+	if details != nil {
+		return GuestRef{}, validationError(details), http.StatusBadRequest
+	}
+	return GuestRef{Name: name}, nil, http.StatusOK
+}
+
+// http hander to allot a table to guest. The name of the guest is taken from
+// the path, which only adds a guest no other guest has the name of, or else
+// from the body.
+func (app *App) AddGuestListHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	var guestList GuestList
 	err = json.Unmarshal(body, &guestList)
 	if err != nil {
-		sendErrorResponse(w, err, http.StatusInternalServerError)
+		sendErrorResponse(w, r, newError(ErrInvalidJSON, "Invalid JSON body: %v", err), http.StatusBadRequest)
+		return
+	}
+	params := mux.Vars(r)
+	name, uniqueName := params["name"]
+	if !uniqueName {
+		name = guestList.Name
+	}
+	name = models.DisplayName(name)
+	details, err := validateName(name)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	errs, err = validateGuestList(guestList)
+	more, err := validateGuestList(guestList)
 	if err != nil {
-		sendErrorResponse(w, err, http.StatusInternalServerError)
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	if errs != "" {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "%s", errs)
+	details = append(details, more...)
+	if details != nil {
+		sendErrorResponse(w, r, validationError(details), http.StatusBadRequest)
 		return
 	}
 	guestList.Name = name
-	guestName, err, respCode := AddGuestList(app, guestList)
+	guestName, err, respCode := AddGuestList(app, guestList, uniqueName)
 	if err != nil {
-		sendErrorResponse(w, err, respCode)
+		sendErrorResponse(w, r, err, respCode)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -119,59 +287,169 @@ func (app *App) AddGuestListHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(guestName)
 }
 
+// http handler to change the table or accompanying guests of a guest who
+// has not arrived yet
+func (app *App) EditGuestListHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	ref, err, respCode := guestRef(r)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	var update GuestListUpdate
+	err = json.Unmarshal(body, &update)
+	if err != nil {
+		sendErrorResponse(w, r, newError(ErrInvalidJSON, "Invalid JSON body: %v", err), http.StatusBadRequest)
+		return
+	}
+	details, err := validateGuestListUpdate(update)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if details != nil {
+		sendErrorResponse(w, r, validationError(details), http.StatusBadRequest)
+		return
+	}
+	guestList, err, respCode := EditGuestList(app, ref, update)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(guestList)
+}
+
+// http handler to remove a guest who has not arrived yet from the guest list
+func (app *App) RemoveGuestListHandler(w http.ResponseWriter, r *http.Request) {
+	ref, err, respCode := guestRef(r)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	err, respCode = RemoveGuestList(app, ref)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// http handler to get a guest of the guest list
+func (app *App) GetGuestHandler(w http.ResponseWriter, r *http.Request) {
+	ref, err, respCode := guestRef(r)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	guestList, err, respCode := GetGuest(app, ref)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(guestList)
+}
+
 // http handler to check-in a guest
 func (app *App) UpdateGuestHandler(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		sendErrorResponse(w, err, http.StatusInternalServerError)
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	params := mux.Vars(r)
-	name := strings.ToLower(params["name"])
-	errs, err := validateName(name)
+	ref, err, respCode := guestRef(r)
 	if err != nil {
-		sendErrorResponse(w, err, http.StatusInternalServerError)
+		sendErrorResponse(w, r, err, respCode)
 		return
 	}
-	if errs != "" {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "%s", errs)
+	var checkIn CheckIn
+	err = json.Unmarshal(body, &checkIn)
+	if err != nil {
+		sendErrorResponse(w, r, newError(ErrInvalidJSON, "Invalid JSON body: %v", err), http.StatusBadRequest)
 		return
 	}
-	var guestList GuestList
-	err = json.Unmarshal(body, &guestList)
+	details, err := validateCheckIn(checkIn)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if details != nil {
+		sendErrorResponse(w, r, validationError(details), http.StatusBadRequest)
+		return
+	}
+	guestName, err, respCode := UpdateGuestList(app, ref, checkIn)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(guestName)
+}
+
+// http handler to allot a table to a guest arriving without one and check
+// the guest in
+func (app *App) WalkInHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		sendErrorResponse(w, err, http.StatusInternalServerError)
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	errs, err = validateAccompanyingGuests(guestList.AccompanyingGuests)
+	var walkIn WalkIn
+	err = json.Unmarshal(body, &walkIn)
 	if err != nil {
-		sendErrorResponse(w, err, http.StatusInternalServerError)
+		sendErrorResponse(w, r, newError(ErrInvalidJSON, "Invalid JSON body: %v", err), http.StatusBadRequest)
 		return
 	}
-	if errs != "" {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "%s", errs)
+	walkIn.Name = models.DisplayName(walkIn.Name)
+	details, err := validateWalkIn(walkIn)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	guestList.Name = name
-	guestName, err, respCode := UpdateGuestList(app, guestList)
+	if details != nil {
+		sendErrorResponse(w, r, validationError(details), http.StatusBadRequest)
+		return
+	}
+	guestList, err, respCode := WalkInGuest(app, walkIn)
 	if err != nil {
-		sendErrorResponse(w, err, respCode)
+		sendErrorResponse(w, r, err, respCode)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(guestName)
+	json.NewEncoder(w).Encode(guestList)
+}
+
+// http handler to get the parties moved to another table at check-in
+func (app *App) GetTableMovesHandler(w http.ResponseWriter, r *http.Request) {
+	moves, err, respCode := GetTableMoves(app)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(moves)
 }
 
 // http handler to check-out a guest
 func (app *App) DeleteGuestHandler(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	name := strings.ToLower(params["name"])
-	err, respCode := DeleteGuest(app, name)
+	ref, err, respCode := guestRef(r)
 	if err != nil {
-		sendErrorResponse(w, err, respCode)
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	err, respCode = DeleteGuest(app, ref)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -181,10 +459,365 @@ func (app *App) DeleteGuestHandler(w http.ResponseWriter, r *http.Request) {
 func (app *App) GetEmptySeatsHandler(w http.ResponseWriter, r *http.Request) {
 	emptySeats, err, respCode := GetEmptySeats(app)
 	if err != nil {
-		sendErrorResponse(w, err, respCode)
+		sendErrorResponse(w, r, err, respCode)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(emptySeats)
 }
+
+// http handler to report how long guests stay, the optional limit query
+// parameter sets the number of longest stayers
+func (app *App) GetDwellReportHandler(w http.ResponseWriter, r *http.Request) {
+	limit := defaultLongestStayers
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			details := []FieldError{{Field: "limit", Message: "limit must be a number"}}
+			sendErrorResponse(w, r, validationError(details), http.StatusBadRequest)
+			return
+		}
+		details, err := validateLimit(limit)
+		if err != nil {
+			sendErrorResponse(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		if details != nil {
+			sendErrorResponse(w, r, validationError(details), http.StatusBadRequest)
+			return
+		}
+	}
+	report, err, respCode := GetDwellReport(app, limit)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// http handler to import a seating plan in CSV or JSON, the format is taken
+// from the format query parameter or the Content-Type header. With
+// dry_run=true the plan is only checked.
+func (app *App) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			details := []FieldError{{Field: "dry_run", Message: "dry_run must be true or false"}}
+			sendErrorResponse(w, r, validationError(details), http.StatusBadRequest)
+			return
+		}
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		format = "csv"
+	}
+	var plan SeatingPlan
+	switch format {
+	case "csv":
+		plan, err = ParseSeatingPlanCSV(bytes.NewReader(body))
+	case "", "json":
+		plan, err = ParseSeatingPlanJSON(body)
+	default:
+		details := []FieldError{{Field: "format", Message: "format must be one of [csv json]"}}
+		err = validationError(details)
+	}
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+	report, err, respCode := ImportSeatingPlan(app, plan, dryRun)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// http handler to propose tables for guests, nothing is added to the guest
+// list until the proposal is committed
+func (app *App) PlanSeatingHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	var request PlanRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		sendErrorResponse(w, r, newError(ErrInvalidJSON, "Invalid JSON body: %v", err), http.StatusBadRequest)
+		return
+	}
+	details, err := validatePlanRequest(request)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if details != nil {
+		sendErrorResponse(w, r, validationError(details), http.StatusBadRequest)
+		return
+	}
+	proposal, err, respCode := PlanSeating(app, request)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(proposal)
+}
+
+// http handler to add a seating constraint
+func (app *App) AddConstraintHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	var constraint Constraint
+	err = json.Unmarshal(body, &constraint)
+	if err != nil {
+		sendErrorResponse(w, r, newError(ErrInvalidJSON, "Invalid JSON body: %v", err), http.StatusBadRequest)
+		return
+	}
+	details, err := validateConstraint(constraint)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if details != nil {
+		sendErrorResponse(w, r, validationError(details), http.StatusBadRequest)
+		return
+	}
+	constraint, err, respCode := AddConstraint(app, constraint)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(constraint)
+}
+
+// http handler to get the seating constraints
+func (app *App) GetConstraintsHandler(w http.ResponseWriter, r *http.Request) {
+	constraints, err, respCode := GetConstraints(app)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(constraints)
+}
+
+// http handler to delete a seating constraint
+func (app *App) DeleteConstraintHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		sendErrorResponse(w, r, newError(ErrInvalidConstraintId, "Invalid constraint-id"), http.StatusBadRequest)
+		return
+	}
+	err, respCode := DeleteConstraint(app, id)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// http handler to queue a guest party for a table
+func (app *App) AddToWaitlistHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	var entry WaitlistEntry
+	err = json.Unmarshal(body, &entry)
+	if err != nil {
+		sendErrorResponse(w, r, newError(ErrInvalidJSON, "Invalid JSON body: %v", err), http.StatusBadRequest)
+		return
+	}
+	entry.Name = models.DisplayName(entry.Name)
+	details, err := validateWaitlistEntry(entry)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if details != nil {
+		sendErrorResponse(w, r, validationError(details), http.StatusBadRequest)
+		return
+	}
+	entry, err, respCode := AddToWaitlist(app, entry)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entry)
+}
+
+// http handler to get the waitlist
+func (app *App) GetWaitlistHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err, respCode := GetWaitlist(app)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entries)
+}
+
+// http handler to take a party off the waitlist
+func (app *App) RemoveFromWaitlistHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		sendErrorResponse(w, r, newError(ErrInvalidWaitlistId, "Invalid waitlist-id"), http.StatusBadRequest)
+		return
+	}
+	err, respCode := RemoveFromWaitlist(app, id)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// http handler to add the guests of a seating proposal to the guest list
+func (app *App) CommitSeatingPlanHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	var proposal SeatingProposal
+	err = json.Unmarshal(body, &proposal)
+	if err != nil {
+		sendErrorResponse(w, r, newError(ErrInvalidJSON, "Invalid JSON body: %v", err), http.StatusBadRequest)
+		return
+	}
+	report, err, respCode := CommitSeatingPlan(app, proposal)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// event id from the request path
+func eventId(r *http.Request) (int64, error) {
+	params := mux.Vars(r)
+	id, err := strconv.ParseInt(params["event_id"], 10, 64)
+	if err != nil || id <= 0 {
+		return 0, newError(ErrInvalidEventId, "Invalid event-id")
+	}
+	return id, nil
+}
+
+// DefaultEvent adapts handler h to serve the tables and guests of the
+// default event
+func (app *App) DefaultEvent(h func(*App, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h(app, w, r)
+	}
+}
+
+// Event adapts handler h to serve the tables and guests of the event in the
+// event_id path variable
+func (app *App) Event(h func(*App, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := eventId(r)
+		if err != nil {
+			sendErrorResponse(w, r, err, http.StatusBadRequest)
+			return
+		}
+		exists, err := app.Party.DbCheckEventExists(id)
+		if err != nil {
+			sendErrorResponse(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		if exists == 0 {
+			sendErrorResponse(w, r, newError(ErrEventNotFound, "Event %d not found", id), http.StatusNotFound)
+			return
+		}
+		eventApp := *app
+		eventApp.Party = app.Party.DbForEvent(id)
+		h(&eventApp, w, r)
+	}
+}
+
+// http handler to add an event
+func (app *App) AddEventHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	var event Event
+	err = json.Unmarshal(body, &event)
+	if err != nil {
+		sendErrorResponse(w, r, newError(ErrInvalidJSON, "Invalid JSON body: %v", err), http.StatusBadRequest)
+		return
+	}
+	details, err := validateEvent(event)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if details != nil {
+		sendErrorResponse(w, r, validationError(details), http.StatusBadRequest)
+		return
+	}
+	event, err, respCode := AddEvent(app, event)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(event)
+}
+
+// http handler to get all events
+func (app *App) GetEventsHandler(w http.ResponseWriter, r *http.Request) {
+	events, err, respCode := GetEvents(app)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(events)
+}
+
+// http handler to get an event and its statistics
+func (app *App) GetEventHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := eventId(r)
+	if err != nil {
+		sendErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+	event, err, respCode := GetEvent(app, id)
+	if err != nil {
+		sendErrorResponse(w, r, err, respCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(event)
+}
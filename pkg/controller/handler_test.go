@@ -1,203 +1,92 @@
 package controller
 
 import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
 	models "github.com/getground/tech-tasks/backend/pkg/models"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
-)
-
-type mockPartyModel struct{}
-
-var guests []models.Guests
-var tables []models.Table
-
-func addCheckedOutGuest() {
-	guests = append(guests, models.Guests{3, 3, "checked-out", time.Time{}, "jack"})
-}
-
-func addGuests() {
-	guests = append(guests, models.Guests{1, 1, "allotted", time.Time{}, "john"})
-	guests = append(guests, models.Guests{2, 2, "checked-in", time.Time{}, "akhila"})
-}
-
-func addSingleGuest() {
-	guests = append(guests, models.Guests{1, 1, "allotted", time.Time{}, "john"})
-}
-
-func addGuest(id int64, accGuest int64,
-	status string, arrTime time.Time, name string) {
-	guests = append(guests, models.Guests{id, accGuest,
-		status, arrTime, name})
-}
-
-func addTables(count int64) {
-	var start int64 = 1
-	for i := start; i <= count; i++ {
-		tables = append(tables, models.Table{i, i + 2})
-	}
-}
-
-func cleanup() {
-	guests = nil
-	tables = nil
-}
-
-func (*mockPartyModel) DbGetTableIdOfGuest(name string) (int64, error) {
-	var id int64
-	for _, guest := range guests {
-		if guest.Name == name {
-			id = guest.Table
-			break
-		}
-	}
-	return id, nil
-}
-
-func (*mockPartyModel) DbGetGuestStatus(name string) (string, error) {
-	var status string
-	for _, guest := range guests {
-		if guest.Name == name {
-			status = guest.Status
-			break
-		}
-	}
-	return status, nil
-}
-
-func (*mockPartyModel) DbAddTable(int64) (int64, error) {
-	var count int64 = 1
-	var id int64 = 1
-	addTables(count)
-	return id, nil
-}
 
-func (*mockPartyModel) DbCheckTableExists(id int64) (int64, error) {
-	var exists int64
-	for _, table := range tables {
-		if table.Id == id {
-			exists = 1
-			break
-		}
-	}
-	return exists, nil
-}
+	db "github.com/getground/tech-tasks/backend/pkg/db"
+	memstore "github.com/getground/tech-tasks/backend/pkg/memstore"
+	migrate "github.com/getground/tech-tasks/backend/pkg/migrate"
+)
 
-func (*mockPartyModel) DbGetGuestInTable(id int64) (string, error) {
-	var name string
-	for _, guest := range guests {
-		if guest.Table == id {
-			name = guest.Name
-			break
-		}
-	}
-	return name, nil
-}
+// time recorded by the store when a guest checks in
+var arrivalTime = time.Date(2022, time.December, 20, 8, 10, 43, 0, time.UTC)
 
-func (*mockPartyModel) DbAddGuestList(guest models.Guests) error {
-	guests = append(guests, models.Guests{guest.Table, guest.AccompanyingGuests,
-		"allotted", time.Time{}, guest.Name})
-	return nil
-}
+var store = newStore()
 
-func (*mockPartyModel) DbUpdateGuestStatus(name string, status string) error {
-	for i, guest := range guests {
-		if guest.Name == name {
-			guests[i].Status = models.CHECKEDOUT
-			break
-		}
-	}
-	return nil
+func newStore() *memstore.PartyStore {
+	s := memstore.New()
+	s.Now = func() time.Time { return arrivalTime }
+	return s
 }
 
-func (*mockPartyModel) DbUpdateGuestList(guest models.Guests) error {
-	for i, g := range guests {
-		if g.Name == guest.Name {
-			guests[i].Status = guest.Status
-			guests[i].AccompanyingGuests = guest.AccompanyingGuests
-			guests[i].TimeArrived = guest.TimeArrived
-			break
-		}
+func must(err error) {
+	if err != nil {
+		panic(err)
 	}
-	return nil
 }
 
-func (*mockPartyModel) DbGetCapacitySum() (int64, error) {
-	var sum int64
-	for _, table := range tables {
-		sum += table.Capacity
-	}
-	return sum, nil
+func addCheckedOutGuest() {
+	addGuest(3, 3, "checked-out", "jack")
 }
 
-func (*mockPartyModel) DbGetAccompanyingGuestsSum(status string) (int64, int64, error) {
-	var sum, count int64
-	for _, g := range guests {
-		if g.Status == status {
-			sum += g.AccompanyingGuests
-			count++
-		}
-	}
-	return sum, count, nil
+func addGuests() {
+	addGuest(1, 1, "allotted", "john")
+	addGuest(2, 2, "checked-in", "akhila")
 }
 
-func (*mockPartyModel) DbGetGuestList() ([]models.Guests, error) {
-	return guests, nil
+func addSingleGuest() {
+	addGuest(1, 1, "allotted", "john")
 }
 
-func (*mockPartyModel) DbGetArrivedGuests() ([]models.Guests, error) {
-	var aguests []models.Guests
-	for _, g := range guests {
-		if g.Status == models.CHECKEDIN {
-			aguests = append(aguests, g)
-		}
+// adds a guest to table id and returns the id of the guest
+func addGuest(id int64, accGuest int64, status string, name string) int64 {
+	guest, err := store.DbAddGuestList(models.Guests{Table: id, AccompanyingGuests: accGuest, Name: name})
+	must(err)
+	// guests leave after they arrived
+	if status == models.CHECKEDOUT {
+		must(store.DbUpdateGuestStatus(guest, models.CHECKEDIN))
 	}
-	return aguests, nil
-}
-
-func (*mockPartyModel) DbGetTableCapacity(id int64) (int64, error) {
-	var capacity int64
-	for _, table := range tables {
-		if table.Id == id {
-			capacity = table.Capacity
-			break
-		}
+	if status != models.ALLOTTED {
+		must(store.DbUpdateGuestStatus(guest, status))
 	}
-	return capacity, nil
+	return guest
 }
 
-func (*mockPartyModel) DbCheckGuestExists(name string) (int64, error) {
-	var exists int64
-	for _, guest := range guests {
-		if guest.Name == name {
-			exists = 1
-			break
-		}
-	}
-	return exists, nil
+// id of the only guest named name
+func guestId(name string) int64 {
+	guests, err := store.DbFindGuests(name)
+	must(err)
+	return guests[0].Id
 }
 
-func (*mockPartyModel) DbIsTablesEmpty() (bool, error) {
-	if len(tables) > 1 {
-		return false, nil
+func addTables(count int64) {
+	var start int64 = 1
+	for i := start; i <= count; i++ {
+		_, err := store.DbAddTable(i + 2)
+		must(err)
 	}
-	return true, nil
 }
 
-func (*mockPartyModel) DbIsGuestsEmpty(string) (bool, error) {
-	if len(guests) > 1 {
-		return false, nil
-	}
-	return true, nil
+func cleanup() {
+	store = newStore()
 }
 
 func TestAddTableHandler(t *testing.T) {
 	var emptyTable, testTable []models.Table
-	testTable = append(testTable, models.Table{1, 3})
+	testTable = append(testTable, models.Table{Id: 1, Capacity: 10})
 
 	tt := []struct {
 		name       string
@@ -212,7 +101,7 @@ func TestAddTableHandler(t *testing.T) {
 			method:     http.MethodPost,
 			body:       `{"capacity": 0}`,
 			expTable:   emptyTable,
-			want:       `must be greater than 0`,
+			want:       `{"code":"validation_failed","message":"capacity must be greater than 0","details":[{"field":"capacity","message":"capacity must be greater than 0"}]}`,
 			statusCode: http.StatusBadRequest,
 		},
 		{
@@ -220,7 +109,7 @@ func TestAddTableHandler(t *testing.T) {
 			method:     http.MethodPost,
 			body:       `{"capacity": -1}`,
 			expTable:   emptyTable,
-			want:       `must be greater than 0`,
+			want:       `{"code":"validation_failed","message":"capacity must be greater than 0","details":[{"field":"capacity","message":"capacity must be greater than 0"}]}`,
 			statusCode: http.StatusBadRequest,
 		},
 		{
@@ -228,7 +117,7 @@ func TestAddTableHandler(t *testing.T) {
 			method:     http.MethodPost,
 			body:       `{"capacity": 99999999999999999}`,
 			expTable:   emptyTable,
-			want:       `must be less than 4,294,967,295`,
+			want:       `{"code":"validation_failed","message":"capacity must be less than 4,294,967,295","details":[{"field":"capacity","message":"capacity must be less than 4,294,967,295"}]}`,
 			statusCode: http.StatusBadRequest,
 		},
 		{
@@ -247,8 +136,7 @@ func TestAddTableHandler(t *testing.T) {
 			request := httptest.NewRequest(tc.method, "/tables", strings.NewReader(tc.body))
 			responseRecorder := httptest.NewRecorder()
 
-			app := App{Party: &mockPartyModel{}}
-
+			app := App{Party: store}
 			handler := http.HandlerFunc(app.AddTableHandler)
 			handler.ServeHTTP(responseRecorder, request)
 
@@ -260,7 +148,7 @@ func TestAddTableHandler(t *testing.T) {
 				t.Errorf("Want '%s', got '%s'", tc.want, responseRecorder.Body)
 			}
 
-			assert.Equal(t, tc.expTable, tables, "both should be equal")
+			assert.Equal(t, tc.expTable, store.Tables(), "both should be equal")
 		})
 	}
 }
@@ -268,10 +156,10 @@ func TestAddTableHandler(t *testing.T) {
 func TestAddGuestListHandler(t *testing.T) {
 	var testGuests1, testGuests2 []models.Guests
 
-	testGuests1 = append(testGuests1, models.Guests{1, 1, "allotted", time.Time{}, "john"})
+	testGuests1 = append(testGuests1, models.Guests{Id: 1, Table: 1, AccompanyingGuests: 1, Status: "allotted", TimeArrived: time.Time{}, Name: "john"})
 
 	testGuests2 = append(testGuests2, testGuests1...)
-	testGuests2 = append(testGuests2, models.Guests{2, 2, "allotted", time.Time{}, "akhila"})
+	testGuests2 = append(testGuests2, models.Guests{Id: 2, Table: 2, AccompanyingGuests: 2, Status: "allotted", TimeArrived: time.Time{}, Name: "akhila"})
 
 	tt := []struct {
 		name       string
@@ -288,7 +176,7 @@ func TestAddGuestListHandler(t *testing.T) {
 			guestName:  "akhila",
 			body:       `{"table": 3, "accompanying_guests": 10}`,
 			expGuests:  testGuests1,
-			want:       `Invalid table-id`,
+			want:       `{"code":"unknown_table","message":"Invalid table-id"}`,
 			statusCode: http.StatusBadRequest,
 		},
 		{
@@ -297,8 +185,8 @@ func TestAddGuestListHandler(t *testing.T) {
 			guestName:  "john",
 			body:       `{"table": 2, "accompanying_guests": 2}`,
 			expGuests:  testGuests1,
-			want:       `Guest john already added`,
-			statusCode: http.StatusBadRequest,
+			want:       `{"code":"guest_exists","message":"Guest john already added"}`,
+			statusCode: http.StatusConflict,
 		},
 		{
 			name:       "repeat name case sensitive",
@@ -306,8 +194,8 @@ func TestAddGuestListHandler(t *testing.T) {
 			guestName:  "JOHN",
 			body:       `{"table": 2, "accompanying_guests": 2}`,
 			expGuests:  testGuests1,
-			want:       `Guest john already added`,
-			statusCode: http.StatusBadRequest,
+			want:       `{"code":"guest_exists","message":"Guest JOHN already added"}`,
+			statusCode: http.StatusConflict,
 		},
 		{
 			name:       "valid input",
@@ -315,7 +203,7 @@ func TestAddGuestListHandler(t *testing.T) {
 			guestName:  "akhila",
 			body:       `{"table": 2, "accompanying_guests": 2}`,
 			expGuests:  testGuests2,
-			want:       `{"name":"akhila"}`,
+			want:       `{"id":2,"name":"akhila"}`,
 			statusCode: http.StatusOK,
 		},
 		{
@@ -324,7 +212,7 @@ func TestAddGuestListHandler(t *testing.T) {
 			guestName:  "akhila",
 			body:       `{"table": 2, "accompanying_guests": -1}`,
 			expGuests:  testGuests1,
-			want:       `AccompanyingGuests must be 0 or greater`,
+			want:       `{"code":"validation_failed","message":"accompanying_guests must be 0 or greater","details":[{"field":"accompanying_guests","message":"accompanying_guests must be 0 or greater"}]}`,
 			statusCode: http.StatusBadRequest,
 		},
 		{
@@ -333,8 +221,8 @@ func TestAddGuestListHandler(t *testing.T) {
 			guestName:  "akhila",
 			body:       `{"table": 1, "accompanying_guests": 10}`,
 			expGuests:  testGuests1,
-			want:       `Cannot allot table. Table capacity is 3`,
-			statusCode: http.StatusBadRequest,
+			want:       `{"code":"table_capacity_exceeded","message":"Cannot allot table. Table capacity is 3"}`,
+			statusCode: http.StatusConflict,
 		},
 		{
 			name:       "already allocated table",
@@ -342,13 +230,11 @@ func TestAddGuestListHandler(t *testing.T) {
 			guestName:  "akhila",
 			body:       `{"table": 1, "accompanying_guests": 0}`,
 			expGuests:  testGuests1,
-			want:       `Table already allotted to john`,
-			statusCode: http.StatusBadRequest,
+			want:       `{"code":"table_allotted","message":"Table already allotted to john"}`,
+			statusCode: http.StatusConflict,
 		},
 	}
 
-	app := App{Party: &mockPartyModel{}}
-
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			addTables(2)
@@ -359,6 +245,7 @@ func TestAddGuestListHandler(t *testing.T) {
 			param := map[string]string{"name": tc.guestName}
 			request = mux.SetURLVars(request, param)
 			responseRecorder := httptest.NewRecorder()
+			app := App{Party: store}
 			handler := http.HandlerFunc(app.AddGuestListHandler)
 			handler.ServeHTTP(responseRecorder, request)
 
@@ -370,17 +257,77 @@ func TestAddGuestListHandler(t *testing.T) {
 				t.Errorf("Want '%s', got '%s'", tc.want, responseRecorder.Body)
 			}
 
-			assert.Equal(t, tc.expGuests, guests, "both should be equal")
+			assert.Equal(t, tc.expGuests, store.Guests(), "both should be equal")
 		})
 	}
 }
 
+// party model of a new sqlite database with the schema migrated
+func newSQLiteModel(t *testing.T) models.PartyModel {
+	sqlDB, err := sql.Open(db.SQLite, db.SQLiteDSN(filepath.Join(t.TempDir(), "party.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	migrator, err := migrate.New(sqlDB, db.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return models.PartyModel{DB: sqlDB, Dialect: db.SQLite}
+}
+
+// concurrent allotments of a table run against the database so that the
+// table row lock taken by the transaction is what keeps them apart
+func TestAddGuestListHandlerConcurrent(t *testing.T) {
+	party := newSQLiteModel(t)
+	_, err := party.DbAddTable(1)
+	assert.Nil(t, err)
+
+	app := App{Party: party}
+	handler := http.HandlerFunc(app.AddGuestListHandler)
+
+	const requests = 20
+	codes := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			guestName := fmt.Sprintf("guest%d", i)
+			request := httptest.NewRequest(http.MethodPost, "/guest_list/"+guestName,
+				strings.NewReader(`{"table": 1, "accompanying_guests": 0}`))
+			request = mux.SetURLVars(request, map[string]string{"name": guestName})
+			responseRecorder := httptest.NewRecorder()
+			handler.ServeHTTP(responseRecorder, request)
+			codes <- responseRecorder.Code
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+
+	var allotted int
+	for code := range codes {
+		if code == http.StatusOK {
+			allotted++
+		} else {
+			assert.Equal(t, http.StatusConflict, code)
+		}
+	}
+	assert.Equal(t, 1, allotted, "only one request should allot the table")
+	guests, err := party.DbGetGuestList()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(guests), "only one guest should be added")
+}
+
 func TestUpateGuestHandler(t *testing.T) {
 	var testGuests1, testGuests2, testGuests3, testGuests4 []models.Guests
-	testGuests1 = append(testGuests1, models.Guests{1, 1, "allotted", time.Time{}, "john"})
-	testGuests2 = append(testGuests2, models.Guests{1, 1, "checked-in", time.Time{}, "john"})
-	testGuests3 = append(testGuests3, models.Guests{1, 0, "checked-in", time.Time{}, "john"})
-	testGuests4 = append(testGuests4, models.Guests{1, 2, "checked-in", time.Time{}, "john"})
+	testGuests1 = append(testGuests1, models.Guests{Id: 1, Table: 1, AccompanyingGuests: 1, Status: "allotted", TimeArrived: time.Time{}, Name: "john"})
+	testGuests2 = append(testGuests2, models.Guests{Id: 1, Table: 1, AccompanyingGuests: 1, Status: "checked-in", TimeArrived: arrivalTime, Name: "john"})
+	testGuests3 = append(testGuests3, models.Guests{Id: 1, Table: 1, AccompanyingGuests: 0, Status: "checked-in", TimeArrived: arrivalTime, Name: "john"})
+	testGuests4 = append(testGuests4, models.Guests{Id: 1, Table: 1, AccompanyingGuests: 2, Status: "checked-in", TimeArrived: arrivalTime, Name: "john"})
 
 	tt := []struct {
 		name       string
@@ -397,8 +344,8 @@ func TestUpateGuestHandler(t *testing.T) {
 			guestName:  "akhila",
 			body:       `{"accompanying_guests": 10}`,
 			expGuests:  testGuests1,
-			want:       `Guest akhila is not present in Guestlist`,
-			statusCode: http.StatusBadRequest,
+			want:       `{"code":"guest_not_found","message":"Guest akhila is not present in Guestlist"}`,
+			statusCode: http.StatusNotFound,
 		},
 		{
 			name:       "more acc guest than capacity",
@@ -406,8 +353,8 @@ func TestUpateGuestHandler(t *testing.T) {
 			guestName:  "john",
 			body:       `{"accompanying_guests": 4}`,
 			expGuests:  testGuests1,
-			want:       `Cannot update number of accompanying guests. Table capacity is 3`,
-			statusCode: http.StatusBadRequest,
+			want:       `{"code":"table_capacity_exceeded","message":"Cannot update number of accompanying guests. Table capacity is 3"}`,
+			statusCode: http.StatusConflict,
 		},
 		{
 			name:       "same acc guest as capacity",
@@ -415,8 +362,8 @@ func TestUpateGuestHandler(t *testing.T) {
 			guestName:  "john",
 			expGuests:  testGuests1,
 			body:       `{"accompanying_guests": 3}`,
-			want:       `Cannot update number of accompanying guests. Table capacity is 3`,
-			statusCode: http.StatusBadRequest,
+			want:       `{"code":"table_capacity_exceeded","message":"Cannot update number of accompanying guests. Table capacity is 3"}`,
+			statusCode: http.StatusConflict,
 		},
 		{
 			name:       "no update in acc guest",
@@ -424,7 +371,7 @@ func TestUpateGuestHandler(t *testing.T) {
 			guestName:  "john",
 			body:       `{"accompanying_guests": 1}`,
 			expGuests:  testGuests2,
-			want:       `{"name":"john"}`,
+			want:       `{"id":1,"name":"john"}`,
 			statusCode: http.StatusOK,
 		},
 		{
@@ -433,7 +380,7 @@ func TestUpateGuestHandler(t *testing.T) {
 			guestName:  "john",
 			body:       `{"accompanying_guests": 0}`,
 			expGuests:  testGuests3,
-			want:       `{"name":"john"}`,
+			want:       `{"id":1,"name":"john"}`,
 			statusCode: http.StatusOK,
 		},
 		{
@@ -442,13 +389,11 @@ func TestUpateGuestHandler(t *testing.T) {
 			guestName:  "john",
 			expGuests:  testGuests4,
 			body:       `{"accompanying_guests": 2}`,
-			want:       `{"name":"john"}`,
+			want:       `{"id":1,"name":"john"}`,
 			statusCode: http.StatusOK,
 		},
 	}
 
-	app := App{Party: &mockPartyModel{}}
-
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			addTables(2)
@@ -459,6 +404,7 @@ func TestUpateGuestHandler(t *testing.T) {
 			param := map[string]string{"name": tc.guestName}
 			request = mux.SetURLVars(request, param)
 			responseRecorder := httptest.NewRecorder()
+			app := App{Party: store}
 			handler := http.HandlerFunc(app.UpdateGuestHandler)
 			handler.ServeHTTP(responseRecorder, request)
 
@@ -470,7 +416,7 @@ func TestUpateGuestHandler(t *testing.T) {
 				t.Errorf("Want '%s', got '%s'", tc.want, responseRecorder.Body)
 			}
 
-			assert.Equal(t, tc.expGuests, guests, "both should be equal")
+			assert.Equal(t, tc.expGuests, store.Guests(), "both should be equal")
 		})
 	}
 }
@@ -478,14 +424,14 @@ func TestUpateGuestHandler(t *testing.T) {
 func TestDeleteGuestHandler(t *testing.T) {
 	var testGuests1, testGuests2, testGuests3 []models.Guests
 
-	testGuests1 = append(testGuests1, models.Guests{1, 1, "allotted", time.Time{}, "john"})
-	testGuests1 = append(testGuests1, models.Guests{2, 2, "checked-in", time.Time{}, "akhila"})
+	testGuests1 = append(testGuests1, models.Guests{Id: 1, Table: 1, AccompanyingGuests: 1, Status: "allotted", TimeArrived: time.Time{}, Name: "john"})
+	testGuests1 = append(testGuests1, models.Guests{Id: 2, Table: 2, AccompanyingGuests: 2, Status: "checked-in", TimeArrived: time.Time{}, Name: "akhila"})
 
-	testGuests2 = append(testGuests2, models.Guests{1, 1, "allotted", time.Time{}, "john"})
-	testGuests2 = append(testGuests2, models.Guests{2, 2, "checked-out", time.Time{}, "akhila"})
+	testGuests2 = append(testGuests2, models.Guests{Id: 1, Table: 1, AccompanyingGuests: 1, Status: "allotted", TimeArrived: time.Time{}, Name: "john"})
+	testGuests2 = append(testGuests2, models.Guests{Id: 2, Table: 2, AccompanyingGuests: 2, Status: "checked-out", TimeArrived: time.Time{}, TimeLeft: arrivalTime, Name: "akhila"})
 
 	testGuests3 = append(testGuests3, testGuests1...)
-	testGuests3 = append(testGuests3, models.Guests{3, 3, "checked-out", time.Time{}, "jack"})
+	testGuests3 = append(testGuests3, models.Guests{Id: 3, Table: 3, AccompanyingGuests: 3, Status: "checked-out", TimeArrived: time.Time{}, TimeLeft: arrivalTime, Name: "jack"})
 
 	tt := []struct {
 		name       string
@@ -502,8 +448,8 @@ func TestDeleteGuestHandler(t *testing.T) {
 			guestName:  "prasob",
 			checkout:   false,
 			expGuests:  testGuests1,
-			want:       `Guest prasob is not present in Guestlist`,
-			statusCode: http.StatusBadRequest,
+			want:       `{"code":"guest_not_found","message":"Guest prasob is not present in Guestlist"}`,
+			statusCode: http.StatusNotFound,
 		},
 		{
 			name:       "guest not arrived",
@@ -511,8 +457,8 @@ func TestDeleteGuestHandler(t *testing.T) {
 			guestName:  "john",
 			checkout:   false,
 			expGuests:  testGuests1,
-			want:       `Request failed, guest not checked-in`,
-			statusCode: http.StatusBadRequest,
+			want:       `{"code":"guest_not_checked_in","message":"Request failed, guest not checked-in","extra":{"transition":{"from":"allotted","to":"checked-out"}}}`,
+			statusCode: http.StatusConflict,
 		},
 		{
 			name:       "guest already checked-out",
@@ -520,8 +466,17 @@ func TestDeleteGuestHandler(t *testing.T) {
 			guestName:  "john",
 			checkout:   true,
 			expGuests:  testGuests3,
-			want:       `Request failed, guest not checked-in`,
-			statusCode: http.StatusBadRequest,
+			want:       `{"code":"guest_not_checked_in","message":"Request failed, guest not checked-in","extra":{"transition":{"from":"allotted","to":"checked-out"}}}`,
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "checked-out guest",
+			method:     http.MethodDelete,
+			guestName:  "jack",
+			checkout:   true,
+			expGuests:  testGuests3,
+			want:       `{"code":"guest_checked_out","message":"Request failed, guest already checked-out","extra":{"transition":{"from":"checked-out","to":"checked-out"}}}`,
+			statusCode: http.StatusConflict,
 		},
 		{
 			name:       "valid input",
@@ -534,11 +489,9 @@ func TestDeleteGuestHandler(t *testing.T) {
 		},
 	}
 
-	app := App{Party: &mockPartyModel{}}
-
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			addTables(2)
+			addTables(3)
 			addGuests()
 			if tc.checkout {
 				addCheckedOutGuest()
@@ -549,6 +502,7 @@ func TestDeleteGuestHandler(t *testing.T) {
 			param := map[string]string{"name": tc.guestName}
 			request = mux.SetURLVars(request, param)
 			responseRecorder := httptest.NewRecorder()
+			app := App{Party: store}
 			handler := http.HandlerFunc(app.DeleteGuestHandler)
 			handler.ServeHTTP(responseRecorder, request)
 
@@ -560,7 +514,7 @@ func TestDeleteGuestHandler(t *testing.T) {
 				t.Errorf("Want '%s', got '%s'", tc.want, responseRecorder.Body)
 			}
 
-			assert.Equal(t, tc.expGuests, guests, "both should be equal")
+			assert.Equal(t, tc.expGuests, store.Guests(), "both should be equal")
 		})
 	}
 }
@@ -584,13 +538,14 @@ func TestGetGuestListHandler(t *testing.T) {
 			name:       "Get guests",
 			method:     http.MethodGet,
 			addGuests:  true,
-			want:       `[{"table":1,"accompanying_guests":1,"name":"john"},{"table":2,"accompanying_guests":2,"name":"akhila"}]`,
+			want:       `[{"id":2,"table":2,"accompanying_guests":2,"name":"akhila","status":"checked-in"},{"id":1,"table":1,"accompanying_guests":1,"name":"john","status":"allotted"}]`,
 			statusCode: http.StatusOK,
 		},
 	}
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
+			addTables(2)
 			if tc.addGuests {
 				addGuests()
 			}
@@ -598,8 +553,7 @@ func TestGetGuestListHandler(t *testing.T) {
 			request := httptest.NewRequest(tc.method, "/guest_list", nil)
 			responseRecorder := httptest.NewRecorder()
 
-			app := App{Party: &mockPartyModel{}}
-
+			app := App{Party: store}
 			handler := http.HandlerFunc(app.GetGuestListHandler)
 			handler.ServeHTTP(responseRecorder, request)
 
@@ -633,25 +587,25 @@ func TestGetGuestsHandler(t *testing.T) {
 			name:       "Get arrived guests",
 			method:     http.MethodGet,
 			addGuests:  true,
-			want:       `[{"time_arrived":"0001-01-01T00:00:00Z","accompanying_guests":2,"name":"akhila"}]`,
+			want:       `[{"id":2,"time_arrived":"0001-01-01T00:00:00Z","accompanying_guests":2,"name":"akhila","present":true,"visits":[]},{"id":3,"time_arrived":"0001-01-01T00:00:00Z","time_left":"2022-12-20T08:10:43Z","accompanying_guests":3,"name":"jack","present":false,"visits":[]}]`,
 			statusCode: http.StatusOK,
 		},
 	}
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
+			addTables(3)
 			if tc.addGuests {
 				addGuests()
 				addCheckedOutGuest()
 			} else {
-				addCheckedOutGuest()
+				addSingleGuest()
 			}
 			defer cleanup()
 			request := httptest.NewRequest(tc.method, "/guests", nil)
 			responseRecorder := httptest.NewRecorder()
 
-			app := App{Party: &mockPartyModel{}}
-
+			app := App{Party: store}
 			handler := http.HandlerFunc(app.GetGuestsHandler)
 			handler.ServeHTTP(responseRecorder, request)
 
@@ -678,14 +632,14 @@ func TestEmptySeatsHandler(t *testing.T) {
 			name:       "Empty tables",
 			method:     http.MethodGet,
 			empty:      true,
-			want:       `{"seats_empty":0}`,
+			want:       `{"seats_empty":0,"capacity":0,"seats_reserved":0,"seats_occupied":0,"tables":[],"guests":{"allotted":{"parties":0,"seats":0},"checked_in":{"parties":0,"seats":0},"checked_out":{"parties":0,"seats":0},"no_show":{"parties":0,"seats":0},"cancelled":{"parties":0,"seats":0}}}`,
 			statusCode: http.StatusOK,
 		},
 		{
 			name:       "Get arrived guests",
 			method:     http.MethodGet,
 			empty:      false,
-			want:       `{"seats_empty":9}`,
+			want:       `{"seats_empty":11,"capacity":18,"seats_reserved":2,"seats_occupied":3,"tables":[{"id":1,"capacity":3,"seats_reserved":2,"seats_occupied":0,"seats_free":0},{"id":2,"capacity":4,"seats_reserved":0,"seats_occupied":3,"seats_free":0},{"id":3,"capacity":5,"seats_reserved":0,"seats_occupied":0,"seats_free":5},{"id":4,"capacity":6,"seats_reserved":0,"seats_occupied":0,"seats_free":6}],"guests":{"allotted":{"parties":1,"seats":2},"checked_in":{"parties":1,"seats":3},"checked_out":{"parties":1,"seats":4},"no_show":{"parties":0,"seats":0},"cancelled":{"parties":0,"seats":0}}}`,
 			statusCode: http.StatusOK,
 		},
 	}
@@ -693,7 +647,7 @@ func TestEmptySeatsHandler(t *testing.T) {
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			if !tc.empty {
-				addTables(3)
+				addTables(4)
 				addGuests()
 				addCheckedOutGuest()
 			}
@@ -701,8 +655,7 @@ func TestEmptySeatsHandler(t *testing.T) {
 			request := httptest.NewRequest(tc.method, "/seats_empty", nil)
 			responseRecorder := httptest.NewRecorder()
 
-			app := App{Party: &mockPartyModel{}}
-
+			app := App{Party: store}
 			handler := http.HandlerFunc(app.GetEmptySeatsHandler)
 			handler.ServeHTTP(responseRecorder, request)
 
@@ -716,3 +669,1786 @@ func TestEmptySeatsHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestGetTablesHandler(t *testing.T) {
+	tt := []struct {
+		name       string
+		method     string
+		addTables  bool
+		want       string
+		statusCode int
+	}{
+		{
+			name:       "Empty tables",
+			method:     http.MethodGet,
+			addTables:  false,
+			want:       `[]`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "Get tables",
+			method:     http.MethodGet,
+			addTables:  true,
+			want:       `[{"id":1,"capacity":3,"seats_allotted":2,"seats_occupied":0,"guests":[{"id":1,"name":"john","accompanying_guests":1,"status":"allotted"}]},{"id":2,"capacity":4,"seats_allotted":3,"seats_occupied":3,"guests":[{"id":2,"name":"akhila","accompanying_guests":2,"status":"checked-in"}]},{"id":3,"capacity":5,"seats_allotted":0,"seats_occupied":0,"guests":[{"id":3,"name":"jack","accompanying_guests":3,"status":"checked-out"}]}]`,
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.addTables {
+				addTables(3)
+				addGuests()
+				addCheckedOutGuest()
+			}
+			defer cleanup()
+			request := httptest.NewRequest(tc.method, "/tables", nil)
+			responseRecorder := httptest.NewRecorder()
+
+			app := App{Party: store}
+			handler := http.HandlerFunc(app.GetTablesHandler)
+			handler.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != tc.statusCode {
+				t.Errorf("Want status '%d', got '%d'", tc.statusCode, responseRecorder.Code)
+			}
+
+			if strings.TrimSpace(responseRecorder.Body.String()) != tc.want {
+				t.Errorf("Want '%s', got '%s'", tc.want, responseRecorder.Body)
+			}
+		})
+	}
+}
+
+func TestGetTableHandler(t *testing.T) {
+	tt := []struct {
+		name       string
+		method     string
+		tableId    string
+		want       string
+		statusCode int
+	}{
+		{
+			name:       "invalid table-id",
+			method:     http.MethodGet,
+			tableId:    "one",
+			want:       `{"code":"invalid_table_id","message":"Invalid table-id"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown table",
+			method:     http.MethodGet,
+			tableId:    "5",
+			want:       `{"code":"table_not_found","message":"Table 5 not found"}`,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "allotted table",
+			method:     http.MethodGet,
+			tableId:    "1",
+			want:       `{"id":1,"capacity":3,"seats_allotted":2,"seats_occupied":0,"guests":[{"id":1,"name":"john","accompanying_guests":1,"status":"allotted"}]}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "free table",
+			method:     http.MethodGet,
+			tableId:    "2",
+			want:       `{"id":2,"capacity":4,"seats_allotted":0,"seats_occupied":0,"guests":[]}`,
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			addTables(2)
+			addSingleGuest()
+			defer cleanup()
+			requrl := "/tables/" + tc.tableId
+			request := httptest.NewRequest(tc.method, requrl, nil)
+			param := map[string]string{"id": tc.tableId}
+			request = mux.SetURLVars(request, param)
+			responseRecorder := httptest.NewRecorder()
+			app := App{Party: store}
+			handler := http.HandlerFunc(app.GetTableHandler)
+			handler.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != tc.statusCode {
+				t.Errorf("Want status '%d', got '%d'", tc.statusCode, responseRecorder.Code)
+			}
+
+			if strings.TrimSpace(responseRecorder.Body.String()) != tc.want {
+				t.Errorf("Want '%s', got '%s'", tc.want, responseRecorder.Body)
+			}
+		})
+	}
+}
+
+func TestUpdateTableHandler(t *testing.T) {
+	testTables1 := []models.Table{{Id: 1, Capacity: 3}, {Id: 2, Capacity: 4}}
+	testTables2 := []models.Table{{Id: 1, Capacity: 2}, {Id: 2, Capacity: 4}}
+
+	tt := []struct {
+		name       string
+		method     string
+		tableId    string
+		body       string
+		expTables  []models.Table
+		want       string
+		statusCode int
+	}{
+		{
+			name:       "unknown table",
+			method:     http.MethodPatch,
+			tableId:    "5",
+			body:       `{"capacity": 10}`,
+			expTables:  testTables1,
+			want:       `{"code":"table_not_found","message":"Table 5 not found"}`,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "invalid capacity",
+			method:     http.MethodPatch,
+			tableId:    "1",
+			body:       `{"capacity": 0}`,
+			expTables:  testTables1,
+			want:       `{"code":"validation_failed","message":"capacity must be greater than 0","details":[{"field":"capacity","message":"capacity must be greater than 0"}]}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "capacity below allotted seats",
+			method:     http.MethodPatch,
+			tableId:    "1",
+			body:       `{"capacity": 1}`,
+			expTables:  testTables1,
+			want:       `{"code":"table_in_use","message":"Cannot resize table. 2 seats are allotted"}`,
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "capacity equal to allotted seats",
+			method:     http.MethodPatch,
+			tableId:    "1",
+			body:       `{"capacity": 2}`,
+			expTables:  testTables2,
+			want:       `{"id":1,"capacity":2,"seats_allotted":2,"seats_occupied":0,"guests":[{"id":1,"name":"john","accompanying_guests":1,"status":"allotted"}]}`,
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			addTables(2)
+			addSingleGuest()
+			defer cleanup()
+			requrl := "/tables/" + tc.tableId
+			request := httptest.NewRequest(tc.method, requrl, strings.NewReader(tc.body))
+			param := map[string]string{"id": tc.tableId}
+			request = mux.SetURLVars(request, param)
+			responseRecorder := httptest.NewRecorder()
+			app := App{Party: store}
+			handler := http.HandlerFunc(app.UpdateTableHandler)
+			handler.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != tc.statusCode {
+				t.Errorf("Want status '%d', got '%d'", tc.statusCode, responseRecorder.Code)
+			}
+
+			if strings.TrimSpace(responseRecorder.Body.String()) != tc.want {
+				t.Errorf("Want '%s', got '%s'", tc.want, responseRecorder.Body)
+			}
+
+			assert.Equal(t, tc.expTables, store.Tables(), "both should be equal")
+		})
+	}
+}
+
+func TestDeleteTableHandler(t *testing.T) {
+	testTables1 := []models.Table{{Id: 1, Capacity: 3}, {Id: 2, Capacity: 4}}
+	testTables2 := []models.Table{{Id: 1, Capacity: 3}}
+
+	tt := []struct {
+		name       string
+		method     string
+		tableId    string
+		expTables  []models.Table
+		want       string
+		statusCode int
+	}{
+		{
+			name:       "unknown table",
+			method:     http.MethodDelete,
+			tableId:    "5",
+			expTables:  testTables1,
+			want:       `{"code":"table_not_found","message":"Table 5 not found"}`,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "allotted table",
+			method:     http.MethodDelete,
+			tableId:    "1",
+			expTables:  testTables1,
+			want:       `{"code":"table_in_use","message":"Cannot delete table. Table is allotted to john"}`,
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "free table",
+			method:     http.MethodDelete,
+			tableId:    "2",
+			expTables:  testTables2,
+			want:       ``,
+			statusCode: http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			addTables(2)
+			addSingleGuest()
+			defer cleanup()
+			requrl := "/tables/" + tc.tableId
+			request := httptest.NewRequest(tc.method, requrl, nil)
+			param := map[string]string{"id": tc.tableId}
+			request = mux.SetURLVars(request, param)
+			responseRecorder := httptest.NewRecorder()
+			app := App{Party: store}
+			handler := http.HandlerFunc(app.DeleteTableHandler)
+			handler.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != tc.statusCode {
+				t.Errorf("Want status '%d', got '%d'", tc.statusCode, responseRecorder.Code)
+			}
+
+			if strings.TrimSpace(responseRecorder.Body.String()) != tc.want {
+				t.Errorf("Want '%s', got '%s'", tc.want, responseRecorder.Body)
+			}
+
+			assert.Equal(t, tc.expTables, store.Tables(), "both should be equal")
+		})
+	}
+}
+
+func TestEditGuestListHandler(t *testing.T) {
+	var testGuests1, testGuests2, testGuests3 []models.Guests
+	testGuests1 = append(testGuests1, models.Guests{Id: 1, Table: 1, AccompanyingGuests: 1, Status: "allotted", Name: "john"})
+	testGuests1 = append(testGuests1, models.Guests{Id: 2, Table: 2, AccompanyingGuests: 2, Status: "checked-in", Name: "akhila"})
+
+	testGuests2 = append(testGuests2, models.Guests{Id: 1, Table: 3, AccompanyingGuests: 4, Status: "allotted", Name: "john"})
+	testGuests2 = append(testGuests2, testGuests1[1])
+
+	testGuests3 = append(testGuests3, models.Guests{Id: 1, Table: 1, AccompanyingGuests: 2, Status: "allotted", Name: "john"})
+	testGuests3 = append(testGuests3, testGuests1[1])
+
+	tt := []struct {
+		name       string
+		method     string
+		guestName  string
+		body       string
+		expGuests  []models.Guests
+		want       string
+		statusCode int
+	}{
+		{
+			name:       "invalid guest",
+			method:     http.MethodPatch,
+			guestName:  "prasob",
+			body:       `{"table": 3}`,
+			expGuests:  testGuests1,
+			want:       `{"code":"guest_not_found","message":"Guest prasob is not present in Guestlist"}`,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "guest already arrived",
+			method:     http.MethodPatch,
+			guestName:  "akhila",
+			body:       `{"table": 3}`,
+			expGuests:  testGuests1,
+			want:       `{"code":"guest_arrived","message":"Request failed, guest already checked-in"}`,
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "nothing to update",
+			method:     http.MethodPatch,
+			guestName:  "john",
+			body:       `{}`,
+			expGuests:  testGuests1,
+			want:       `{"code":"validation_failed","message":"table, accompanying_guests, companions or status is required","details":[{"field":"table","message":"table, accompanying_guests, companions or status is required"}]}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "negative accompanying guests",
+			method:     http.MethodPatch,
+			guestName:  "john",
+			body:       `{"accompanying_guests": -1}`,
+			expGuests:  testGuests1,
+			want:       `{"code":"validation_failed","message":"accompanying_guests must be 0 or greater","details":[{"field":"accompanying_guests","message":"accompanying_guests must be 0 or greater"}]}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid table",
+			method:     http.MethodPatch,
+			guestName:  "john",
+			body:       `{"table": 9}`,
+			expGuests:  testGuests1,
+			want:       `{"code":"unknown_table","message":"Invalid table-id"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "already allocated table",
+			method:     http.MethodPatch,
+			guestName:  "john",
+			body:       `{"table": 2}`,
+			expGuests:  testGuests1,
+			want:       `{"code":"table_allotted","message":"Table already allotted to akhila"}`,
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "more accompanying guests than capacity",
+			method:     http.MethodPatch,
+			guestName:  "john",
+			body:       `{"accompanying_guests": 3}`,
+			expGuests:  testGuests1,
+			want:       `{"code":"table_capacity_exceeded","message":"Cannot allot table. Table capacity is 3"}`,
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "move to another table",
+			method:     http.MethodPatch,
+			guestName:  "john",
+			body:       `{"table": 3, "accompanying_guests": 4}`,
+			expGuests:  testGuests2,
+			want:       `{"id":1,"table":3,"accompanying_guests":4,"name":"john"}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "change accompanying guests",
+			method:     http.MethodPatch,
+			guestName:  "john",
+			body:       `{"accompanying_guests": 2}`,
+			expGuests:  testGuests3,
+			want:       `{"id":1,"table":1,"accompanying_guests":2,"name":"john"}`,
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			addTables(3)
+			addGuests()
+			defer cleanup()
+			requrl := "/guest_list/" + tc.guestName
+			request := httptest.NewRequest(tc.method, requrl, strings.NewReader(tc.body))
+			param := map[string]string{"name": tc.guestName}
+			request = mux.SetURLVars(request, param)
+			responseRecorder := httptest.NewRecorder()
+			app := App{Party: store}
+			handler := http.HandlerFunc(app.EditGuestListHandler)
+			handler.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != tc.statusCode {
+				t.Errorf("Want status '%d', got '%d'", tc.statusCode, responseRecorder.Code)
+			}
+
+			if strings.TrimSpace(responseRecorder.Body.String()) != tc.want {
+				t.Errorf("Want '%s', got '%s'", tc.want, responseRecorder.Body)
+			}
+
+			assert.Equal(t, tc.expGuests, store.Guests(), "both should be equal")
+		})
+	}
+}
+
+func TestRemoveGuestListHandler(t *testing.T) {
+	var testGuests1, testGuests2 []models.Guests
+	testGuests1 = append(testGuests1, models.Guests{Id: 1, Table: 1, AccompanyingGuests: 1, Status: "allotted", Name: "john"})
+	testGuests1 = append(testGuests1, models.Guests{Id: 2, Table: 2, AccompanyingGuests: 2, Status: "checked-in", Name: "akhila"})
+	testGuests2 = append(testGuests2, testGuests1[1])
+
+	tt := []struct {
+		name       string
+		method     string
+		guestName  string
+		expGuests  []models.Guests
+		want       string
+		statusCode int
+	}{
+		{
+			name:       "invalid guest",
+			method:     http.MethodDelete,
+			guestName:  "prasob",
+			expGuests:  testGuests1,
+			want:       `{"code":"guest_not_found","message":"Guest prasob is not present in Guestlist"}`,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "guest already arrived",
+			method:     http.MethodDelete,
+			guestName:  "akhila",
+			expGuests:  testGuests1,
+			want:       `{"code":"guest_arrived","message":"Request failed, guest already checked-in"}`,
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "valid input",
+			method:     http.MethodDelete,
+			guestName:  "john",
+			expGuests:  testGuests2,
+			want:       ``,
+			statusCode: http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			addTables(2)
+			addGuests()
+			defer cleanup()
+			requrl := "/guest_list/" + tc.guestName
+			request := httptest.NewRequest(tc.method, requrl, nil)
+			param := map[string]string{"name": tc.guestName}
+			request = mux.SetURLVars(request, param)
+			responseRecorder := httptest.NewRecorder()
+			app := App{Party: store}
+			handler := http.HandlerFunc(app.RemoveGuestListHandler)
+			handler.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != tc.statusCode {
+				t.Errorf("Want status '%d', got '%d'", tc.statusCode, responseRecorder.Code)
+			}
+
+			if strings.TrimSpace(responseRecorder.Body.String()) != tc.want {
+				t.Errorf("Want '%s', got '%s'", tc.want, responseRecorder.Body)
+			}
+
+			assert.Equal(t, tc.expGuests, store.Guests(), "both should be equal")
+		})
+	}
+
+	// guests who will not come are removed to free their table
+	addTables(2)
+	addGuest(1, 1, models.CANCELLED, "john")
+	addGuest(2, 2, models.NOSHOW, "akhila")
+	defer cleanup()
+	app := App{Party: store}
+	for _, name := range []string{"john", "akhila"} {
+		request := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/guest_list/"+name, nil), map[string]string{"name": name})
+		responseRecorder := httptest.NewRecorder()
+		http.HandlerFunc(app.RemoveGuestListHandler).ServeHTTP(responseRecorder, request)
+		assert.Equal(t, http.StatusNoContent, responseRecorder.Code, name)
+	}
+	assert.Nil(t, store.Guests())
+}
+
+func TestErrorResponse(t *testing.T) {
+	app := App{Party: store}
+
+	tt := []struct {
+		name       string
+		handler    http.HandlerFunc
+		method     string
+		url        string
+		body       string
+		requestId  string
+		want       string
+		statusCode int
+	}{
+		{
+			name:       "invalid json",
+			handler:    app.AddTableHandler,
+			method:     http.MethodPost,
+			url:        "/tables",
+			body:       `{"capacity":`,
+			requestId:  "req-1",
+			want:       `{"code":"invalid_json","message":"Invalid JSON body: unexpected end of JSON input","request_id":"req-1"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown route",
+			handler:    NotFoundHandler,
+			method:     http.MethodGet,
+			url:        "/chairs",
+			requestId:  "req-2",
+			want:       `{"code":"not_found","message":"/chairs not found","request_id":"req-2"}`,
+			statusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			defer cleanup()
+			request := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			request.Header.Set("X-Request-Id", tc.requestId)
+			responseRecorder := httptest.NewRecorder()
+			handler := RequestIdMiddleware(tc.handler)
+			handler.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != tc.statusCode {
+				t.Errorf("Want status '%d', got '%d'", tc.statusCode, responseRecorder.Code)
+			}
+
+			if strings.TrimSpace(responseRecorder.Body.String()) != tc.want {
+				t.Errorf("Want '%s', got '%s'", tc.want, responseRecorder.Body)
+			}
+
+			assert.Equal(t, "application/json", responseRecorder.Header().Get("Content-Type"))
+			assert.Equal(t, tc.requestId, responseRecorder.Header().Get("X-Request-Id"))
+		})
+	}
+}
+
+func TestAddEventHandler(t *testing.T) {
+	tt := []struct {
+		name       string
+		method     string
+		body       string
+		want       string
+		statusCode int
+	}{
+		{
+			name:       "add event",
+			method:     http.MethodPost,
+			body:       `{"name":"wedding","date":"2023-06-01","venue":"garden"}`,
+			want:       `{"id":2,"name":"wedding","date":"2023-06-01","venue":"garden"}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "missing name",
+			method:     http.MethodPost,
+			body:       `{"venue":"garden"}`,
+			want:       `{"code":"validation_failed","message":"name is a required field","details":[{"field":"name","message":"name is a required field"}]}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid date",
+			method:     http.MethodPost,
+			body:       `{"name":"wedding","date":"01/06/2023"}`,
+			want:       `{"code":"validation_failed","message":"date must be a date in the format YYYY-MM-DD","details":[{"field":"date","message":"date must be a date in the format YYYY-MM-DD"}]}`,
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			defer cleanup()
+			request := httptest.NewRequest(tc.method, "/events", strings.NewReader(tc.body))
+			responseRecorder := httptest.NewRecorder()
+			app := App{Party: store}
+			handler := http.HandlerFunc(app.AddEventHandler)
+			handler.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != tc.statusCode {
+				t.Errorf("Want status '%d', got '%d'", tc.statusCode, responseRecorder.Code)
+			}
+
+			if strings.TrimSpace(responseRecorder.Body.String()) != tc.want {
+				t.Errorf("Want '%s', got '%s'", tc.want, responseRecorder.Body)
+			}
+		})
+	}
+}
+
+func TestGetEventHandler(t *testing.T) {
+	tt := []struct {
+		name       string
+		eventId    string
+		want       string
+		statusCode int
+	}{
+		{
+			name:       "default event",
+			eventId:    "1",
+			want:       `{"id":1,"name":"Default","venue":"","statistics":{"tables":3,"capacity":12,"guests":3,"allotted":1,"checked_in":1,"checked_out":1,"no_show":0,"cancelled":0,"seats_empty":5}}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "empty event",
+			eventId:    "2",
+			want:       `{"id":2,"name":"wedding","venue":"","statistics":{"tables":0,"capacity":0,"guests":0,"allotted":0,"checked_in":0,"checked_out":0,"no_show":0,"cancelled":0,"seats_empty":0}}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "unknown event",
+			eventId:    "3",
+			want:       `{"code":"event_not_found","message":"Event 3 not found"}`,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "invalid event-id",
+			eventId:    "one",
+			want:       `{"code":"invalid_event_id","message":"Invalid event-id"}`,
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			addTables(3)
+			addGuests()
+			addCheckedOutGuest()
+			_, err := store.DbAddEvent(models.Event{Name: "wedding"})
+			must(err)
+			defer cleanup()
+			request := httptest.NewRequest(http.MethodGet, "/events/"+tc.eventId, nil)
+			request = mux.SetURLVars(request, map[string]string{"event_id": tc.eventId})
+			responseRecorder := httptest.NewRecorder()
+			app := App{Party: store}
+			handler := http.HandlerFunc(app.GetEventHandler)
+			handler.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Code != tc.statusCode {
+				t.Errorf("Want status '%d', got '%d'", tc.statusCode, responseRecorder.Code)
+			}
+
+			if strings.TrimSpace(responseRecorder.Body.String()) != tc.want {
+				t.Errorf("Want '%s', got '%s'", tc.want, responseRecorder.Body)
+			}
+		})
+	}
+}
+
+func TestEventScopedHandlers(t *testing.T) {
+	defer cleanup()
+	addTables(1)
+	addSingleGuest()
+	id, err := store.DbAddEvent(models.Event{Name: "wedding"})
+	must(err)
+	app := &App{Party: store}
+
+	serve := func(handler http.HandlerFunc, method string, url string, vars map[string]string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		request = mux.SetURLVars(request, vars)
+		responseRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, request)
+		return responseRecorder
+	}
+	event := map[string]string{"event_id": fmt.Sprint(id)}
+
+	resp := serve(app.Event((*App).AddTableHandler), http.MethodPost, "/events/2/tables", event, `{"capacity":4}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":2,"capacity":4}`, strings.TrimSpace(resp.Body.String()))
+
+	// the table of the default event is unknown in the wedding
+	resp = serve(app.Event((*App).AddGuestListHandler), http.MethodPost, "/events/2/guest_list/john",
+		map[string]string{"event_id": "2", "name": "john"}, `{"table":1}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// john is on the guest list of both events
+	resp = serve(app.Event((*App).AddGuestListHandler), http.MethodPost, "/events/2/guest_list/john",
+		map[string]string{"event_id": "2", "name": "john"}, `{"table":2,"accompanying_guests":3}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serve(app.Event((*App).GetGuestListHandler), http.MethodGet, "/events/2/guest_list", event, "")
+	assert.Equal(t, `[{"id":2,"table":2,"accompanying_guests":3,"name":"john","status":"allotted"}]`, strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.DefaultEvent((*App).GetGuestListHandler), http.MethodGet, "/guest_list", nil, "")
+	assert.Equal(t, `[{"id":1,"table":1,"accompanying_guests":1,"name":"john","status":"allotted"}]`, strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.Event((*App).GetTablesHandler), http.MethodGet, "/events/2/tables", event, "")
+	assert.Equal(t, `[{"id":2,"capacity":4,"seats_allotted":4,"seats_occupied":0,"guests":[{"id":2,"name":"john","accompanying_guests":3,"status":"allotted"}]}]`, strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.Event((*App).GetTablesHandler), http.MethodGet, "/events/3/tables", map[string]string{"event_id": "3"}, "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, `{"code":"event_not_found","message":"Event 3 not found"}`, strings.TrimSpace(resp.Body.String()))
+}
+
+func TestSharedTables(t *testing.T) {
+	defer cleanup()
+	_, err := store.DbAddTable(10)
+	must(err)
+	_, err = store.DbAddTable(2)
+	must(err)
+	app := &App{Party: store, SharedTables: true}
+
+	serve := func(handler http.HandlerFunc, method string, url string, vars map[string]string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		request = mux.SetURLVars(request, vars)
+		responseRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, request)
+		return responseRecorder
+	}
+	addGuestList := func(name string, body string) *httptest.ResponseRecorder {
+		return serve(app.AddGuestListHandler, http.MethodPost, "/guest_list/"+name, map[string]string{"name": name}, body)
+	}
+
+	assert.Equal(t, http.StatusOK, addGuestList("john", `{"table":1,"accompanying_guests":1}`).Code)
+	assert.Equal(t, http.StatusOK, addGuestList("akhila", `{"table":1,"accompanying_guests":4}`).Code)
+	resp := addGuestList("jack", `{"table":1,"accompanying_guests":3}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"table_capacity_exceeded","message":"Cannot allot table. 3 of 10 seats are free"}`, strings.TrimSpace(resp.Body.String()))
+	assert.Equal(t, http.StatusOK, addGuestList("jack", `{"table":1,"accompanying_guests":2}`).Code)
+
+	// moving a party onto a full table is rejected as well
+	resp = serve(app.EditGuestListHandler, http.MethodPatch, "/guest_list/jack", map[string]string{"name": "jack"}, `{"table":1,"accompanying_guests":3}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = serve(app.GetTableHandler, http.MethodGet, "/tables/1", map[string]string{"id": "1"}, "")
+	assert.Equal(t, `{"id":1,"capacity":10,"seats_allotted":10,"seats_occupied":0,"guests":[{"id":2,"name":"akhila","accompanying_guests":4,"status":"allotted"},{"id":3,"name":"jack","accompanying_guests":2,"status":"allotted"},{"id":1,"name":"john","accompanying_guests":1,"status":"allotted"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	// a party cannot bring more guests than the seats left at the table
+	resp = serve(app.UpdateGuestHandler, http.MethodPut, "/guests/john", map[string]string{"name": "john"}, `{"accompanying_guests":2}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"table_capacity_exceeded","message":"Cannot update number of accompanying guests. 2 of 10 seats are free"}`, strings.TrimSpace(resp.Body.String()))
+	resp = serve(app.UpdateGuestHandler, http.MethodPut, "/guests/john", map[string]string{"name": "john"}, `{"accompanying_guests":0}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serve(app.GetEmptySeatsHandler, http.MethodGet, "/seats_empty", nil, "")
+	assert.Equal(t, `{"seats_empty":3,"capacity":12,"seats_reserved":8,"seats_occupied":1,"tables":[{"id":1,"capacity":10,"seats_reserved":8,"seats_occupied":1,"seats_free":1},{"id":2,"capacity":2,"seats_reserved":0,"seats_occupied":0,"seats_free":2}],"guests":{"allotted":{"parties":2,"seats":8},"checked_in":{"parties":1,"seats":1},"checked_out":{"parties":0,"seats":0},"no_show":{"parties":0,"seats":0},"cancelled":{"parties":0,"seats":0}}}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	// seats of checked-out parties are free again
+	must(store.DbUpdateGuestStatus(guestId("john"), models.CHECKEDOUT))
+	assert.Equal(t, http.StatusOK, addGuestList("jill", `{"table":1,"accompanying_guests":1}`).Code)
+}
+
+func TestReadmission(t *testing.T) {
+	defer cleanup()
+	addTables(1)
+	addSingleGuest()
+	app := &App{Party: store}
+	departureTime := arrivalTime.Add(time.Hour)
+
+	checkIn := func(body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPut, "/guests/john", strings.NewReader(body))
+		request = mux.SetURLVars(request, map[string]string{"name": "john"})
+		responseRecorder := httptest.NewRecorder()
+		app.UpdateGuestHandler(responseRecorder, request)
+		return responseRecorder
+	}
+	checkOut := func() *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodDelete, "/guests/john", nil)
+		request = mux.SetURLVars(request, map[string]string{"name": "john"})
+		responseRecorder := httptest.NewRecorder()
+		app.DeleteGuestHandler(responseRecorder, request)
+		return responseRecorder
+	}
+	getGuests := func() string {
+		responseRecorder := httptest.NewRecorder()
+		app.GetGuestsHandler(responseRecorder, httptest.NewRequest(http.MethodGet, "/guests", nil))
+		return strings.TrimSpace(responseRecorder.Body.String())
+	}
+
+	assert.Equal(t, http.StatusOK, checkIn(`{"accompanying_guests":1}`).Code)
+	resp := checkIn(`{"accompanying_guests":1}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"guest_checked_in","message":"Request failed, guest already checked-in","extra":{"transition":{"from":"checked-in","to":"checked-in"}}}`, strings.TrimSpace(resp.Body.String()))
+
+	store.Now = func() time.Time { return departureTime }
+	assert.Equal(t, http.StatusNoContent, checkOut().Code)
+	assert.Equal(t, `[{"id":1,"time_arrived":"2022-12-20T08:10:43Z","time_left":"2022-12-20T09:10:43Z","accompanying_guests":1,"name":"john","present":false,"visits":[{"time_arrived":"2022-12-20T08:10:43Z","time_left":"2022-12-20T09:10:43Z","accompanying_guests":1}]}]`, getGuests())
+
+	// john comes back alone, the first visit is kept
+	assert.Equal(t, http.StatusOK, checkIn(`{"accompanying_guests":0}`).Code)
+	assert.Equal(t, `[{"id":1,"time_arrived":"2022-12-20T09:10:43Z","accompanying_guests":0,"name":"john","present":true,"visits":[{"time_arrived":"2022-12-20T08:10:43Z","time_left":"2022-12-20T09:10:43Z","accompanying_guests":1},{"time_arrived":"2022-12-20T09:10:43Z","accompanying_guests":0}]}]`, getGuests())
+}
+
+func TestGuestStatusTransitions(t *testing.T) {
+	defer cleanup()
+	addTables(2)
+	addGuest(1, 1, models.ALLOTTED, "john")
+	addGuest(2, 1, models.ALLOTTED, "akhila")
+	app := &App{Party: store}
+
+	patch := func(name, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPatch, "/guest_list/"+name, strings.NewReader(body))
+		request = mux.SetURLVars(request, map[string]string{"name": name})
+		responseRecorder := httptest.NewRecorder()
+		app.EditGuestListHandler(responseRecorder, request)
+		return responseRecorder
+	}
+	checkIn := func(name string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPut, "/guests/"+name, strings.NewReader(`{"accompanying_guests":1}`))
+		request = mux.SetURLVars(request, map[string]string{"name": name})
+		responseRecorder := httptest.NewRecorder()
+		app.UpdateGuestHandler(responseRecorder, request)
+		return responseRecorder
+	}
+
+	resp := patch("john", `{"status":"no-show"}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":1,"table":1,"accompanying_guests":1,"name":"john","status":"no-show"}`, strings.TrimSpace(resp.Body.String()))
+	// a late guest can still check-in
+	assert.Equal(t, http.StatusOK, checkIn("john").Code)
+
+	resp = patch("akhila", `{"status":"cancelled"}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = checkIn("akhila")
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"invalid_status_transition","message":"Request failed, guest status cannot change from cancelled to checked-in","extra":{"transition":{"from":"cancelled","to":"checked-in"}}}`, strings.TrimSpace(resp.Body.String()))
+
+	resp = patch("john", `{"status":"cancelled"}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"invalid_status_transition","message":"Request failed, guest status cannot change from checked-in to cancelled","extra":{"transition":{"from":"checked-in","to":"cancelled"}}}`, strings.TrimSpace(resp.Body.String()))
+
+	resp = patch("john", `{"status":"checked-out"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = patch("john", `{"status":"no-show","table":2}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, http.StatusNotFound, patch("jack", `{"status":"no-show"}`).Code)
+
+	status, err := store.DbGetGuestStatus(guestId("akhila"))
+	assert.NoError(t, err)
+	assert.Equal(t, models.CANCELLED, status)
+}
+
+func TestDwellReportHandler(t *testing.T) {
+	defer cleanup()
+	defer func() { store.Now = func() time.Time { return arrivalTime } }()
+	addTables(3)
+	addGuest(1, 0, models.ALLOTTED, "john")
+	addGuest(2, 0, models.ALLOTTED, "akhila")
+	addGuest(3, 0, models.ALLOTTED, "jack")
+	app := &App{Party: store, Now: func() time.Time { return arrivalTime.Add(3 * time.Hour) }}
+
+	at := func(d time.Duration) {
+		store.Now = func() time.Time { return arrivalTime.Add(d) }
+	}
+	checkIn := func(name string) {
+		request := httptest.NewRequest(http.MethodPut, "/guests/"+name, strings.NewReader(`{"accompanying_guests":0}`))
+		request = mux.SetURLVars(request, map[string]string{"name": name})
+		responseRecorder := httptest.NewRecorder()
+		app.UpdateGuestHandler(responseRecorder, request)
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+	}
+	checkOut := func(name string) {
+		request := httptest.NewRequest(http.MethodDelete, "/guests/"+name, nil)
+		request = mux.SetURLVars(request, map[string]string{"name": name})
+		responseRecorder := httptest.NewRecorder()
+		app.DeleteGuestHandler(responseRecorder, request)
+		assert.Equal(t, http.StatusNoContent, responseRecorder.Code)
+	}
+	report := func(url string) *httptest.ResponseRecorder {
+		responseRecorder := httptest.NewRecorder()
+		app.GetDwellReportHandler(responseRecorder, httptest.NewRequest(http.MethodGet, url, nil))
+		return responseRecorder
+	}
+
+	resp := report("/dwell_report")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"visits":0,"average_stay_seconds":0,"median_stay_seconds":0,"longest_stayers":[],"present":[]}`, strings.TrimSpace(resp.Body.String()))
+
+	at(0)
+	checkIn("john")
+	checkIn("akhila")
+	at(time.Hour)
+	checkOut("john")
+	at(90 * time.Minute)
+	checkOut("akhila")
+	at(2 * time.Hour)
+	checkIn("jack")
+	checkIn("john")
+
+	resp = report("/dwell_report")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"visits":2,"average_stay_seconds":4500,"median_stay_seconds":4500,"longest_stayers":[{"id":1,"name":"john","stay_seconds":7200},{"id":2,"name":"akhila","stay_seconds":5400},{"id":3,"name":"jack","stay_seconds":3600}],"present":[{"id":3,"name":"jack","time_arrived":"2022-12-20T10:10:43Z","stay_seconds":3600},{"id":1,"name":"john","time_arrived":"2022-12-20T10:10:43Z","stay_seconds":3600}]}`, strings.TrimSpace(resp.Body.String()))
+
+	resp = report("/dwell_report?limit=1")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"longest_stayers":[{"id":1,"name":"john","stay_seconds":7200}]`)
+
+	resp = report("/dwell_report?limit=0")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"limit must be greater than 0","details":[{"field":"limit","message":"limit must be greater than 0"}]}`, strings.TrimSpace(resp.Body.String()))
+	assert.Equal(t, http.StatusBadRequest, report("/dwell_report?limit=all").Code)
+}
+
+func TestImportHandler(t *testing.T) {
+	defer cleanup()
+	addTables(1)
+	app := &App{Party: store}
+
+	importPlan := func(url, contentType, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		responseRecorder := httptest.NewRecorder()
+		app.ImportHandler(responseRecorder, request)
+		return responseRecorder
+	}
+
+	plan := "table,capacity,name,accompanying_guests\n" +
+		"1,4,,\n" +
+		"2,0,,\n" +
+		",,John,2\n" +
+		"1,,akhila,x\n" +
+		"1,,jack,4\n"
+	resp := importPlan("/import", "text/csv", plan)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"import_failed","message":"Import failed, 4 of 5 rows are invalid","extra":{"rows":[`+
+		`{"row":2,"kind":"table","table":2},`+
+		`{"row":3,"kind":"table","code":"validation_failed","message":"capacity must be greater than 0","details":[{"field":"capacity","message":"capacity must be greater than 0"}]},`+
+		`{"row":4,"kind":"guest","name":"John","code":"validation_failed","message":"table is a required field","details":[{"field":"table","message":"table is a required field"}]},`+
+		`{"row":5,"kind":"guest","name":"akhila","code":"validation_failed","message":"accompanying_guests must be a number","details":[{"field":"accompanying_guests","message":"accompanying_guests must be a number"}]},`+
+		`{"row":6,"kind":"guest","name":"jack","code":"table_capacity_exceeded","message":"Cannot allot table. Table capacity is 4"}]}}`,
+		strings.TrimSpace(resp.Body.String()))
+	assert.Equal(t, 1, len(store.Tables()), "nothing is imported when a row fails")
+	assert.Nil(t, store.Guests())
+
+	// table 1 of the plan is the new table, table 1 of the party is used by
+	// its id
+	plan = `{"tables":[{"table":1,"capacity":4},{"table":7,"capacity":6}],` +
+		`"guests":[{"id":1,"name":"john","table":1,"accompanying_guests":3},{"id":2,"name":"akhila","table":7},{"id":3,"name":"jack","table":9}]}`
+	resp = importPlan("/import?dry_run=true", "application/json", plan)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), `{"row":3,"kind":"guest","name":"jack","code":"unknown_table","message":"Invalid table-id"}`)
+
+	plan = strings.Replace(plan, `"table":9`, `"table":7`, 1)
+	resp = importPlan("/import?dry_run=true", "application/json", plan)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"code":"table_allotted","message":"Table already allotted to akhila"`)
+
+	plan = strings.Replace(plan, `{"id":3,"name":"jack","table":7}`, `{"id":3,"name":"jack","table":1}`, 1)
+	resp = importPlan("/import?dry_run=true", "application/json", plan)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"code":"table_allotted","message":"Table already allotted to john"`)
+
+	plan = `{"tables":[{"table":1,"capacity":4},{"table":7,"capacity":6}],` +
+		`"guests":[{"id":1,"name":"john","table":1,"accompanying_guests":3},{"id":2,"name":"akhila","table":7}]}`
+	resp = importPlan("/import?dry_run=true", "application/json", plan)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"dry_run":true,"tables":2,"guests":2,"rows":[{"row":1,"kind":"table","table":2},{"row":2,"kind":"table","table":3},{"row":1,"kind":"guest","name":"john","table":2,"guest_id":1},{"row":2,"kind":"guest","name":"akhila","table":3,"guest_id":2}]}`,
+		strings.TrimSpace(resp.Body.String()))
+	assert.Equal(t, 1, len(store.Tables()), "a dry run imports nothing")
+
+	resp = importPlan("/import", "application/json", plan)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"dry_run":false,"tables":2,"guests":2,"rows":[{"row":1,"kind":"table","table":2},{"row":2,"kind":"table","table":3},{"row":1,"kind":"guest","name":"john","table":2,"guest_id":1},{"row":2,"kind":"guest","name":"akhila","table":3,"guest_id":2}]}`,
+		strings.TrimSpace(resp.Body.String()))
+	assert.Equal(t, []models.Guests{
+		{Id: 1, Table: 2, AccompanyingGuests: 3, Status: models.ALLOTTED, Name: "john"},
+		{Id: 2, Table: 3, AccompanyingGuests: 0, Status: models.ALLOTTED, Name: "akhila"},
+	}, store.Guests())
+
+	resp = importPlan("/import", "application/json", plan)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"code":"guest_exists","message":"Guest john already added"`)
+
+	assert.Equal(t, http.StatusBadRequest, importPlan("/import", "text/csv", "table,seats\n1,4\n").Code)
+	assert.Equal(t, http.StatusBadRequest, importPlan("/import?format=xml", "text/xml", "").Code)
+	assert.Equal(t, http.StatusBadRequest, importPlan("/import?dry_run=maybe", "application/json", plan).Code)
+}
+
+func TestExportHandlers(t *testing.T) {
+	defer cleanup()
+	addTables(3)
+	addGuest(1, 1, models.ALLOTTED, "john")
+	addGuest(2, 2, models.CHECKEDIN, "akhila")
+	addGuest(3, 0, models.ALLOTTED, "=cmd")
+	app := &App{Party: store}
+	checkIn := httptest.NewRequest(http.MethodPut, "/guests/=cmd", strings.NewReader(`{"accompanying_guests":0}`))
+	checkIn = mux.SetURLVars(checkIn, map[string]string{"name": "=cmd"})
+	app.UpdateGuestHandler(httptest.NewRecorder(), checkIn)
+
+	get := func(handler http.HandlerFunc, url, accept string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+		responseRecorder := httptest.NewRecorder()
+		handler(responseRecorder, request)
+		return responseRecorder
+	}
+
+	tt := []struct {
+		name        string
+		handler     http.HandlerFunc
+		url         string
+		accept      string
+		contentType string
+		want        string
+	}{
+		{
+			name:        "guest list as csv",
+			handler:     app.GetGuestListHandler,
+			url:         "/guest_list",
+			accept:      "text/csv",
+			contentType: "text/csv; charset=utf-8",
+			want: "id,name,table,accompanying_guests,status\n" +
+				"3,=cmd,3,0,checked-in\n" +
+				"2,akhila,2,2,checked-in\n" +
+				"1,john,1,1,allotted\n",
+		},
+		{
+			name:        "guest list for excel",
+			handler:     app.GetGuestListHandler,
+			url:         "/guest_list?format=excel",
+			contentType: "text/csv; charset=utf-8",
+			want: "\ufeffid,name,table,accompanying_guests,status\r\n" +
+				"3,'=cmd,3,0,checked-in\r\n" +
+				"2,akhila,2,2,checked-in\r\n" +
+				"1,john,1,1,allotted\r\n",
+		},
+		{
+			name:        "arrivals as csv",
+			handler:     app.GetGuestsHandler,
+			url:         "/guests",
+			accept:      "application/xml, text/csv;q=0.9",
+			contentType: "text/csv; charset=utf-8",
+			want: "id,name,accompanying_guests,time_arrived,time_left\n" +
+				"3,=cmd,0,2022-12-20T08:10:43Z,\n",
+		},
+		{
+			name:        "tables as csv",
+			handler:     app.GetTablesHandler,
+			url:         "/tables?format=csv",
+			accept:      "application/json",
+			contentType: "text/csv; charset=utf-8",
+			want: "id,capacity,seats_allotted,seats_occupied,guests\n" +
+				"1,3,2,0,john\n" +
+				"2,4,3,3,akhila\n" +
+				"3,5,1,1,=cmd\n",
+		},
+		{
+			name:        "json is the default",
+			handler:     app.GetTablesHandler,
+			url:         "/tables",
+			accept:      "text/csv;q=0, image/png",
+			contentType: "application/json",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := get(tc.handler, tc.url, tc.accept)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, tc.contentType, resp.Header().Get("Content-Type"))
+			if tc.want != "" {
+				assert.Equal(t, tc.want, resp.Body.String())
+			}
+		})
+	}
+
+	resp := get(app.GetGuestListHandler, "/guest_list", "text/html")
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header().Get("Content-Type"))
+	assert.Contains(t, resp.Body.String(), "<p>Page 1 of 1</p>")
+	assert.Contains(t, resp.Body.String(), "<tr><td>3</td><td>=cmd</td><td>3</td><td>0</td><td>checked-in</td><td></td></tr>")
+
+	resp = get(app.GetGuestListHandler, "/guest_list?format=pdf", "")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"format must be one of [json csv excel html]","details":[{"field":"format","message":"format must be one of [json csv excel html]"}]}`, strings.TrimSpace(resp.Body.String()))
+}
+
+func TestPrintPages(t *testing.T) {
+	e := export{title: "Guest list", header: []string{"name"}}
+	for i := 0; i < printRowsPerPage+1; i++ {
+		e.rows = append(e.rows, []string{fmt.Sprintf("guest %d", i)})
+	}
+	responseRecorder := httptest.NewRecorder()
+	sendHTML(responseRecorder, e)
+	assert.Equal(t, 2, strings.Count(responseRecorder.Body.String(), "<section>"))
+	assert.Contains(t, responseRecorder.Body.String(), "<p>Page 2 of 2</p>")
+}
+
+func TestGuestListPages(t *testing.T) {
+	defer cleanup()
+	addTables(3)
+	addGuest(1, 0, models.ALLOTTED, "john")
+	addGuest(2, 0, models.CHECKEDIN, "akhila")
+	addGuest(3, 0, models.ALLOTTED, "jack")
+	addGuest(1, 0, models.CHECKEDOUT, "jill")
+	app := &App{Party: store}
+
+	get := func(url string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		responseRecorder := httptest.NewRecorder()
+		app.GetGuestListHandler(responseRecorder, request)
+		return responseRecorder
+	}
+	names := func(resp *httptest.ResponseRecorder) []string {
+		var guestList []GuestList
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &guestList))
+		var names []string
+		for _, g := range guestList {
+			names = append(names, g.Name)
+		}
+		return names
+	}
+	nextPage := func(resp *httptest.ResponseRecorder) string {
+		link := resp.Header().Get("Link")
+		assert.True(t, strings.HasSuffix(link, `>; rel="next"`), link)
+		return strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+	}
+
+	resp := get("/guest_list?sort=-table&limit=2")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, []string{"jack", "akhila"}, names(resp))
+	next := nextPage(resp)
+	assert.True(t, strings.HasPrefix(next, "/guest_list?cursor="), next)
+	resp = get(next)
+	assert.Equal(t, []string{"john", "jill"}, names(resp))
+	assert.Equal(t, "", resp.Header().Get("Link"), "last page")
+
+	assert.Equal(t, []string{"jack", "jill", "john"}, names(get("/guest_list?name_prefix=J&status=allotted,checked-out&sort=name")))
+	assert.Equal(t, []string{"jill"}, names(get("/guest_list?name_prefix=ji")))
+	assert.Equal(t, []string{"jill", "john"}, names(get("/guest_list?table=1")))
+
+	resp = get("/guest_list?sort=table&cursor=" + strings.TrimPrefix(next, "/guest_list?cursor="))
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), `{"field":"cursor","message":"cursor is not a page of this sort"}`)
+	resp = get("/guest_list?status=present&limit=0&sort=time_arrived")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"status must be one of [allotted checked-in checked-out no-show cancelled], sort must be one of [name table], with a leading - to sort descending, limit must be between 1 and 1000","details":[{"field":"status","message":"status must be one of [allotted checked-in checked-out no-show cancelled]"},{"field":"sort","message":"sort must be one of [name table], with a leading - to sort descending"},{"field":"limit","message":"limit must be between 1 and 1000"}]}`, strings.TrimSpace(resp.Body.String()))
+
+	guests := httptest.NewRecorder()
+	app.GetGuestsHandler(guests, httptest.NewRequest(http.MethodGet, "/guests?status=checked-out", nil))
+	assert.Equal(t, http.StatusOK, guests.Code)
+	assert.Equal(t, []string{"jill"}, names(guests))
+	guests = httptest.NewRecorder()
+	app.GetGuestsHandler(guests, httptest.NewRequest(http.MethodGet, "/guests?status=allotted&arrived_after=yesterday", nil))
+	assert.Equal(t, http.StatusBadRequest, guests.Code)
+	assert.Contains(t, guests.Body.String(), `{"field":"arrived_after","message":"arrived_after must be a time in RFC 3339 format"}`)
+}
+
+func TestSearchGuestsHandler(t *testing.T) {
+	defer cleanup()
+	addTables(3)
+	addGuest(1, 2, models.CHECKEDIN, "zoë müller")
+	addGuest(2, 0, models.ALLOTTED, "john smith")
+	addGuest(3, 0, models.ALLOTTED, "joan")
+	addGuest(3, 0, models.NOSHOW, "jack")
+	app := &App{Party: store}
+
+	search := func(url string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		responseRecorder := httptest.NewRecorder()
+		app.SearchGuestsHandler(responseRecorder, request)
+		return responseRecorder
+	}
+
+	resp := search("/guests/search?q=Zoe")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `[{"id":1,"name":"zoë müller","table":1,"accompanying_guests":2,"status":"checked-in","score":1}]`, strings.TrimSpace(resp.Body.String()))
+
+	resp = search("/guests/search?q=MULLER")
+	assert.Equal(t, `[{"id":1,"name":"zoë müller","table":1,"accompanying_guests":2,"status":"checked-in","score":0.9}]`, strings.TrimSpace(resp.Body.String()))
+
+	resp = search("/guests/search?q=jhon")
+	var results []SearchResult
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &results))
+	assert.Equal(t, []SearchResult{
+		{ID: 2, Name: "john smith", Table: 2, Status: models.ALLOTTED, Score: 0.6},
+		{ID: 3, Name: "joan", Table: 3, Status: models.ALLOTTED, Score: 0.4},
+	}, results)
+
+	resp = search("/guests/search?q=jo&limit=1")
+	assert.Equal(t, `[{"id":3,"name":"joan","table":3,"accompanying_guests":0,"status":"allotted","score":1}]`, strings.TrimSpace(resp.Body.String()))
+
+	resp = search("/guests/search?q=xyz")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "[]", strings.TrimSpace(resp.Body.String()))
+
+	resp = search("/guests/search?q=%20&limit=101")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"q is required, limit must be between 1 and 100","details":[{"field":"q","message":"q is required"},{"field":"limit","message":"limit must be between 1 and 100"}]}`, strings.TrimSpace(resp.Body.String()))
+}
+
+func TestDisplayNames(t *testing.T) {
+	defer cleanup()
+	addTables(2)
+	app := &App{Party: store}
+
+	add := func(name string, table int64) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/guest_list/"+url.PathEscape(name), strings.NewReader(fmt.Sprintf(`{"table":%d}`, table)))
+		request = mux.SetURLVars(request, map[string]string{"name": name})
+		responseRecorder := httptest.NewRecorder()
+		app.AddGuestListHandler(responseRecorder, request)
+		return responseRecorder
+	}
+	checkIn := func(name string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPut, "/guests/"+url.PathEscape(name), strings.NewReader(`{"accompanying_guests":0}`))
+		request = mux.SetURLVars(request, map[string]string{"name": name})
+		responseRecorder := httptest.NewRecorder()
+		app.UpdateGuestHandler(responseRecorder, request)
+		return responseRecorder
+	}
+
+	resp := add(" Mary-Jane  O'Neil", 1)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":1,"name":"Mary-Jane O'Neil"}`, strings.TrimSpace(resp.Body.String()))
+
+	// names differing in case or spacing are the same guest
+	resp = add("MARY-JANE O'NEIL", 2)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = checkIn("mary-jane o'neil")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":1,"name":"Mary-Jane O'Neil"}`, strings.TrimSpace(resp.Body.String()))
+
+	guestList := httptest.NewRecorder()
+	app.GetGuestListHandler(guestList, httptest.NewRequest(http.MethodGet, "/guest_list?name_prefix=MARY", nil))
+	assert.Equal(t, `[{"id":1,"table":1,"accompanying_guests":0,"name":"Mary-Jane O'Neil","status":"checked-in"}]`, strings.TrimSpace(guestList.Body.String()))
+}
+
+func TestSameNames(t *testing.T) {
+	defer cleanup()
+	addTables(3)
+	app := &App{Party: store}
+
+	serve := func(handler http.HandlerFunc, method, target, body string, vars map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		request = mux.SetURLVars(request, vars)
+		responseRecorder := httptest.NewRecorder()
+		handler(responseRecorder, request)
+		return responseRecorder
+	}
+	byId := func(id string) map[string]string { return map[string]string{"id": id} }
+	byName := func(name string) map[string]string { return map[string]string{"name": name} }
+
+	resp := serve(app.AddGuestListHandler, http.MethodPost, "/guest_list", `{"name":"John Smith","table":1}`, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":1,"name":"John Smith"}`, strings.TrimSpace(resp.Body.String()))
+
+	// the body names a guest that may share the name of another guest
+	resp = serve(app.AddGuestListHandler, http.MethodPost, "/guest_list", `{"name":"john  smith","table":2,"accompanying_guests":1}`, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":2,"name":"john smith"}`, strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.AddGuestListHandler, http.MethodPost, "/guest_list/John%20Smith", `{"table":3}`, byName("John Smith"))
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = serve(app.AddGuestListHandler, http.MethodPost, "/guest_list", `{"table":3}`, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"name is a required field","details":[{"field":"name","message":"name is a required field"}]}`, strings.TrimSpace(resp.Body.String()))
+
+	// a shared name no longer tells the guests apart
+	resp = serve(app.UpdateGuestHandler, http.MethodPut, "/guests/John%20Smith", `{"accompanying_guests":0}`, byName("John Smith"))
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"guest_name_ambiguous","message":"Request failed, 2 guests are named John Smith, refer to the guest by id","extra":{"guest_ids":[1,2]}}`, strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.UpdateGuestHandler, http.MethodPut, "/guests/by-id/2", `{"accompanying_guests":1}`, byId("2"))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":2,"name":"john smith"}`, strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.GetGuestHandler, http.MethodGet, "/guests/by-id/2", "", byId("2"))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":2,"table":2,"accompanying_guests":1,"name":"john smith","status":"checked-in"}`, strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.GetGuestHandler, http.MethodGet, "/guests/by-id/1", "", byId("1"))
+	assert.Equal(t, `{"id":1,"table":1,"accompanying_guests":0,"name":"John Smith","status":"allotted"}`, strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.EditGuestListHandler, http.MethodPatch, "/guest_list/by-id/1", `{"table":3}`, byId("1"))
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serve(app.DeleteGuestHandler, http.MethodDelete, "/guests/by-id/2", "", byId("2"))
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = serve(app.RemoveGuestListHandler, http.MethodDelete, "/guest_list/by-id/1", "", byId("1"))
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	guests := store.Guests()
+	assert.Equal(t, 1, len(guests))
+	assert.Equal(t, int64(2), guests[0].Id)
+	assert.Equal(t, models.CHECKEDOUT, guests[0].Status)
+
+	resp = serve(app.GetGuestHandler, http.MethodGet, "/guests/by-id/1", "", byId("1"))
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, `{"code":"guest_not_found","message":"Guest 1 is not present in Guestlist"}`, strings.TrimSpace(resp.Body.String()))
+
+	for _, id := range []string{"0", "x", "-1"} {
+		resp = serve(app.GetGuestHandler, http.MethodGet, "/guests/by-id/"+id, "", byId(id))
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Equal(t, `{"code":"invalid_guest_id","message":"Invalid guest-id"}`, strings.TrimSpace(resp.Body.String()))
+	}
+}
+
+func TestCompanions(t *testing.T) {
+	defer cleanup()
+	addTables(3)
+	app := &App{Party: store}
+
+	serve := func(handler http.HandlerFunc, method, target, body string, vars map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		request = mux.SetURLVars(request, vars)
+		responseRecorder := httptest.NewRecorder()
+		handler(responseRecorder, request)
+		return responseRecorder
+	}
+	john := map[string]string{"name": "john"}
+
+	// the named companions are counted as accompanying guests
+	resp := serve(app.AddGuestListHandler, http.MethodPost, "/guest_list/john",
+		`{"table":3,"companions":[{"name":" Anna  Lee","dietary":"vegan"},{"name":"Ben","accessibility":"wheelchair"}]}`, john)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = serve(app.GetGuestHandler, http.MethodGet, "/guests/by-id/1", "", map[string]string{"id": "1"})
+	assert.Equal(t, `{"id":1,"table":3,"accompanying_guests":2,"name":"john","status":"allotted","companions":[{"name":"Anna Lee","dietary":"vegan","arrived":false},{"name":"Ben","accessibility":"wheelchair","arrived":false}]}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	// table 1 seats 3, the party of four does not fit
+	resp = serve(app.AddGuestListHandler, http.MethodPost, "/guest_list/mary", `{"table":1,"companions":[{"name":"Tom"},{"name":"Sue"},{"name":"Max"}]}`, map[string]string{"name": "mary"})
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"table_capacity_exceeded","message":"Cannot allot table. Table capacity is 3"}`, strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.AddGuestListHandler, http.MethodPost, "/guest_list/mary", `{"table":2,"accompanying_guests":1,"companions":[{"name":"Tom"},{"name":"tom"},{"name":""}]}`, map[string]string{"name": "mary"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"companions[1].name is the name of another companion, companions[2].name is a required field, accompanying_guests must be at least the number of companions","details":[{"field":"companions[1].name","message":"companions[1].name is the name of another companion"},{"field":"companions[2].name","message":"companions[2].name is a required field"},{"field":"accompanying_guests","message":"accompanying_guests must be at least the number of companions"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.EditGuestListHandler, http.MethodPatch, "/guest_list/john", `{"accompanying_guests":1}`, john)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"accompanying_guests must be at least the number of companions","details":[{"field":"accompanying_guests","message":"accompanying_guests must be at least the number of companions"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.EditGuestListHandler, http.MethodPatch, "/guest_list/john", `{"companions":[{"name":"Anna Lee","dietary":"vegan"},{"name":"Ben"},{"name":"Cleo"}]}`, john)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":1,"table":3,"accompanying_guests":3,"name":"john","companions":[{"name":"Anna Lee","dietary":"vegan","arrived":false},{"name":"Ben","arrived":false},{"name":"Cleo","arrived":false}]}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.UpdateGuestHandler, http.MethodPut, "/guests/john", `{"companions_arrived":["anna lee","Dora"]}`, john)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"companions_arrived[1] is not a companion of the guest","details":[{"field":"companions_arrived[1]","message":"companions_arrived[1] is not a companion of the guest"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.UpdateGuestHandler, http.MethodPut, "/guests/john", `{"accompanying_guests":1,"companions_arrived":["anna lee","Cleo"]}`, john)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// the arrived companions are counted, one more guest came unnamed
+	resp = serve(app.UpdateGuestHandler, http.MethodPut, "/guests/john", `{"accompanying_guests":3,"companions_arrived":["anna lee","Cleo"]}`, john)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	guests := httptest.NewRecorder()
+	app.GetGuestsHandler(guests, httptest.NewRequest(http.MethodGet, "/guests", nil))
+	assert.Contains(t, guests.Body.String(), `"accompanying_guests":3,"name":"john","present":true`)
+	assert.Contains(t, guests.Body.String(), `"companions":[{"name":"Anna Lee","dietary":"vegan","arrived":true},{"name":"Ben","arrived":false},{"name":"Cleo","arrived":true}]`)
+
+	guestList := httptest.NewRecorder()
+	app.GetGuestListHandler(guestList, httptest.NewRequest(http.MethodGet, "/guest_list", nil))
+	assert.Equal(t, `[{"id":1,"table":3,"accompanying_guests":3,"name":"john","status":"checked-in","companions":[{"name":"Anna Lee","dietary":"vegan","arrived":true},{"name":"Ben","arrived":false},{"name":"Cleo","arrived":true}]}]`,
+		strings.TrimSpace(guestList.Body.String()))
+}
+
+func TestSeatingPlanner(t *testing.T) {
+	defer cleanup()
+	app := &App{Party: store, SharedTables: true}
+	for _, capacity := range []int64{5, 7} {
+		_, err := store.DbAddTable(capacity)
+		must(err)
+	}
+
+	serve := func(handler http.HandlerFunc, target, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		responseRecorder := httptest.NewRecorder()
+		handler(responseRecorder, request)
+		return responseRecorder
+	}
+
+	// best fit alone seats ann at table 1 and wastes 5 seats
+	resp := serve(app.PlanSeatingHandler, "/seating_plan", `{"guests":[{"name":"ann","accompanying_guests":3},{"name":"bob","accompanying_guests":2}]}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"wasted_seats":0,"guests":[{"name":"ann","table":2,"accompanying_guests":3},{"name":"bob","table":2,"accompanying_guests":2}],"unseated":[]}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	plan := `{"guests":[{"name":"ann","accompanying_guests":3},{"name":"bob","accompanying_guests":2},{"name":"cat","accompanying_guests":7}],"keep_apart":[["ann","bob"]]}`
+	resp = serve(app.PlanSeatingHandler, "/seating_plan", plan)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	proposal := resp.Body.String()
+	assert.Equal(t, `{"wasted_seats":5,"guests":[{"name":"ann","table":1,"accompanying_guests":3},{"name":"bob","table":2,"accompanying_guests":2}],"unseated":[{"name":"cat","accompanying_guests":7,"reason":"No table has 8 free seats"}],"keep_apart":[["ann","bob"]]}`,
+		strings.TrimSpace(proposal))
+	assert.Empty(t, store.Guests(), "a proposal adds no guests")
+
+	// a proposal changed against its constraints is refused
+	resp = serve(app.CommitSeatingPlanHandler, "/seating_plan/commit", strings.Replace(proposal, `"table":1`, `"table":2`, 1))
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"keep_apart[0][1] is seated with a guest kept apart","details":[{"field":"keep_apart[0][1]","message":"keep_apart[0][1] is seated with a guest kept apart"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.CommitSeatingPlanHandler, "/seating_plan/commit", proposal)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"dry_run":false,"tables":0,"guests":2,"rows":[{"row":1,"kind":"guest","name":"ann","table":1,"guest_id":1},{"row":2,"kind":"guest","name":"bob","table":2,"guest_id":2}]}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	// the seats taken by the committed plan are not free any more
+	resp = serve(app.PlanSeatingHandler, "/seating_plan", `{"guests":[{"name":"dan"},{"name":"eve","accompanying_guests":1}],"keep_together":[["dan","eve"]]}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"wasted_seats":1,"guests":[{"name":"dan","table":2,"accompanying_guests":0},{"name":"eve","table":2,"accompanying_guests":1}],"unseated":[]}`+"\n",
+		strings.Replace(resp.Body.String(), `,"keep_together":[["dan","eve"]]`, "", 1))
+
+	resp = serve(app.PlanSeatingHandler, "/seating_plan", `{"guests":[{"name":"ANN"}]}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"guest_exists","message":"Guest ANN already added"}`, strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.PlanSeatingHandler, "/seating_plan", `{"guests":[{"name":"fay"},{"name":"gus"}],"keep_together":[["fay","gus"]],"keep_apart":[["gus","fay"]]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"keep_apart[0] separates guests kept together","details":[{"field":"keep_apart[0]","message":"keep_apart[0] separates guests kept together"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.PlanSeatingHandler, "/seating_plan", `{"guests":[{"name":"fay"},{"name":" FAY"}],"keep_apart":[["fay","hal"]]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"guests[1].name is the name of another guest of the plan, keep_apart[0][1] is not a guest of the plan","details":[{"field":"guests[1].name","message":"guests[1].name is the name of another guest of the plan"},{"field":"keep_apart[0][1]","message":"keep_apart[0][1] is not a guest of the plan"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	// without shared tables every party needs an empty table
+	app.SharedTables = false
+	resp = serve(app.PlanSeatingHandler, "/seating_plan", `{"guests":[{"name":"fay"},{"name":"gus"}],"keep_together":[["fay","gus"]]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"keep_together[0] needs shared tables, a table seats a single party","details":[{"field":"keep_together[0]","message":"keep_together[0] needs shared tables, a table seats a single party"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	for _, capacity := range []int64{3, 4} {
+		_, err := store.DbAddTable(capacity)
+		must(err)
+	}
+	resp = serve(app.PlanSeatingHandler, "/seating_plan", `{"guests":[{"name":"fay"},{"name":"gus","accompanying_guests":3},{"name":"hal","accompanying_guests":1}]}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"wasted_seats":1,"guests":[{"name":"gus","table":4,"accompanying_guests":3},{"name":"hal","table":3,"accompanying_guests":1}],"unseated":[{"name":"fay","accompanying_guests":0,"reason":"No table left for the party"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+}
+
+func TestConstraints(t *testing.T) {
+	defer cleanup()
+	app := &App{Party: store, SharedTables: true}
+	for _, capacity := range []int64{5, 7} {
+		_, err := store.DbAddTable(capacity)
+		must(err)
+	}
+
+	serve := func(handler http.HandlerFunc, method, target, body string, vars map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		request = mux.SetURLVars(request, vars)
+		responseRecorder := httptest.NewRecorder()
+		handler(responseRecorder, request)
+		return responseRecorder
+	}
+	addConstraint := func(body string) *httptest.ResponseRecorder {
+		return serve(app.AddConstraintHandler, http.MethodPost, "/constraints", body, nil)
+	}
+	allot := func(name, body string) *httptest.ResponseRecorder {
+		return serve(app.AddGuestListHandler, http.MethodPost, "/guest_list/"+name, body, map[string]string{"name": name})
+	}
+
+	resp := addConstraint(`{"kind":"together","guests":["ann"],"tag":"x"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"guests must contain 2 items for together, together takes no table or tag","details":[{"field":"guests","message":"guests must contain 2 items for together"},{"field":"kind","message":"together takes no table or tag"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+	resp = addConstraint(`{"kind":"apart","guests":["ann"," ANN"]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"guests must name two different guests","details":[{"field":"guests","message":"guests must name two different guests"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+	resp = addConstraint(`{"kind":"table_tag","table":9,"tag":"accessible"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"unknown_table","message":"Invalid table-id"}`, strings.TrimSpace(resp.Body.String()))
+
+	for _, body := range []string{
+		`{"kind":"table_tag","table":2,"tag":" Accessible"}`,
+		`{"kind":"requires","guests":["ann"],"tag":"accessible"}`,
+		`{"kind":"apart","guests":["ann","bob"]}`,
+		`{"kind":"together","guests":["bob","cat"]}`,
+	} {
+		resp = addConstraint(body)
+		assert.Equal(t, http.StatusOK, resp.Code)
+	}
+	resp = serve(app.GetConstraintsHandler, http.MethodGet, "/constraints", "", nil)
+	assert.Equal(t, `[{"id":1,"kind":"table_tag","table":2,"tag":"accessible"},{"id":2,"kind":"requires","guests":["ann"],"tag":"accessible"},{"id":3,"kind":"apart","guests":["ann","bob"]},{"id":4,"kind":"together","guests":["bob","cat"]}]`,
+		strings.TrimSpace(resp.Body.String()))
+
+	// the constraints are checked when a table is chosen
+	resp = allot("ann", `{"table":1}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"constraint_violated","message":"Cannot allot table. ann needs a table tagged accessible","extra":{"constraint_ids":[2]}}`, strings.TrimSpace(resp.Body.String()))
+	resp = allot("ann", `{"table":2}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = allot("bob", `{"table":2}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"constraint_violated","message":"Cannot allot table. bob must not share a table with ann","extra":{"constraint_ids":[3]}}`, strings.TrimSpace(resp.Body.String()))
+	resp = allot("bob", `{"table":1}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = allot("cat", `{"table":2}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"constraint_violated","message":"Cannot allot table. cat must sit with bob at table 1","extra":{"constraint_ids":[4]}}`, strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.EditGuestListHandler, http.MethodPatch, "/guest_list/bob", `{"table":2}`, map[string]string{"name": "bob"})
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"constraint_violated","message":"Cannot allot table. bob must not share a table with ann","extra":{"constraint_ids":[3]}}`, strings.TrimSpace(resp.Body.String()))
+
+	// guests seated before a constraint was added are warned of it
+	addGuest(2, 0, models.ALLOTTED, "cat")
+	resp = serve(app.GetGuestListHandler, http.MethodGet, "/guest_list", "", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `[{"id":1,"table":2,"accompanying_guests":0,"name":"ann","status":"allotted"},{"id":2,"table":1,"accompanying_guests":0,"name":"bob","status":"allotted","warnings":["bob must sit with cat at table 2"]},{"id":3,"table":2,"accompanying_guests":0,"name":"cat","status":"allotted","warnings":["cat must sit with bob at table 1"]}]`,
+		strings.TrimSpace(resp.Body.String()))
+
+	// the planner only proposes tables that meet the stored constraints
+	resp = addConstraint(`{"kind":"requires","guests":["dan"],"tag":"accessible"}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = addConstraint(`{"kind":"apart","guests":["eve","ann"]}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = serve(app.PlanSeatingHandler, http.MethodPost, "/seating_plan", `{"guests":[{"name":"dan"},{"name":"eve","accompanying_guests":4}]}`, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"wasted_seats":4,"guests":[{"name":"dan","table":2,"accompanying_guests":0}],"unseated":[{"name":"eve","accompanying_guests":4,"reason":"No table meets the seating constraints of the party"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.DeleteConstraintHandler, http.MethodDelete, "/constraints/4", "", map[string]string{"id": "4"})
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	resp = serve(app.DeleteConstraintHandler, http.MethodDelete, "/constraints/4", "", map[string]string{"id": "4"})
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, `{"code":"constraint_not_found","message":"Constraint 4 not found"}`, strings.TrimSpace(resp.Body.String()))
+	resp = serve(app.DeleteConstraintHandler, http.MethodDelete, "/constraints/x", "", map[string]string{"id": "x"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"invalid_constraint_id","message":"Invalid constraint-id"}`, strings.TrimSpace(resp.Body.String()))
+	resp = serve(app.GetGuestListHandler, http.MethodGet, "/guest_list", "", nil)
+	assert.NotContains(t, resp.Body.String(), "warnings")
+
+	// a table seats a single party without shared tables, guests cannot be
+	// kept together and stored together constraints are only warned of
+	cleanup()
+	app = &App{Party: store}
+	addTables(2)
+	resp = addConstraint(`{"kind":"together","guests":["bob","cat"]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"together needs shared tables, a table seats a single party","details":[{"field":"kind","message":"together needs shared tables, a table seats a single party"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+	_, err := store.DbAddConstraint(models.Constraint{Kind: models.TOGETHER, Name: "bob", Other: "cat"})
+	must(err)
+	resp = allot("bob", `{"table":1}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = allot("cat", `{"table":2}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = serve(app.GetGuestListHandler, http.MethodGet, "/guest_list", "", nil)
+	assert.Contains(t, resp.Body.String(), `"warnings":["cat must sit with bob at table 1"]`)
+}
+
+func TestWalkIn(t *testing.T) {
+	defer cleanup()
+	addTables(3)
+	addGuest(1, 1, models.ALLOTTED, "john")
+	app := &App{Party: store}
+
+	walkIn := func(body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/guests", strings.NewReader(body))
+		responseRecorder := httptest.NewRecorder()
+		app.WalkInHandler(responseRecorder, request)
+		return responseRecorder
+	}
+
+	// table 2 seating 4 is the smallest free table the party fits on
+	resp := walkIn(`{"name":" Ann ","accompanying_guests":1}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":2,"table":2,"accompanying_guests":1,"name":"Ann","status":"checked-in"}`, strings.TrimSpace(resp.Body.String()))
+	visits, err := store.DbGetVisits()
+	must(err)
+	assert.Equal(t, 1, len(visits), "the walk-in is checked in")
+
+	app.WalkIn.Policy = SmallestFit
+	resp = walkIn(`{"name":"bob","policy":"largest_free"}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":3,"table":3,"accompanying_guests":0,"name":"bob","status":"checked-in"}`, strings.TrimSpace(resp.Body.String()))
+
+	// without shared tables every table now has a party
+	resp = walkIn(`{"name":"cat"}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"no_table_available","message":"No table has 1 free seats for the party"}`, strings.TrimSpace(resp.Body.String()))
+
+	// table 3 keeps 3 of its 4 free seats in reserve
+	app.SharedTables = true
+	app.WalkIn.Reserve = 3
+	resp = walkIn(`{"name":"cat"}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":4,"table":3,"accompanying_guests":0,"name":"cat","status":"checked-in"}`, strings.TrimSpace(resp.Body.String()))
+	resp = walkIn(`{"name":"dan"}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"no_table_available","message":"No table has 1 free seats for the party and 3 reserve seats"}`, strings.TrimSpace(resp.Body.String()))
+	resp = walkIn(`{"name":"dan","reserve":0}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":5,"table":1,"accompanying_guests":0,"name":"dan","status":"checked-in"}`, strings.TrimSpace(resp.Body.String()))
+
+	resp = walkIn(`{"name":"","accompanying_guests":-1,"policy":"random"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"name is a required field, accompanying_guests must be 0 or greater, policy must be one of [smallest_fit largest_free]","details":[{"field":"name","message":"name is a required field"},{"field":"accompanying_guests","message":"accompanying_guests must be 0 or greater"},{"field":"policy","message":"policy must be one of [smallest_fit largest_free]"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+}
+
+// lockRecorder records the locks taken in its transactions
+type lockRecorder struct {
+	*memstore.PartyStore
+	locks *[]string
+}
+
+func (l *lockRecorder) DbTransaction(fn func(models.Party) error) error {
+	return l.PartyStore.DbTransaction(func(tx models.Party) error {
+		return fn(&lockRecorder{PartyStore: tx.(*memstore.PartyStore), locks: l.locks})
+	})
+}
+
+func (l *lockRecorder) DbLockEvent() error {
+	*l.locks = append(*l.locks, "event")
+	return l.PartyStore.DbLockEvent()
+}
+
+func (l *lockRecorder) DbLockTable(id int64) (int64, error) {
+	*l.locks = append(*l.locks, fmt.Sprintf("table %d", id))
+	return l.PartyStore.DbLockTable(id)
+}
+
+func TestLockOrder(t *testing.T) {
+	defer cleanup()
+	addTables(3)
+	addGuest(3, 1, models.ALLOTTED, "john")
+	var locks []string
+	app := &App{Party: &lockRecorder{PartyStore: store, locks: &locks}}
+
+	// only table 3 seats ann, which is allotted to john
+	entry, err, _ := AddToWaitlist(app, WaitlistEntry{Name: "ann", AccompanyingGuests: 4})
+	assert.Nil(t, err)
+	assert.Equal(t, WAITING, entry.Status)
+	assert.Equal(t, []string{"event"}, locks)
+
+	// removing john locks his table before the waitlist locks the tables
+	// ann may take, the event is locked before either
+	locks = nil
+	err, _ = RemoveGuestList(app, GuestRef{Name: "john"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"event", "table 3", "table 3", "table 3"}, locks)
+
+	// a walk-in locks the tables it tries after the event
+	locks = nil
+	guestList, err, _ := WalkInGuest(app, WalkIn{Name: "bob", AccompanyingGuests: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), guestList.Table)
+	assert.Equal(t, []string{"event", "table 1", "table 1", "table 1"}, locks)
+}
+
+func TestWaitlist(t *testing.T) {
+	defer cleanup()
+	addTables(1)
+	addGuest(1, 1, models.ALLOTTED, "john")
+	app := &App{Party: store}
+
+	serve := func(handler http.HandlerFunc, method, target, body string, vars map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		request = mux.SetURLVars(request, vars)
+		responseRecorder := httptest.NewRecorder()
+		handler(responseRecorder, request)
+		return responseRecorder
+	}
+	waitlist := func() string {
+		resp := serve(app.GetWaitlistHandler, http.MethodGet, "/waitlist", "", nil)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		return strings.TrimSpace(resp.Body.String())
+	}
+
+	// the only table is allotted to john
+	resp := serve(app.AddToWaitlistHandler, http.MethodPost, "/waitlist", `{"name":"ann","accompanying_guests":2}`, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":1,"name":"ann","accompanying_guests":2,"priority":0,"status":"waiting","position":1}`, strings.TrimSpace(resp.Body.String()))
+	resp = serve(app.AddToWaitlistHandler, http.MethodPost, "/waitlist", `{"name":"bob","priority":5}`, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":2,"name":"bob","accompanying_guests":0,"priority":5,"status":"waiting","position":2}`, strings.TrimSpace(resp.Body.String()))
+	resp = serve(app.AddToWaitlistHandler, http.MethodPost, "/waitlist", `{"name":"cat","table":9}`, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"unknown_table","message":"Invalid table-id"}`, strings.TrimSpace(resp.Body.String()))
+	resp = serve(app.AddToWaitlistHandler, http.MethodPost, "/waitlist", `{"name":"","priority":-1}`, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"name is a required field, priority must be 0 or greater","details":[{"field":"name","message":"name is a required field"},{"field":"priority","message":"priority must be 0 or greater"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+
+	app.WaitlistOrder = WaitlistPriority
+	assert.Equal(t, `[{"id":2,"name":"bob","accompanying_guests":0,"priority":5,"status":"waiting","position":1},{"id":1,"name":"ann","accompanying_guests":2,"priority":0,"status":"waiting","position":2}]`, waitlist())
+
+	// a new table seating 2 is too small for ann
+	resp = serve(app.AddTableHandler, http.MethodPost, "/tables", `{"capacity":2}`, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `[{"id":1,"name":"ann","accompanying_guests":2,"priority":0,"status":"waiting","position":1},{"id":2,"name":"bob","accompanying_guests":0,"priority":5,"status":"allotted","guest_id":2,"allotted_table":2}]`, waitlist())
+
+	// removing john frees table 1
+	resp = serve(app.RemoveGuestListHandler, http.MethodDelete, "/guest_list/john", "", map[string]string{"name": "john"})
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	assert.Equal(t, `[{"id":1,"name":"ann","accompanying_guests":2,"priority":0,"status":"allotted","guest_id":3,"allotted_table":1},{"id":2,"name":"bob","accompanying_guests":0,"priority":5,"status":"allotted","guest_id":2,"allotted_table":2}]`, waitlist())
+
+	// a shared table that grows seats the party preferring it
+	app.SharedTables = true
+	resp = serve(app.AddToWaitlistHandler, http.MethodPost, "/waitlist", `{"name":"dan","accompanying_guests":2,"table":2}`, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":3,"name":"dan","accompanying_guests":2,"priority":0,"table":2,"status":"waiting","position":1}`, strings.TrimSpace(resp.Body.String()))
+	resp = serve(app.UpdateTableHandler, http.MethodPatch, "/tables/2", `{"capacity":4}`, map[string]string{"id": "2"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = serve(app.GetGuestHandler, http.MethodGet, "/guests/by-id/4", "", map[string]string{"id": "4"})
+	assert.Equal(t, `{"id":4,"table":2,"accompanying_guests":2,"name":"dan","status":"allotted"}`, strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.RemoveFromWaitlistHandler, http.MethodDelete, "/waitlist/1", "", map[string]string{"id": "1"})
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	resp = serve(app.RemoveFromWaitlistHandler, http.MethodDelete, "/waitlist/1", "", map[string]string{"id": "1"})
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, `{"code":"waitlist_entry_not_found","message":"Waitlist entry 1 not found"}`, strings.TrimSpace(resp.Body.String()))
+	resp = serve(app.RemoveFromWaitlistHandler, http.MethodDelete, "/waitlist/x", "", map[string]string{"id": "x"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"invalid_waitlist_id","message":"Invalid waitlist-id"}`, strings.TrimSpace(resp.Body.String()))
+	assert.Equal(t, 3, len(store.Guests()), "ann stays on the guest list")
+
+	// without shared tables a party checking out frees its table for the
+	// head of the waitlist
+	app.SharedTables = false
+	resp = serve(app.AddToWaitlistHandler, http.MethodPost, "/waitlist", `{"name":"eve","accompanying_guests":1}`, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":4,"name":"eve","accompanying_guests":1,"priority":0,"status":"waiting","position":1}`, strings.TrimSpace(resp.Body.String()))
+	resp = serve(app.UpdateGuestHandler, http.MethodPut, "/guests/ann", `{"accompanying_guests":2}`, map[string]string{"name": "ann"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = serve(app.DeleteGuestHandler, http.MethodDelete, "/guests/ann", "", map[string]string{"name": "ann"})
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	resp = serve(app.GetGuestHandler, http.MethodGet, "/guests/by-id/5", "", map[string]string{"id": "5"})
+	assert.Equal(t, `{"id":5,"table":1,"accompanying_guests":1,"name":"eve","status":"allotted"}`, strings.TrimSpace(resp.Body.String()))
+
+	// a table added by an import seats a waiting party, a dry run does not
+	resp = serve(app.AddToWaitlistHandler, http.MethodPost, "/waitlist", `{"name":"fay","accompanying_guests":1}`, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":5,"name":"fay","accompanying_guests":1,"priority":0,"status":"waiting","position":1}`, strings.TrimSpace(resp.Body.String()))
+	importPlan := func(target string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"tables":[{"capacity":4}]}`))
+		request.Header.Set("Content-Type", "application/json")
+		responseRecorder := httptest.NewRecorder()
+		app.ImportHandler(responseRecorder, request)
+		return responseRecorder
+	}
+	resp = importPlan("/import?dry_run=true")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, waitlist(), `{"id":5,"name":"fay","accompanying_guests":1,"priority":0,"status":"waiting","position":1}`)
+	resp = importPlan("/import")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, waitlist(), `{"id":5,"name":"fay","accompanying_guests":1,"priority":0,"status":"allotted","guest_id":6,"allotted_table":3}`)
+}
+
+func TestReassignAtCheckIn(t *testing.T) {
+	defer cleanup()
+	addTables(3)
+	addGuest(1, 1, models.ALLOTTED, "john")
+	addGuest(2, 1, models.ALLOTTED, "mary")
+	app := &App{Party: store}
+
+	serve := func(handler http.HandlerFunc, method, target, body string, vars map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		request = mux.SetURLVars(request, vars)
+		responseRecorder := httptest.NewRecorder()
+		handler(responseRecorder, request)
+		return responseRecorder
+	}
+	checkIn := func(name, body string) *httptest.ResponseRecorder {
+		return serve(app.UpdateGuestHandler, http.MethodPut, "/guests/"+name, body, map[string]string{"name": name})
+	}
+
+	// table 1 seats 3, table 3 seating 5 is the only free table
+	resp := checkIn("john", `{"accompanying_guests":3}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"table_capacity_exceeded","message":"Cannot update number of accompanying guests. Table capacity is 3"}`, strings.TrimSpace(resp.Body.String()))
+	resp = checkIn("john", `{"accompanying_guests":3,"reassign":"suggest"}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"table_capacity_exceeded","message":"Cannot update number of accompanying guests. Table capacity is 3","extra":{"candidate_tables":[{"id":3,"capacity":5,"seats_reserved":0,"seats_occupied":0,"seats_free":5}]}}`,
+		strings.TrimSpace(resp.Body.String()))
+	assert.Equal(t, models.ALLOTTED, store.Guests()[0].Status, "a suggestion checks no guest in")
+
+	resp = checkIn("john", `{"accompanying_guests":3,"reassign":"move"}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"id":1,"name":"john","table":3,"moved_from":1}`, strings.TrimSpace(resp.Body.String()))
+	resp = serve(app.GetGuestHandler, http.MethodGet, "/guests/by-id/1", "", map[string]string{"id": "1"})
+	assert.Equal(t, `{"id":1,"table":3,"accompanying_guests":3,"name":"john","status":"checked-in"}`, strings.TrimSpace(resp.Body.String()))
+
+	resp = serve(app.GetTableMovesHandler, http.MethodGet, "/table_moves", "", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var moves []TableMove
+	must(json.Unmarshal(resp.Body.Bytes(), &moves))
+	assert.Equal(t, 1, len(moves))
+	assert.Equal(t, TableMove{GuestID: 1, Name: "john", From: 1, To: 3, AccompanyingGuests: 3}, TableMove{
+		GuestID: moves[0].GuestID, Name: moves[0].Name, From: moves[0].From, To: moves[0].To, AccompanyingGuests: moves[0].AccompanyingGuests,
+	})
+	assert.True(t, arrivalTime.Equal(moves[0].TimeMoved))
+
+	// no table seats ten
+	resp = checkIn("mary", `{"accompanying_guests":9,"reassign":"move"}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, `{"code":"table_capacity_exceeded","message":"Cannot update number of accompanying guests. Table capacity is 4"}`, strings.TrimSpace(resp.Body.String()))
+	resp = checkIn("mary", `{"reassign":"swap"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"code":"validation_failed","message":"reassign must be one of [move suggest]","details":[{"field":"reassign","message":"reassign must be one of [move suggest]"}]}`,
+		strings.TrimSpace(resp.Body.String()))
+}
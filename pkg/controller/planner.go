@@ -0,0 +1,446 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	models "github.com/getground/tech-tasks/backend/pkg/models"
+)
+
+// nodes of the search tree the planner visits before it settles for the
+// best plan found so far
+const planSearchBudget = 100000
+
+// parties kept together, seated at one table. The stored constraints of
+// the parties can require tags of the table, seat them with a seated guest
+// at table or keep them away from the tables in avoid.
+type planGroup struct {
+	guests []int
+	seats  int64
+	tags   []string
+	table  int64
+	avoid  map[int64]bool
+}
+
+// whether the stored constraints of the group restrict its tables
+func (g planGroup) constrained() bool {
+	return len(g.tags) > 0 || g.table != 0 || len(g.avoid) > 0
+}
+
+// table the planner can seat parties at. Free are the seats not allotted to
+// other parties, single tables seat one party only.
+type planTable struct {
+	id     int64
+	free   int64
+	single bool
+	tags   map[string]bool
+}
+
+// whether the stored constraints of group allow it at table
+func (t planTable) allows(group planGroup) bool {
+	if group.table != 0 && group.table != t.id || group.avoid[t.id] {
+		return false
+	}
+	for _, tag := range group.tags {
+		if !t.tags[tag] {
+			return false
+		}
+	}
+	return true
+}
+
+// seatingSearch assigns the groups to the tables, seating as many guests as
+// possible and then wasting as few seats as possible on the tables used. The
+// groups are tried largest first on the table they fill best, so the first
+// plan found is the best fit decreasing plan. Branch and bound improves on
+// it until the search budget is spent.
+type seatingSearch struct {
+	groups []planGroup
+	tables []planTable
+	// groups with guests kept apart
+	apart [][]bool
+	// whether any group has stored constraints
+	constrained bool
+
+	assigned []int
+	load     []int64
+	seated   [][]int
+	nodes    int
+
+	best         []int
+	bestUnseated int64
+	bestWasted   int64
+}
+
+func newSeatingSearch(groups []planGroup, tables []planTable, apart [][]bool) *seatingSearch {
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].seats > groups[j].seats })
+	search := &seatingSearch{
+		groups:   groups,
+		tables:   tables,
+		assigned: make([]int, len(groups)),
+		load:     make([]int64, len(tables)),
+		seated:   make([][]int, len(tables)),
+	}
+	search.apart = make([][]bool, len(groups))
+	for i := range groups {
+		search.apart[i] = make([]bool, len(groups))
+		for j := range groups {
+			for _, a := range groups[i].guests {
+				for _, b := range groups[j].guests {
+					search.apart[i][j] = search.apart[i][j] || apart[a][b]
+				}
+			}
+		}
+	}
+	for _, g := range groups {
+		search.constrained = search.constrained || g.constrained()
+	}
+	search.bestUnseated = -1
+	return search
+}
+
+// wasted seats of the tables used, at least the seats the groups from
+// group i on cannot fill
+func (s *seatingSearch) wasted(i int) int64 {
+	var wasted int64
+	for t, table := range s.tables {
+		if len(s.seated[t]) > 0 {
+			wasted += table.free - s.load[t]
+		}
+	}
+	for _, g := range s.groups[i:] {
+		wasted -= g.seats
+	}
+	if wasted < 0 {
+		return 0
+	}
+	return wasted
+}
+
+// tables group i fits on, the table left with the fewest free seats first.
+// Of the unused tables with the same seats only one is tried.
+func (s *seatingSearch) candidates(i int) []int {
+	var tables []int
+	unused := map[int64]bool{}
+	for t, table := range s.tables {
+		if s.load[t]+s.groups[i].seats > table.free || !table.allows(s.groups[i]) {
+			continue
+		}
+		if len(s.seated[t]) == 0 {
+			// tables differing in tags or id may suit other groups
+			if unused[table.free] && !s.constrained {
+				continue
+			}
+			unused[table.free] = true
+		} else if table.single {
+			continue
+		}
+		conflict := false
+		for _, g := range s.seated[t] {
+			conflict = conflict || s.apart[i][g]
+		}
+		if !conflict {
+			tables = append(tables, t)
+		}
+	}
+	sort.SliceStable(tables, func(a, b int) bool {
+		return s.tables[tables[a]].free-s.load[tables[a]] < s.tables[tables[b]].free-s.load[tables[b]]
+	})
+	return tables
+}
+
+func (s *seatingSearch) run(i int, unseated int64) {
+	s.nodes++
+	if s.nodes > planSearchBudget && s.best != nil {
+		return
+	}
+	if s.bestUnseated >= 0 {
+		if unseated > s.bestUnseated || unseated == s.bestUnseated && s.wasted(i) >= s.bestWasted {
+			return
+		}
+	}
+	if i == len(s.groups) {
+		s.bestUnseated = unseated
+		s.bestWasted = s.wasted(i)
+		s.best = append(s.best[:0], s.assigned...)
+		return
+	}
+	for _, t := range s.candidates(i) {
+		s.assigned[i] = t
+		s.load[t] += s.groups[i].seats
+		s.seated[t] = append(s.seated[t], i)
+		s.run(i+1, unseated)
+		s.seated[t] = s.seated[t][:len(s.seated[t])-1]
+		s.load[t] -= s.groups[i].seats
+	}
+	s.assigned[i] = -1
+	s.run(i+1, unseated+s.groups[i].seats)
+}
+
+// find the root of the keep together group of guest i
+func findGroup(parent []int, i int) int {
+	for parent[i] != i {
+		parent[i] = parent[parent[i]]
+		i = parent[i]
+	}
+	return i
+}
+
+// propose tables for the guests of request on the free seats of the event,
+// honouring the constraints of the request and the stored constraints of
+// its guests. Guests that cannot be seated are returned as unseated.
+// Without shared tables every party needs a table of its own and no guests
+// can be kept together.
+func PlanSeating(app *App, request PlanRequest) (SeatingProposal, error, int) {
+	proposal := SeatingProposal{
+		Guests:       []ImportGuest{},
+		Unseated:     []UnseatedGuest{},
+		KeepTogether: request.KeepTogether,
+		KeepApart:    request.KeepApart,
+	}
+
+	index := map[string]int{}
+	for i, g := range request.Guests {
+		request.Guests[i].Name = models.DisplayName(g.Name)
+		index[models.NameKey(g.Name)] = i
+		exists, err := app.Party.DbCheckGuestExists(request.Guests[i].Name)
+		if err != nil {
+			return proposal, err, http.StatusInternalServerError
+		}
+		if exists > 0 {
+			return proposal, newError(ErrGuestExists, "Guest %s already added", request.Guests[i].Name), http.StatusConflict
+		}
+	}
+
+	parent := make([]int, len(request.Guests))
+	for i := range parent {
+		parent[i] = i
+	}
+	for i, names := range request.KeepTogether {
+		if len(names) > 1 && !app.SharedTables {
+			f := fmt.Sprintf("keep_together[%d]", i)
+			return proposal, validationError([]FieldError{{Field: f, Message: f + " needs shared tables, a table seats a single party"}}), http.StatusBadRequest
+		}
+		for _, name := range names[1:] {
+			parent[findGroup(parent, index[models.NameKey(name)])] = findGroup(parent, index[models.NameKey(names[0])])
+		}
+	}
+	constraints, err := app.Party.DbGetConstraints()
+	if err != nil {
+		return proposal, err, http.StatusInternalServerError
+	}
+	// stored constraints among guests of the plan join those of the request
+	var storedApart []models.Constraint
+	for _, c := range constraints {
+		a, okA := index[models.NameKey(c.Name)]
+		b, okB := index[models.NameKey(c.Other)]
+		if !okA || !okB {
+			continue
+		}
+		if c.Kind == models.TOGETHER && app.SharedTables {
+			parent[findGroup(parent, b)] = findGroup(parent, a)
+		}
+		if c.Kind == models.APART {
+			storedApart = append(storedApart, c)
+		}
+	}
+	apart := make([][]bool, len(request.Guests))
+	for i := range apart {
+		apart[i] = make([]bool, len(request.Guests))
+	}
+	for _, c := range storedApart {
+		a, b := index[models.NameKey(c.Name)], index[models.NameKey(c.Other)]
+		if findGroup(parent, a) == findGroup(parent, b) {
+			return proposal, &Error{
+				Code:    ErrConstraintViolated,
+				Message: fmt.Sprintf("Cannot plan seating. %s must not share a table with %s", request.Guests[a].Name, request.Guests[b].Name),
+				Extra:   map[string]interface{}{"constraint_ids": []int64{c.Id}},
+			}, http.StatusConflict
+		}
+		apart[a][b], apart[b][a] = true, true
+	}
+	for i, names := range request.KeepApart {
+		for j, a := range names {
+			for _, b := range names[j+1:] {
+				ga, gb := index[models.NameKey(a)], index[models.NameKey(b)]
+				if findGroup(parent, ga) == findGroup(parent, gb) {
+					f := fmt.Sprintf("keep_apart[%d]", i)
+					return proposal, validationError([]FieldError{{Field: f, Message: f + " separates guests kept together"}}), http.StatusBadRequest
+				}
+				apart[ga][gb], apart[gb][ga] = true, true
+			}
+		}
+	}
+
+	var groups []planGroup
+	groupOf := map[int]int{}
+	for i, g := range request.Guests {
+		root := findGroup(parent, i)
+		n, ok := groupOf[root]
+		if !ok {
+			n = len(groups)
+			groupOf[root] = n
+			groups = append(groups, planGroup{})
+		}
+		groups[n].guests = append(groups[n].guests, i)
+		groups[n].seats += g.AccompanyingGuests + 1
+	}
+	for n := range groups {
+		if err := constrainGroup(app.Party, &groups[n], request.Guests, constraints); err != nil {
+			return proposal, err, http.StatusInternalServerError
+		}
+	}
+
+	tables, err, respCode := planTables(app.Party, app.SharedTables)
+	if err != nil {
+		return proposal, err, respCode
+	}
+	var mostFree int64
+	for _, table := range tables {
+		if table.free > mostFree {
+			mostFree = table.free
+		}
+	}
+
+	search := newSeatingSearch(groups, tables, apart)
+	search.run(0, 0)
+	proposal.WastedSeats = search.bestWasted
+
+	tableOf := make([]int64, len(request.Guests))
+	reasons := make([]string, len(request.Guests))
+	for n, group := range search.groups {
+		for _, i := range group.guests {
+			if t := search.best[n]; t >= 0 {
+				tableOf[i] = search.tables[t].id
+			} else if group.seats > mostFree {
+				reasons[i] = fmt.Sprintf("No table has %d free seats", group.seats)
+			} else if group.constrained() {
+				reasons[i] = "No table meets the seating constraints of the party"
+			} else {
+				reasons[i] = "No table left for the party"
+			}
+		}
+	}
+	for i, g := range request.Guests {
+		if tableOf[i] == 0 {
+			proposal.Unseated = append(proposal.Unseated, UnseatedGuest{Name: g.Name, AccompanyingGuests: g.AccompanyingGuests, Reason: reasons[i]})
+			continue
+		}
+		proposal.Guests = append(proposal.Guests, ImportGuest{Name: g.Name, Table: tableOf[i], AccompanyingGuests: g.AccompanyingGuests})
+	}
+	return proposal, nil, http.StatusOK
+}
+
+// restrict the tables of group by the stored constraints of its guests:
+// the tags they require, the table of a seated guest they sit with and the
+// tables of seated guests they are kept apart from
+func constrainGroup(party models.Party, group *planGroup, guests []PlanGuest, constraints []models.Constraint) error {
+	keys := map[string]bool{}
+	for _, i := range group.guests {
+		keys[models.NameKey(guests[i].Name)] = true
+	}
+	tags := map[string]bool{}
+	for _, c := range constraints {
+		other := c.Other
+		if keys[models.NameKey(c.Other)] {
+			other = c.Name
+		} else if !keys[models.NameKey(c.Name)] {
+			continue
+		}
+		switch c.Kind {
+		case models.REQUIRES:
+			if !tags[c.Tag] {
+				tags[c.Tag] = true
+				group.tags = append(group.tags, c.Tag)
+			}
+		case models.TOGETHER, models.APART:
+			seated, err := seatedGuests(party, other, 0)
+			if err != nil {
+				return err
+			}
+			for _, g := range seated {
+				if c.Kind == models.TOGETHER {
+					group.table = g.Table
+					continue
+				}
+				if group.avoid == nil {
+					group.avoid = map[int64]bool{}
+				}
+				group.avoid[g.Table] = true
+			}
+		}
+	}
+	return nil
+}
+
+// tables with free seats for new parties. Tables are only free for a party of
+// their own unless they are shared, shared tables have the seats free that
+// are not allotted to expected or present parties.
+func planTables(party models.Party, sharedTables bool) ([]planTable, error, int) {
+	var tables []planTable
+	stored, err := party.DbGetTables()
+	if err != nil {
+		return tables, err, http.StatusInternalServerError
+	}
+	constraints, err := party.DbGetConstraints()
+	if err != nil {
+		return tables, err, http.StatusInternalServerError
+	}
+	tags := tableTags(constraints)
+	for _, table := range stored {
+		seats, err := seatsOfOtherParties(party, table.Id, 0)
+		if err != nil {
+			return tables, err, http.StatusInternalServerError
+		}
+		if sharedTables {
+			if seats < table.Capacity {
+				tables = append(tables, planTable{id: table.Id, free: table.Capacity - seats, tags: tags[table.Id]})
+			}
+			continue
+		}
+		if seats == 0 {
+			tables = append(tables, planTable{id: table.Id, free: table.Capacity, single: true, tags: tags[table.Id]})
+		}
+	}
+	return tables, nil, http.StatusOK
+}
+
+// add the guests of a proposal as a seating plan import, once the
+// constraints kept in the proposal are checked to hold for its tables
+func CommitSeatingPlan(app *App, proposal SeatingProposal) (ImportReport, error, int) {
+	tableOf := map[string]int64{}
+	for _, g := range proposal.Guests {
+		tableOf[models.NameKey(g.Name)] = g.Table
+	}
+	var details []FieldError
+	for i, names := range proposal.KeepTogether {
+		for j, name := range names {
+			if tableOf[models.NameKey(name)] != tableOf[models.NameKey(names[0])] {
+				f := fmt.Sprintf("keep_together[%d][%d]", i, j)
+				details = append(details, FieldError{Field: f, Message: f + " is not seated with " + names[0]})
+			}
+		}
+	}
+	for i, names := range proposal.KeepApart {
+		seen := map[int64]bool{}
+		for j, name := range names {
+			table, ok := tableOf[models.NameKey(name)]
+			if !ok {
+				continue
+			}
+			if seen[table] {
+				f := fmt.Sprintf("keep_apart[%d][%d]", i, j)
+				details = append(details, FieldError{Field: f, Message: f + " is seated with a guest kept apart"})
+			}
+			seen[table] = true
+		}
+	}
+	if details != nil {
+		return ImportReport{}, validationError(details), http.StatusBadRequest
+	}
+	for i := range proposal.Guests {
+		proposal.Guests[i].Row = i + 1
+	}
+	return ImportSeatingPlan(app, SeatingPlan{Guests: proposal.Guests}, false)
+}
@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	models "github.com/getground/tech-tasks/backend/pkg/models"
+)
+
+// page sizes of the guest list and arrivals. Exports return every guest
+// unless a limit is requested.
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// candidates returned by a guest search
+const (
+	defaultSearchResults = 10
+	maxSearchResults     = 100
+)
+
+// statuses the guest list can be filtered by
+var guestListStatuses = []string{models.ALLOTTED, models.CHECKEDIN, models.CHECKEDOUT, models.NOSHOW, models.CANCELLED}
+
+// cursor is the position of a page, the last guest of the previous page and
+// the order it was taken from
+type cursor struct {
+	Sort        string     `json:"sort"`
+	Desc        bool       `json:"desc,omitempty"`
+	Name        string     `json:"name"`
+	ID          int64      `json:"id,omitempty"`
+	Table       int64      `json:"table,omitempty"`
+	TimeArrived *time.Time `json:"time_arrived,omitempty"`
+}
+
+func encodeCursor(q models.GuestQuery, last models.Guests) string {
+	c := cursor{Sort: q.Sort, Desc: q.Desc, Name: last.Name, ID: last.Id, Table: last.Table}
+	if !last.TimeArrived.IsZero() {
+		c.TimeArrived = &last.TimeArrived
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (cursor, bool) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, false
+	}
+	return c, json.Unmarshal(data, &c) == nil
+}
+
+// guestQuery reads the filters, sort and page of a guest list or arrivals
+// request. statuses are the statuses that can be filtered by and sorts the
+// keys that can be sorted by.
+func guestQuery(r *http.Request, statuses []string, sorts []string, format string) (models.GuestQuery, error) {
+	q := models.GuestQuery{Sort: models.SortByName}
+	params := r.URL.Query()
+	var details []FieldError
+
+	if value := params.Get("status"); value != "" {
+		for _, status := range strings.Split(value, ",") {
+			if !contains(statuses, status) {
+				details = append(details, FieldError{Field: "status", Message: "status must be one of [" + strings.Join(statuses, " ") + "]"})
+				break
+			}
+			q.Statuses = append(q.Statuses, status)
+		}
+	}
+	if value := params.Get("table"); value != "" {
+		table, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || table <= 0 {
+			details = append(details, FieldError{Field: "table", Message: "table must be a table id"})
+		}
+		q.Table = table
+	}
+	if value := params.Get("name_prefix"); value != "" {
+		q.NamePrefix = models.NameKey(value)
+	}
+	for _, field := range []string{"arrived_after", "arrived_before"} {
+		value := params.Get(field)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			details = append(details, FieldError{Field: field, Message: field + " must be a time in RFC 3339 format"})
+		}
+		if field == "arrived_after" {
+			q.ArrivedAfter = t
+		} else {
+			q.ArrivedBefore = t
+		}
+	}
+	if value := params.Get("sort"); value != "" {
+		q.Desc = strings.HasPrefix(value, "-")
+		q.Sort = strings.TrimPrefix(value, "-")
+		if !contains(sorts, q.Sort) {
+			details = append(details, FieldError{Field: "sort", Message: "sort must be one of [" + strings.Join(sorts, " ") + "], with a leading - to sort descending"})
+		}
+	}
+
+	if format == formatJSON {
+		q.Limit = defaultPageSize
+	}
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			details = append(details, FieldError{Field: "limit", Message: "limit must be between 1 and " + strconv.Itoa(maxPageSize)})
+		}
+		q.Limit = limit
+	}
+	if value := params.Get("cursor"); value != "" {
+		c, ok := decodeCursor(value)
+		if !ok || c.Sort != q.Sort || c.Desc != q.Desc {
+			details = append(details, FieldError{Field: "cursor", Message: "cursor is not a page of this sort"})
+		}
+		q.After = &models.Guests{Id: c.ID, Name: c.Name, Table: c.Table}
+		if c.TimeArrived != nil {
+			q.After.TimeArrived = *c.TimeArrived
+		}
+	}
+
+	if details != nil {
+		return q, validationError(details)
+	}
+	return q, nil
+}
+
+// searchQuery reads the search key of the q query parameter and the number
+// of candidates to return of a guest search
+func searchQuery(r *http.Request) (string, int, error) {
+	params := r.URL.Query()
+	var details []FieldError
+	query := models.SearchKey(params.Get("q"))
+	if query == "" {
+		details = append(details, FieldError{Field: "q", Message: "q is required"})
+	}
+	limit := defaultSearchResults
+	if value := params.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxSearchResults {
+			details = append(details, FieldError{Field: "limit", Message: "limit must be between 1 and " + strconv.Itoa(maxSearchResults)})
+		}
+	}
+	if details != nil {
+		return query, limit, validationError(details)
+	}
+	return query, limit, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// setNextLink adds the Link header of the page at cursor next to the
+// response, the request url with the cursor replaced
+func setNextLink(w http.ResponseWriter, r *http.Request, next string) {
+	if next == "" {
+		return
+	}
+	params := r.URL.Query()
+	params.Set("cursor", next)
+	u := url.URL{Path: r.URL.Path, RawQuery: params.Encode()}
+	w.Header().Set("Link", "<"+u.String()+`>; rel="next"`)
+}
@@ -1,132 +1,816 @@
 package controller
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	models "github.com/getground/tech-tasks/backend/pkg/models"
 	"github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
 	"gopkg.in/go-playground/validator.v9"
 	en_translations "gopkg.in/go-playground/validator.v9/translations/en"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// date format of events
+const dateLayout = "2006-01-02"
+
+// longest stayers in the dwell report unless the request sets a limit
+const defaultLongestStayers int64 = 5
+
+type Event struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name" validate:"required,max=100"`
+	Date  string `json:"date,omitempty"`
+	Venue string `json:"venue" validate:"max=255"`
+}
+
+// counts of the tables, seats and guests of an event
+type EventStatistics struct {
+	Tables     int64 `json:"tables"`
+	Capacity   int64 `json:"capacity"`
+	Guests     int64 `json:"guests"`
+	Allotted   int64 `json:"allotted"`
+	CheckedIn  int64 `json:"checked_in"`
+	CheckedOut int64 `json:"checked_out"`
+	NoShow     int64 `json:"no_show"`
+	Cancelled  int64 `json:"cancelled"`
+	SeatsEmpty int64 `json:"seats_empty"`
+}
+
+type EventDetails struct {
+	Event
+	Statistics EventStatistics `json:"statistics"`
+}
+
 type Table struct {
 	ID       int64 `json:"id"`
 	Capacity int64 `json:"capacity" validate:"required,gt=0,lt=4294967295"`
 }
 
+type TableGuest struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	AccompanyingGuests int64  `json:"accompanying_guests"`
+	Status             string `json:"status"`
+}
+
+// Table with its guests and the seats taken by them. Allotted counts the
+// seats of every party that has not checked-out yet and Occupied the seats
+// of the parties present.
+type TableOccupancy struct {
+	ID       int64        `json:"id"`
+	Capacity int64        `json:"capacity"`
+	Allotted int64        `json:"seats_allotted"`
+	Occupied int64        `json:"seats_occupied"`
+	Guests   []TableGuest `json:"guests"`
+}
+
+// guest added or checked-in. Table and MovedFrom are set for a party moved
+// to another table at check-in.
 type GuestName struct {
-	Name string `json:"name" validate:"min=0,max=100"`
+	ID        int64  `json:"id"`
+	Name      string `json:"name" validate:"min=0,max=100"`
+	Table     int64  `json:"table,omitempty"`
+	MovedFrom int64  `json:"moved_from,omitempty"`
+}
+
+// GuestRef refers to a guest by id or, when ID is 0, by name. A name only
+// refers to a guest as long as no other guest has the same name.
+type GuestRef struct {
+	ID   int64
+	Name string
 }
 
+// named accompanying guest with the dietary and accessibility needs to
+// prepare for. Arrived tells whether the companion came with the guest at
+// the latest check-in and is ignored in requests.
+type Companion struct {
+	Name          string `json:"name"`
+	Dietary       string `json:"dietary,omitempty"`
+	Accessibility string `json:"accessibility,omitempty"`
+	Arrived       bool   `json:"arrived"`
+}
+
+// guest list entry. Companions are the accompanying guests known by name,
+// AccompanyingGuests counts them together with the unnamed ones. Warnings
+// are the constraints of the guest the seating does not meet.
 type GuestList struct {
-	Table              int64  `json:"table" validate:"required,gt=0,lt=4294967295"`
+	ID                 int64       `json:"id"`
+	Table              int64       `json:"table" validate:"required,gt=0,lt=4294967295"`
+	AccompanyingGuests int64       `json:"accompanying_guests" validate:"omitempty,gte=0,lt=4294967295"`
+	Name               string      `json:"name"`
+	Status             string      `json:"status,omitempty"`
+	Companions         []Companion `json:"companions,omitempty"`
+	Warnings           []string    `json:"warnings,omitempty"`
+}
+
+// seating constraint. Together and apart name two guests, requires names a
+// guest who needs a table with Tag and table_tag tags Table with Tag.
+type Constraint struct {
+	ID     int64    `json:"id"`
+	Kind   string   `json:"kind" validate:"required,oneof=together apart requires table_tag"`
+	Guests []string `json:"guests,omitempty"`
+	Table  int64    `json:"table,omitempty" validate:"omitempty,gt=0,lt=4294967295"`
+	Tag    string   `json:"tag,omitempty" validate:"omitempty,max=50"`
+}
+
+// fields of a guest list entry to change, nil fields are kept. Companions
+// replace the companions of the guest. Status marks an allotted guest as
+// no-show or cancelled and cannot be combined with the other fields.
+type GuestListUpdate struct {
+	Table              *int64       `json:"table" validate:"omitempty,gt=0,lt=4294967295"`
+	AccompanyingGuests *int64       `json:"accompanying_guests" validate:"omitempty,gte=0,lt=4294967295"`
+	Companions         *[]Companion `json:"companions"`
+	Status             *string      `json:"status" validate:"omitempty,oneof=no-show cancelled"`
+}
+
+// check-in of a guest. CompanionsArrived are the names of the companions who
+// came with the guest, AccompanyingGuests counts them together with the
+// unnamed ones.
+type CheckIn struct {
+	AccompanyingGuests int64    `json:"accompanying_guests"`
+	CompanionsArrived  []string `json:"companions_arrived"`
+	Reassign           string   `json:"reassign,omitempty" validate:"omitempty,oneof=move suggest"`
+}
+
+// what a check-in does when the party grew beyond the seats of its table.
+// Move moves the party to the smallest table it fits on, suggest refuses
+// the check-in with the tables the party fits on.
+const (
+	ReassignMove    = "move"
+	ReassignSuggest = "suggest"
+)
+
+// move of a party to another table at check-in
+type TableMove struct {
+	GuestID            int64     `json:"guest_id"`
+	Name               string    `json:"name"`
+	From               int64     `json:"from_table"`
+	To                 int64     `json:"to_table"`
+	AccompanyingGuests int64     `json:"accompanying_guests"`
+	TimeMoved          time.Time `json:"time_moved"`
+}
+
+// policies choosing the table of a walk-in party
+const (
+	SmallestFit = "smallest_fit"
+	LargestFree = "largest_free"
+)
+
+// WalkInPolicy chooses the table of walk-in guests. Smallest fit takes the
+// table with the fewest free seats the party fits on, largest free the table
+// with the most free seats, smallest fit when Policy is empty. Reserve seats
+// are left free on the table for the parties still expected.
+type WalkInPolicy struct {
+	Policy  string
+	Reserve int64
+}
+
+// guest arriving without a table. Policy and Reserve override the walk-in
+// policy of the server for the guest.
+type WalkIn struct {
+	Name               string `json:"name"`
 	AccompanyingGuests int64  `json:"accompanying_guests" validate:"omitempty,gte=0,lt=4294967295"`
+	Policy             string `json:"policy,omitempty" validate:"omitempty,oneof=smallest_fit largest_free"`
+	Reserve            *int64 `json:"reserve,omitempty" validate:"omitempty,gte=0,lt=4294967295"`
+}
+
+// orders the waiting parties get tables in. Priority serves the parties
+// with the highest priority first and the parties of equal priority in the
+// order they were queued.
+const (
+	WaitlistFIFO     = "fifo"
+	WaitlistPriority = "priority"
+)
+
+// status of a party waiting for a table, a party that got a table is
+// allotted
+const WAITING = "waiting"
+
+// party on the waitlist. Table is the table the party prefers, Position the
+// place of a waiting party in the queue. GuestID and AllottedTable are set
+// once the party got a table.
+type WaitlistEntry struct {
+	ID                 int64  `json:"id"`
 	Name               string `json:"name"`
+	AccompanyingGuests int64  `json:"accompanying_guests" validate:"omitempty,gte=0,lt=4294967295"`
+	Priority           int64  `json:"priority" validate:"omitempty,gte=0,lt=2147483647"`
+	Table              int64  `json:"table,omitempty" validate:"omitempty,gt=0,lt=4294967295"`
+	Status             string `json:"status"`
+	Position           int    `json:"position,omitempty"`
+	GuestID            int64  `json:"guest_id,omitempty"`
+	AllottedTable      int64  `json:"allotted_table,omitempty"`
+}
+
+// a stay of a guest, TimeLeft is nil while the guest is present
+type Visit struct {
+	TimeArrived        time.Time  `json:"time_arrived"`
+	TimeLeft           *time.Time `json:"time_left,omitempty"`
+	AccompanyingGuests int64      `json:"accompanying_guests"`
 }
 
+// TimeArrived and TimeLeft are the arrival and departure of the latest visit
+// of the guest, TimeLeft is nil while the guest is present
 type ArrivedGuests struct {
-	TimeArrived        time.Time `json:"time_arrived"`
-	AccompanyingGuests int64     `json:"accompanying_guests"`
-	Name               string    `json:"name"`
+	ID                 int64       `json:"id"`
+	TimeArrived        time.Time   `json:"time_arrived"`
+	TimeLeft           *time.Time  `json:"time_left,omitempty"`
+	AccompanyingGuests int64       `json:"accompanying_guests"`
+	Name               string      `json:"name"`
+	Present            bool        `json:"present"`
+	Visits             []Visit     `json:"visits"`
+	Companions         []Companion `json:"companions,omitempty"`
+}
+
+// page of the guest list, Next is the cursor of the next page
+type GuestListPage struct {
+	Guests []GuestList
+	Next   string
+}
+
+// page of the arrived guests, Next is the cursor of the next page
+type ArrivedGuestsPage struct {
+	Guests []ArrivedGuests
+	Next   string
+}
+
+// guest found by a search, Score rates the match from 0 to 1 for a name
+// starting with the query
+type SearchResult struct {
+	ID                 int64   `json:"id"`
+	Name               string  `json:"name"`
+	Table              int64   `json:"table"`
+	AccompanyingGuests int64   `json:"accompanying_guests"`
+	Status             string  `json:"status"`
+	Score              float64 `json:"score"`
+}
+
+// time a guest spent at the party over all visits, a present guest has
+// stayed until now
+type GuestStay struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	StaySeconds int64  `json:"stay_seconds"`
+}
+
+// guest at the party and how long since the guest arrived
+type PresentGuest struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	TimeArrived time.Time `json:"time_arrived"`
+	StaySeconds int64     `json:"stay_seconds"`
+}
+
+// Stay lengths of the completed visits, the guests who stayed longest and
+// the guests present
+type DwellReport struct {
+	Visits         int64          `json:"visits"`
+	AverageStay    int64          `json:"average_stay_seconds"`
+	MedianStay     int64          `json:"median_stay_seconds"`
+	LongestStayers []GuestStay    `json:"longest_stayers"`
+	Present        []PresentGuest `json:"present"`
+}
+
+// Seats of a table: the seats reserved for parties that have not arrived
+// yet, the seats occupied by checked-in parties and the seats that can still
+// be allotted. Unless tables are shared, a table allotted to a party has no
+// free seats.
+type TableSeats struct {
+	ID       int64 `json:"id"`
+	Capacity int64 `json:"capacity"`
+	Reserved int64 `json:"seats_reserved"`
+	Occupied int64 `json:"seats_occupied"`
+	Free     int64 `json:"seats_free"`
+}
+
+type StatusTotal struct {
+	Parties int64 `json:"parties"`
+	Seats   int64 `json:"seats"`
+}
+
+// parties and their seats by status
+type StatusTotals struct {
+	Allotted   StatusTotal `json:"allotted"`
+	CheckedIn  StatusTotal `json:"checked_in"`
+	CheckedOut StatusTotal `json:"checked_out"`
+	NoShow     StatusTotal `json:"no_show"`
+	Cancelled  StatusTotal `json:"cancelled"`
 }
 
+// SeatsEmpty is the sum of the free seats of all tables
 type EmptySeats struct {
-	SeatsEmpty int64 `json:"seats_empty"`
+	SeatsEmpty int64        `json:"seats_empty"`
+	Capacity   int64        `json:"capacity"`
+	Reserved   int64        `json:"seats_reserved"`
+	Occupied   int64        `json:"seats_occupied"`
+	Tables     []TableSeats `json:"tables"`
+	Guests     StatusTotals `json:"guests"`
+}
+
+// Table of an imported seating plan. Table is the number of the table in the
+// plan which guests of the plan use to refer to it, Row the position of the
+// table in the import.
+type ImportTable struct {
+	Row      int   `json:"-"`
+	Table    int64 `json:"table"`
+	Capacity int64 `json:"capacity"`
+	errs     []FieldError
+}
+
+// Guest of an imported seating plan. Table is the number of a table of the
+// plan or the id of an existing table.
+type ImportGuest struct {
+	Row                int    `json:"-"`
+	Name               string `json:"name"`
+	Table              int64  `json:"table"`
+	AccompanyingGuests int64  `json:"accompanying_guests"`
+	errs               []FieldError
+}
+
+// tables and guests added by a single import
+type SeatingPlan struct {
+	Tables []ImportTable `json:"tables"`
+	Guests []ImportGuest `json:"guests"`
 }
 
-func validateCapacity(capacity int64) (string, error) {
-	return validatePositiveInteger(capacity, false)
+// result of a row of an import, the error of the row or the id of the table
+// the row added or allotted to the guest and the id of the guest added
+type ImportRow struct {
+	Row     int          `json:"row"`
+	Kind    string       `json:"kind"`
+	Name    string       `json:"name,omitempty"`
+	Table   int64        `json:"table,omitempty"`
+	GuestID int64        `json:"guest_id,omitempty"`
+	Code    string       `json:"code,omitempty"`
+	Message string       `json:"message,omitempty"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun bool        `json:"dry_run"`
+	Tables int         `json:"tables"`
+	Guests int         `json:"guests"`
+	Rows   []ImportRow `json:"rows"`
+}
+
+// guest the seating planner is asked to find a table for
+type PlanGuest struct {
+	Name               string `json:"name"`
+	AccompanyingGuests int64  `json:"accompanying_guests"`
+}
+
+// guests to seat on the free seats of the tables. Every list of KeepTogether
+// names guests to seat at the same table, every list of KeepApart guests no
+// two of which sit at the same table.
+type PlanRequest struct {
+	Guests       []PlanGuest `json:"guests"`
+	KeepTogether [][]string  `json:"keep_together"`
+	KeepApart    [][]string  `json:"keep_apart"`
+}
+
+// guest of a plan request the planner found no table for
+type UnseatedGuest struct {
+	Name               string `json:"name"`
+	AccompanyingGuests int64  `json:"accompanying_guests"`
+	Reason             string `json:"reason"`
 }
 
-func validateAccompanyingGuests(accompanyingGuests int64) (string, error) {
-	return validatePositiveInteger(accompanyingGuests, true)
+// seating plan proposed by the planner. Guests can be committed as they
+// are, WastedSeats are the seats the plan leaves free on the tables it uses.
+// The constraints of the request are kept to be checked again on commit.
+type SeatingProposal struct {
+	WastedSeats  int64           `json:"wasted_seats"`
+	Guests       []ImportGuest   `json:"guests"`
+	Unseated     []UnseatedGuest `json:"unseated"`
+	KeepTogether [][]string      `json:"keep_together,omitempty"`
+	KeepApart    [][]string      `json:"keep_apart,omitempty"`
 }
 
-func validatePositiveInteger(integer int64, zeroAllowed bool) (string, error) {
+// columns of a seating plan in CSV
+var importColumns = map[string]bool{"table": true, "capacity": true, "name": true, "accompanying_guests": true}
+
+// ParseSeatingPlanCSV reads a seating plan from CSV with a header line. Rows
+// with a name are guests, the other rows are tables. Rows are numbered by
+// their line.
+func ParseSeatingPlanCSV(r io.Reader) (SeatingPlan, error) {
+	var plan SeatingPlan
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return plan, newError(ErrInvalidCSV, "Invalid CSV body: %v", err)
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if !importColumns[header[i]] {
+			return plan, newError(ErrInvalidCSV, "Invalid CSV body: unknown column %s", column)
+		}
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return plan, nil
+		}
+		if err != nil {
+			return plan, newError(ErrInvalidCSV, "Invalid CSV body: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		values := map[string]string{}
+		for i, value := range record {
+			values[header[i]] = strings.TrimSpace(value)
+		}
+		var errs []FieldError
+		if values["name"] == "" {
+			plan.Tables = append(plan.Tables, ImportTable{
+				Row:      line,
+				Table:    parseImportNumber("table", values["table"], &errs),
+				Capacity: parseImportNumber("capacity", values["capacity"], &errs),
+				errs:     errs,
+			})
+			continue
+		}
+		if values["capacity"] != "" {
+			errs = append(errs, FieldError{Field: "capacity", Message: "capacity must be empty for a guest"})
+		}
+		plan.Guests = append(plan.Guests, ImportGuest{
+			Row:                line,
+			Name:               values["name"],
+			Table:              parseImportNumber("table", values["table"], &errs),
+			AccompanyingGuests: parseImportNumber("accompanying_guests", values["accompanying_guests"], &errs),
+			errs:               errs,
+		})
+	}
+}
+
+// number in column field of a CSV row, empty values are 0
+func parseImportNumber(field string, value string, errs *[]FieldError) int64 {
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		*errs = append(*errs, FieldError{Field: field, Message: field + " must be a number"})
+	}
+	return n
+}
+
+// ParseSeatingPlanJSON reads a seating plan from JSON, rows are numbered
+// from 1 within the tables and within the guests
+func ParseSeatingPlanJSON(data []byte) (SeatingPlan, error) {
+	var plan SeatingPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return plan, newError(ErrInvalidJSON, "Invalid JSON body: %v", err)
+	}
+	for i := range plan.Tables {
+		plan.Tables[i].Row = i + 1
+	}
+	for i := range plan.Guests {
+		plan.Guests[i].Row = i + 1
+	}
+	return plan, nil
+}
+
+func validateCapacity(capacity int64) ([]FieldError, error) {
+	return validatePositiveInteger("capacity", capacity, false)
+}
+
+func validateAccompanyingGuests(accompanyingGuests int64) ([]FieldError, error) {
+	return validatePositiveInteger("accompanying_guests", accompanyingGuests, true)
+}
+
+// validator that names struct fields after their json key
+func newValidator() (*validator.Validate, ut.Translator, error) {
 	translator := en.New()
 	uni := ut.New(translator, translator)
 
 	trans, found := uni.GetTranslator("en")
 	if !found {
-		return "", fmt.Errorf("translator not found")
+		return nil, nil, fmt.Errorf("translator not found")
 	}
 
 	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
 
 	if err := en_translations.RegisterDefaultTranslations(v, trans); err != nil {
-		return "", err
+		return nil, nil, err
+	}
+	return v, trans, nil
+}
+
+func validatePositiveInteger(field string, integer int64, zeroAllowed bool) ([]FieldError, error) {
+	v, trans, err := newValidator()
+	if err != nil {
+		return nil, err
 	}
 
-	var err error
 	if zeroAllowed {
 		err = v.Var(integer, "gte=0,lt=4294967295")
 	} else {
 		err = v.Var(integer, "gt=0,lt=4294967295")
 	}
-	errs := translateError(err, trans)
-	var strerrs string
-	for _, e := range errs {
-		strerrs += e.Error()
+	return translateError(err, trans, field), nil
+}
+
+func validateLimit(limit int64) ([]FieldError, error) {
+	return validatePositiveInteger("limit", limit, false)
+}
+
+func validateName(name string) ([]FieldError, error) {
+	v, trans, err := newValidator()
+	if err != nil {
+		return nil, err
 	}
-	return strerrs, nil
+
+	err = v.Var(name, "required,min=0,max=100")
+	return translateError(err, trans, "name"), nil
 }
 
-func validateName(name string) (string, error) {
-	translator := en.New()
-	uni := ut.New(translator, translator)
+func validateGuestList(guestList GuestList) ([]FieldError, error) {
+	details, err := validateStruct(guestList)
+	if err != nil {
+		return nil, err
+	}
+	more, err := validateCompanions(guestList.Companions)
+	if err != nil {
+		return nil, err
+	}
+	details = append(details, more...)
+	if guestList.AccompanyingGuests != 0 {
+		details = append(details, checkCompanionCount(guestList.AccompanyingGuests, len(guestList.Companions))...)
+	}
+	return details, nil
+}
 
-	trans, found := uni.GetTranslator("en")
-	if !found {
-		return "", fmt.Errorf("translator not found")
+func validateGuestListUpdate(update GuestListUpdate) ([]FieldError, error) {
+	if update.Status != nil {
+		if update.Table != nil || update.AccompanyingGuests != nil || update.Companions != nil {
+			return []FieldError{{Field: "status", Message: "status cannot be changed together with table, accompanying_guests or companions"}}, nil
+		}
+		return validateStruct(update)
+	}
+	if update.Table == nil && update.AccompanyingGuests == nil && update.Companions == nil {
+		return []FieldError{{Field: "table", Message: "table, accompanying_guests, companions or status is required"}}, nil
+	}
+	details, err := validateStruct(update)
+	if err != nil || update.Companions == nil {
+		return details, err
 	}
+	more, err := validateCompanions(*update.Companions)
+	if err != nil {
+		return nil, err
+	}
+	details = append(details, more...)
+	if update.AccompanyingGuests != nil {
+		details = append(details, checkCompanionCount(*update.AccompanyingGuests, len(*update.Companions))...)
+	}
+	return details, nil
+}
 
-	v := validator.New()
+// at most this many companions can be named for a guest
+const maxCompanions = 100
 
-	if err := en_translations.RegisterDefaultTranslations(v, trans); err != nil {
-		return "", err
+// companions need a name no other companion of the guest has, the names are
+// compared by their name key
+func validateCompanions(companions []Companion) ([]FieldError, error) {
+	if len(companions) > maxCompanions {
+		return []FieldError{{Field: "companions", Message: fmt.Sprintf("companions must contain at most %d items", maxCompanions)}}, nil
 	}
+	v, trans, err := newValidator()
+	if err != nil {
+		return nil, err
+	}
+	var details []FieldError
+	names := map[string]bool{}
+	for i, c := range companions {
+		field := fmt.Sprintf("companions[%d].", i)
+		name := models.DisplayName(c.Name)
+		details = append(details, translateError(v.Var(name, "required,max=100"), trans, field+"name")...)
+		details = append(details, translateError(v.Var(c.Dietary, "max=255"), trans, field+"dietary")...)
+		details = append(details, translateError(v.Var(c.Accessibility, "max=255"), trans, field+"accessibility")...)
+		key := models.NameKey(name)
+		if name != "" && names[key] {
+			details = append(details, FieldError{Field: field + "name", Message: field + "name is the name of another companion"})
+		}
+		names[key] = true
+	}
+	return details, nil
+}
 
-	err := v.Var(name, "required,min=0,max=100")
-	errs := translateError(err, trans)
-	strerrs := ""
-	for _, e := range errs {
-		strerrs += e.Error()
+// accompanying_guests counts the named companions, a lower count is refused.
+// Negative counts are refused on their own.
+func checkCompanionCount(accompanyingGuests int64, companions int) []FieldError {
+	if accompanyingGuests < 0 || accompanyingGuests >= int64(companions) {
+		return nil
 	}
-	return strerrs, nil
+	return []FieldError{{Field: "accompanying_guests", Message: "accompanying_guests must be at least the number of companions"}}
 }
 
-func validateGuestList(guestList GuestList) (string, error) {
-	translator := en.New()
-	uni := ut.New(translator, translator)
+func validateWalkIn(walkIn WalkIn) ([]FieldError, error) {
+	details, err := validateName(walkIn.Name)
+	if err != nil {
+		return nil, err
+	}
+	more, err := validateStruct(walkIn)
+	if err != nil {
+		return nil, err
+	}
+	return append(details, more...), nil
+}
 
-	trans, found := uni.GetTranslator("en")
-	if !found {
-		return "", fmt.Errorf("translator not found")
+func validateWaitlistEntry(entry WaitlistEntry) ([]FieldError, error) {
+	details, err := validateName(entry.Name)
+	if err != nil {
+		return nil, err
 	}
+	more, err := validateStruct(entry)
+	if err != nil {
+		return nil, err
+	}
+	return append(details, more...), nil
+}
 
-	v := validator.New()
+func validateCheckIn(checkIn CheckIn) ([]FieldError, error) {
+	details, err := validateAccompanyingGuests(checkIn.AccompanyingGuests)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for i, name := range checkIn.CompanionsArrived {
+		key := models.NameKey(name)
+		if names[key] {
+			field := fmt.Sprintf("companions_arrived[%d]", i)
+			details = append(details, FieldError{Field: field, Message: field + " names a companion twice"})
+		}
+		names[key] = true
+	}
+	if checkIn.AccompanyingGuests != 0 {
+		details = append(details, checkCompanionCount(checkIn.AccompanyingGuests, len(checkIn.CompanionsArrived))...)
+	}
+	more, err := validateStruct(checkIn)
+	if err != nil {
+		return nil, err
+	}
+	return append(details, more...), nil
+}
 
-	if err := en_translations.RegisterDefaultTranslations(v, trans); err != nil {
-		return "", err
+// at most this many guests are seated by a single plan request
+const maxPlanGuests = 500
+
+// guests of a plan need names no other guest of the plan has and the
+// constraints name guests of the plan
+func validatePlanRequest(request PlanRequest) ([]FieldError, error) {
+	var details []FieldError
+	if len(request.Guests) == 0 {
+		details = append(details, FieldError{Field: "guests", Message: "guests must contain at least 1 item"})
 	}
+	if len(request.Guests) > maxPlanGuests {
+		return []FieldError{{Field: "guests", Message: fmt.Sprintf("guests must contain at most %d items", maxPlanGuests)}}, nil
+	}
+	names := map[string]bool{}
+	for i, g := range request.Guests {
+		field := fmt.Sprintf("guests[%d].", i)
+		name := models.DisplayName(g.Name)
+		more, err := validateName(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range more {
+			details = append(details, FieldError{Field: field + d.Field, Message: field + d.Message})
+		}
+		more, err = validateAccompanyingGuests(g.AccompanyingGuests)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range more {
+			details = append(details, FieldError{Field: field + d.Field, Message: field + d.Message})
+		}
+		key := models.NameKey(name)
+		if name != "" && names[key] {
+			details = append(details, FieldError{Field: field + "name", Message: field + "name is the name of another guest of the plan"})
+		}
+		names[key] = true
+	}
+	details = append(details, validatePlanConstraints("keep_together", request.KeepTogether, names)...)
+	details = append(details, validatePlanConstraints("keep_apart", request.KeepApart, names)...)
+	return details, nil
+}
+
+// every constraint names at least two guests of the plan, names holds the
+// name keys of the guests of the plan
+func validatePlanConstraints(field string, constraints [][]string, names map[string]bool) []FieldError {
+	var details []FieldError
+	for i, constraint := range constraints {
+		if len(constraint) < 2 {
+			f := fmt.Sprintf("%s[%d]", field, i)
+			details = append(details, FieldError{Field: f, Message: f + " must contain at least 2 items"})
+		}
+		for j, name := range constraint {
+			if !names[models.NameKey(name)] {
+				f := fmt.Sprintf("%s[%d][%d]", field, i, j)
+				details = append(details, FieldError{Field: f, Message: f + " is not a guest of the plan"})
+			}
+		}
+	}
+	return details
+}
+
+// guests needed by every kind of constraint
+var constraintGuests = map[string]int{
+	models.TOGETHER: 2,
+	models.APART:    2,
+	models.REQUIRES: 1,
+	models.TABLETAG: 0,
+}
+
+func validateConstraint(constraint Constraint) ([]FieldError, error) {
+	details, err := validateStruct(constraint)
+	if err != nil || details != nil {
+		return details, err
+	}
+	if n := constraintGuests[constraint.Kind]; len(constraint.Guests) != n {
+		message := fmt.Sprintf("guests must contain %d items for %s", n, constraint.Kind)
+		if n == 0 {
+			message = "guests must be empty for " + constraint.Kind
+		}
+		details = append(details, FieldError{Field: "guests", Message: message})
+	}
+	for i, name := range constraint.Guests {
+		more, err := validateName(models.DisplayName(name))
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range more {
+			field := fmt.Sprintf("guests[%d]", i)
+			details = append(details, FieldError{Field: field, Message: field + strings.TrimPrefix(d.Message, "name")})
+		}
+	}
+	if len(constraint.Guests) == 2 && models.NameKey(constraint.Guests[0]) == models.NameKey(constraint.Guests[1]) {
+		details = append(details, FieldError{Field: "guests", Message: "guests must name two different guests"})
+	}
+	switch constraint.Kind {
+	case models.TOGETHER, models.APART:
+		if constraint.Table != 0 || constraint.Tag != "" {
+			details = append(details, FieldError{Field: "kind", Message: constraint.Kind + " takes no table or tag"})
+		}
+	case models.REQUIRES, models.TABLETAG:
+		if strings.TrimSpace(constraint.Tag) == "" {
+			details = append(details, FieldError{Field: "tag", Message: "tag is a required field"})
+		}
+		if (constraint.Kind == models.TABLETAG) != (constraint.Table != 0) {
+			details = append(details, FieldError{Field: "table", Message: "table is only and always given for table_tag"})
+		}
+	}
+	return details, nil
+}
 
-	err := v.Struct(guestList)
-	errs := translateError(err, trans)
-	strerrs := ""
-	for _, e := range errs {
-		strerrs += e.Error()
+func validateEvent(event Event) ([]FieldError, error) {
+	details, err := validateStruct(event)
+	if err != nil {
+		return nil, err
 	}
-	return strerrs, nil
+	if event.Date != "" {
+		if _, err := time.Parse(dateLayout, event.Date); err != nil {
+			details = append(details, FieldError{Field: "date", Message: "date must be a date in the format YYYY-MM-DD"})
+		}
+	}
+	return details, nil
+}
+
+func validateStruct(s interface{}) ([]FieldError, error) {
+	v, trans, err := newValidator()
+	if err != nil {
+		return nil, err
+	}
+
+	err = v.Struct(s)
+	return translateError(err, trans, ""), nil
 }
 
-func translateError(err error, trans ut.Translator) (errs []error) {
+// translate the validation errors, field names the value validated by
+// validator.Var which has no field of its own
+func translateError(err error, trans ut.Translator, field string) (errs []FieldError) {
 	if err == nil {
 		return nil
 	}
 	validatorErrs := err.(validator.ValidationErrors)
 	for _, e := range validatorErrs {
-		translatedErr := fmt.Errorf(e.Translate(trans))
-		errs = append(errs, translatedErr)
+		fe := FieldError{Field: e.Field(), Message: e.Translate(trans)}
+		if field != "" {
+			fe.Field = field
+			fe.Message = field + fe.Message
+		}
+		errs = append(errs, fe)
 	}
 	return errs
 }
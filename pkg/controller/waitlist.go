@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"net/http"
+	"sort"
+
+	models "github.com/getground/tech-tasks/backend/pkg/models"
+)
+
+// queue a guest party for a table, the party gets a table right away when
+// one is free
+func AddToWaitlist(app *App, entry WaitlistEntry) (WaitlistEntry, error, int) {
+	respCode := http.StatusOK
+	err := app.Party.DbTransaction(func(tx models.Party) error {
+		if err := tx.DbLockEvent(); err != nil {
+			return err
+		}
+		var err error
+		entry, err, respCode = addToWaitlist(tx, entry, app.SharedTables, app.WaitlistOrder)
+		return err
+	})
+	if err != nil && respCode == http.StatusOK {
+		respCode = http.StatusInternalServerError
+	}
+	return entry, err, respCode
+}
+
+func addToWaitlist(party models.Party, entry WaitlistEntry, sharedTables bool, order string) (WaitlistEntry, error, int) {
+	if entry.Table != 0 {
+		exists, err := party.DbCheckTableExists(entry.Table)
+		if err != nil {
+			return entry, err, http.StatusInternalServerError
+		}
+		if exists == 0 {
+			return entry, newError(ErrUnknownTable, "Invalid table-id"), http.StatusBadRequest
+		}
+	}
+	id, err := party.DbAddWaitlistEntry(models.WaitlistEntry{
+		Name:               entry.Name,
+		AccompanyingGuests: entry.AccompanyingGuests,
+		Priority:           entry.Priority,
+		Table:              entry.Table,
+	})
+	if err != nil {
+		return entry, err, http.StatusInternalServerError
+	}
+	if err, respCode := processWaitlist(party, sharedTables, order); err != nil {
+		return entry, err, respCode
+	}
+	entries, err, respCode := getWaitlist(party, order)
+	if err != nil {
+		return entry, err, respCode
+	}
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil, http.StatusOK
+		}
+	}
+	return entry, newError(ErrWaitlistNotFound, "Waitlist entry %d not found", id), http.StatusNotFound
+}
+
+// parties on the waitlist, the waiting parties in the order they get tables
+// and then the allotted parties in the order they were queued
+func GetWaitlist(app *App) ([]WaitlistEntry, error, int) {
+	return getWaitlist(app.Party, app.WaitlistOrder)
+}
+
+func getWaitlist(party models.Party, order string) ([]WaitlistEntry, error, int) {
+	entries := []WaitlistEntry{}
+	stored, err := party.DbGetWaitlist()
+	if err != nil {
+		return entries, err, http.StatusInternalServerError
+	}
+	for i, e := range waitingParties(stored, order) {
+		entries = append(entries, WaitlistEntry{
+			ID:                 e.Id,
+			Name:               e.Name,
+			AccompanyingGuests: e.AccompanyingGuests,
+			Priority:           e.Priority,
+			Table:              e.Table,
+			Status:             WAITING,
+			Position:           i + 1,
+		})
+	}
+	for _, e := range stored {
+		if e.GuestId == 0 {
+			continue
+		}
+		guest, err := party.DbGetGuest(e.GuestId)
+		if err != nil {
+			return entries, err, http.StatusInternalServerError
+		}
+		entries = append(entries, WaitlistEntry{
+			ID:                 e.Id,
+			Name:               e.Name,
+			AccompanyingGuests: e.AccompanyingGuests,
+			Priority:           e.Priority,
+			Table:              e.Table,
+			Status:             models.ALLOTTED,
+			GuestID:            e.GuestId,
+			AllottedTable:      guest.Table,
+		})
+	}
+	return entries, nil, http.StatusOK
+}
+
+// take a party off the waitlist, a party that got a table stays on the
+// guest list
+func RemoveFromWaitlist(app *App, id int64) (error, int) {
+	deleted, err := app.Party.DbDeleteWaitlistEntry(id)
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if deleted == 0 {
+		return newError(ErrWaitlistNotFound, "Waitlist entry %d not found", id), http.StatusNotFound
+	}
+	return nil, http.StatusOK
+}
+
+// the parties of entries still waiting in the order they get tables
+func waitingParties(entries []models.WaitlistEntry, order string) []models.WaitlistEntry {
+	var waiting []models.WaitlistEntry
+	for _, e := range entries {
+		if e.GuestId == 0 {
+			waiting = append(waiting, e)
+		}
+	}
+	if order == WaitlistPriority {
+		sort.SliceStable(waiting, func(i, j int) bool { return waiting[i].Priority > waiting[j].Priority })
+	}
+	return waiting
+}
+
+// allot tables to the waiting parties in order. A party no table is free for
+// keeps its place and the parties after it are still served. Called whenever
+// seats may have been freed, within the transaction that freed them. The
+// transaction holds the event lock, so its tables can be locked in any order.
+func processWaitlist(party models.Party, sharedTables bool, order string) (error, int) {
+	entries, err := party.DbGetWaitlist()
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	for _, e := range waitingParties(entries, order) {
+		guestList := GuestList{Name: e.Name, AccompanyingGuests: e.AccompanyingGuests, Table: e.Table}
+		table, err, respCode := chooseTable(party, guestList, WalkInPolicy{}, sharedTables)
+		if err != nil {
+			return err, respCode
+		}
+		if table == 0 {
+			continue
+		}
+		guestList.Table = table
+		guestName, err, respCode := addGuestList(party, guestList, false, sharedTables)
+		if err != nil {
+			return err, respCode
+		}
+		allotted, err := party.DbAllotWaitlistEntry(e.Id, guestName.ID)
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+		// a concurrent transaction served or removed the party
+		if allotted == 0 {
+			if err = party.DbDeleteGuest(guestName.ID); err != nil {
+				return err, http.StatusInternalServerError
+			}
+		}
+	}
+	return nil, http.StatusOK
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	models "github.com/getground/tech-tasks/backend/pkg/models"
+)
+
+// allot a table to a guest arriving without one and check the guest in,
+// within a single transaction. The table is chosen by the walk-in policy of
+// the server unless the guest overrides it.
+func WalkInGuest(app *App, walkIn WalkIn) (GuestList, error, int) {
+	var guestList GuestList
+	policy := app.WalkIn
+	if walkIn.Policy != "" {
+		policy.Policy = walkIn.Policy
+	}
+	if walkIn.Reserve != nil {
+		policy.Reserve = *walkIn.Reserve
+	}
+	respCode := http.StatusOK
+	err := app.Party.DbTransaction(func(tx models.Party) error {
+		if err := tx.DbLockEvent(); err != nil {
+			return err
+		}
+		var err error
+		guestList, err, respCode = walkInGuest(tx, walkIn, policy, app.SharedTables)
+		return err
+	})
+	if err != nil && respCode == http.StatusOK {
+		respCode = http.StatusInternalServerError
+	}
+	return guestList, err, respCode
+}
+
+func walkInGuest(party models.Party, walkIn WalkIn, policy WalkInPolicy, sharedTables bool) (GuestList, error, int) {
+	guestList := GuestList{Name: walkIn.Name, AccompanyingGuests: walkIn.AccompanyingGuests}
+	table, err, respCode := chooseTable(party, guestList, policy, sharedTables)
+	if err != nil {
+		return guestList, err, respCode
+	}
+	if table == 0 {
+		seats := walkIn.AccompanyingGuests + 1
+		if policy.Reserve > 0 {
+			return guestList, newError(ErrNoTableAvailable, "No table has %d free seats for the party and %d reserve seats", seats, policy.Reserve), http.StatusConflict
+		}
+		return guestList, newError(ErrNoTableAvailable, "No table has %d free seats for the party", seats), http.StatusConflict
+	}
+
+	guestList.Table = table
+	guestName, err, respCode := addGuestList(party, guestList, false, sharedTables)
+	if err != nil {
+		return guestList, err, respCode
+	}
+	guestList.ID = guestName.ID
+	checkIn := CheckIn{AccompanyingGuests: walkIn.AccompanyingGuests}
+	if _, err, respCode := updateGuestList(party, GuestRef{ID: guestList.ID}, checkIn, sharedTables); err != nil {
+		return guestList, err, respCode
+	}
+	guestList.Status = models.CHECKEDIN
+	return guestList, nil, http.StatusOK
+}
+
+// tables the empty seats report has the free seats for the party of
+// guestList on, in the order policy tries them. Only the table of guestList
+// is considered when it has one.
+func freeTables(party models.Party, guestList GuestList, policy WalkInPolicy, sharedTables bool) ([]TableSeats, error) {
+	seats := guestList.AccompanyingGuests + 1
+	tables, err := party.DbGetTableSeats()
+	if err != nil {
+		return nil, err
+	}
+	var candidates []TableSeats
+	for _, table := range tables {
+		if guestList.Table != 0 && table.Id != guestList.Table {
+			continue
+		}
+		free := freeSeats(table, sharedTables)
+		if free-seats >= policy.Reserve {
+			candidates = append(candidates, TableSeats{
+				ID:       table.Id,
+				Capacity: table.Capacity,
+				Reserved: table.AllottedSeats,
+				Occupied: table.CheckedInSeats,
+				Free:     free,
+			})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if policy.Policy == LargestFree {
+			return candidates[i].Free > candidates[j].Free
+		}
+		return candidates[i].Free < candidates[j].Free
+	})
+	return candidates, nil
+}
+
+// table policy chooses for the party of guestList, 0 when no table has the
+// free seats or every table breaks a constraint of the guest. Only the table
+// of guestList is tried when it has one. The tables tried stay locked, the
+// caller holds the event lock so they can be locked in the order tried.
+func chooseTable(party models.Party, guestList GuestList, policy WalkInPolicy, sharedTables bool) (int64, error, int) {
+	// the free seats of the empty seats report decide the order the tables
+	// are tried in, every table is checked again once it is locked
+	candidates, err := freeTables(party, guestList, policy, sharedTables)
+	if err != nil {
+		return 0, err, http.StatusInternalServerError
+	}
+	for _, table := range candidates {
+		guestList.Table = table.ID
+		if _, err := party.DbLockTable(table.ID); err != nil {
+			return 0, err, http.StatusInternalServerError
+		}
+		// the reserve seats count as part of the party while checking
+		reserved := guestList
+		reserved.AccompanyingGuests += policy.Reserve
+		err, respCode := checkTableAvailable(party, reserved, sharedTables)
+		if err == nil {
+			err, respCode = checkConstraints(party, guestList, sharedTables)
+		}
+		if errors.Is(err, ErrTableCapacity) || errors.Is(err, ErrTableAllotted) || errors.Is(err, ErrConstraintViolated) {
+			continue
+		}
+		if err != nil {
+			return 0, err, respCode
+		}
+		return table.ID, nil, http.StatusOK
+	}
+	return 0, nil, http.StatusOK
+}
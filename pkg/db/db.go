@@ -6,41 +6,86 @@ import (
 	"os"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 	//	"github.com/golang/protobuf/jsonpb"
 	//	"k8s.io/klog"
 )
 
 var (
+	dbdriver   = ""
 	dbhost     = ""
 	dbport     = ""
 	dbuser     = ""
 	dbpassword = ""
 	dbname     = ""
+	dbpath     = ""
+	dbsslmode  = ""
 )
 
 func init() {
+	dbdriver = os.Getenv("DBDRIVER")
 	dbhost = os.Getenv("DBHOST")
 	dbport = os.Getenv("DBPORT")
 	dbuser = os.Getenv("DBUSER")
 	dbpassword = os.Getenv("DBPASSWORD")
 	dbname = os.Getenv("DBNAME")
+	dbpath = os.Getenv("DBPATH")
+	dbsslmode = os.Getenv("DBSSLMODE")
+	if dbdriver == "" {
+		dbdriver = MySQL
+	}
+	if dbpath == "" {
+		dbpath = "party.db"
+	}
+	if dbsslmode == "" {
+		dbsslmode = "disable"
+	}
 }
 
+// Supported values of DBDRIVER, they double as the sql dialect of the
+// database.
 const (
-	dbDriver = "mysql"
+	MySQL    = "mysql"
+	Postgres = "postgres"
+	SQLite   = "sqlite"
 )
 
+// Driver returns the database driver selected through DBDRIVER.
+func Driver() string {
+	return dbdriver
+}
+
 func ConnectToDB() (*sql.DB, error) {
-	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		dbuser, dbpassword, dbhost, dbport, dbname)
-	db, err := sql.Open(dbDriver, dsn)
+	var dsn string
+	switch dbdriver {
+	case MySQL:
+		dsn = fmt.Sprintf(
+			"%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+			dbuser, dbpassword, dbhost, dbport, dbname)
+	case Postgres:
+		dsn = fmt.Sprintf(
+			"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+			dbhost, dbport, dbuser, dbpassword, dbname, dbsslmode)
+	case SQLite:
+		dsn = SQLiteDSN(dbpath)
+	default:
+		return nil, fmt.Errorf("unsupported DBDRIVER %s", dbdriver)
+	}
+	db, err := sql.Open(dbdriver, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("DB open failed: %v", err)
 	}
 	return db, nil
 }
 
+// SQLiteDSN returns the dsn of the sqlite database file at path with foreign
+// keys enforced. Transactions take the write lock when they begin so that
+// concurrent transactions are serialized.
+func SQLiteDSN(path string) string {
+	return fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate", path)
+}
+
 func CloseConnection(db *sql.DB) {
 	db.Close()
 }
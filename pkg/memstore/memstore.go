@@ -0,0 +1,769 @@
+// Package memstore is an in-memory implementation of models.Party. It follows
+// the semantics of the sql queries in models.PartyModel and is safe for
+// concurrent use, so it can back the server for demos and act as the fake
+// storage in tests.
+package memstore
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	models "github.com/getground/tech-tasks/backend/pkg/models"
+)
+
+// table and guest are stored together with the event they belong to
+type table struct {
+	event int64
+	models.Table
+}
+
+type guest struct {
+	event int64
+	models.Guests
+}
+
+type visit struct {
+	event int64
+	models.Visit
+}
+
+type companion struct {
+	event int64
+	models.Companion
+}
+
+type constraint struct {
+	event int64
+	models.Constraint
+}
+
+type waitlistEntry struct {
+	event int64
+	models.WaitlistEntry
+}
+
+type tableMove struct {
+	event int64
+	models.TableMove
+}
+
+type data struct {
+	events           []models.Event
+	tables           []table
+	guests           []guest
+	visits           []visit
+	companions       []companion
+	constraints      []constraint
+	waitlist         []waitlistEntry
+	tableMoves       []tableMove
+	lastTableId      int64
+	lastGuestId      int64
+	lastConstraintId int64
+	lastWaitlistId   int64
+}
+
+func (d *data) clone() *data {
+	return &data{
+		events:           append([]models.Event(nil), d.events...),
+		tables:           append([]table(nil), d.tables...),
+		guests:           append([]guest(nil), d.guests...),
+		visits:           append([]visit(nil), d.visits...),
+		companions:       append([]companion(nil), d.companions...),
+		constraints:      append([]constraint(nil), d.constraints...),
+		waitlist:         append([]waitlistEntry(nil), d.waitlist...),
+		tableMoves:       append([]tableMove(nil), d.tableMoves...),
+		lastTableId:      d.lastTableId,
+		lastGuestId:      d.lastGuestId,
+		lastConstraintId: d.lastConstraintId,
+		lastWaitlistId:   d.lastWaitlistId,
+	}
+}
+
+// PartyStore reads and writes the tables and guests of a single event, use
+// DbForEvent to get the store of another event.
+type PartyStore struct {
+	// Now returns the time recorded when a guest arrives, leaves or moves
+	Now func() time.Time
+
+	mu    *sync.Mutex
+	data  *data
+	event int64
+	inTx  bool
+}
+
+func New() *PartyStore {
+	return &PartyStore{
+		Now: time.Now,
+		mu:  &sync.Mutex{},
+		data: &data{
+			events: []models.Event{{Id: models.DefaultEvent, Name: "Default"}},
+		},
+		event: models.DefaultEvent,
+	}
+}
+
+// DbForEvent returns a store of event id sharing the data, lock and
+// transaction of s
+func (s *PartyStore) DbForEvent(id int64) models.Party {
+	return &PartyStore{Now: s.Now, mu: s.mu, data: s.data, event: id, inTx: s.inTx}
+}
+
+// lock acquires the store mutex unless the caller already holds it as part
+// of a transaction and returns the matching unlock function.
+func (s *PartyStore) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// DbTransaction holds the store lock while fn runs and restores the previous
+// state when fn returns an error.
+func (s *PartyStore) DbTransaction(fn func(models.Party) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := s.data.clone()
+	tx := &PartyStore{Now: s.Now, mu: s.mu, data: s.data, event: s.event, inTx: true}
+	if err := fn(tx); err != nil {
+		*s.data = *snapshot
+		return err
+	}
+	return nil
+}
+
+// Tables returns a copy of the tables of the event.
+func (s *PartyStore) Tables() []models.Table {
+	defer s.lock()()
+	return s.tables()
+}
+
+// Guests returns a copy of the guests of the event in the order they were
+// added.
+func (s *PartyStore) Guests() []models.Guests {
+	defer s.lock()()
+	return s.guests()
+}
+
+func (s *PartyStore) tables() []models.Table {
+	var tables []models.Table
+	for _, table := range s.data.tables {
+		if table.event == s.event {
+			tables = append(tables, table.Table)
+		}
+	}
+	return tables
+}
+
+func (s *PartyStore) guests() []models.Guests {
+	var guests []models.Guests
+	for _, guest := range s.data.guests {
+		if guest.event == s.event {
+			guests = append(guests, guest.Guests)
+		}
+	}
+	return guests
+}
+
+func (s *PartyStore) table(id int64) (int, bool) {
+	for i, table := range s.data.tables {
+		if table.Id == id && table.event == s.event {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// guest returns the index of guest id
+func (s *PartyStore) guest(id int64) (int, bool) {
+	for i, guest := range s.data.guests {
+		if guest.event == s.event && guest.Id == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// guestsNamed returns the guests with the name key of name in the order they
+// were added
+func (s *PartyStore) guestsNamed(name string) []models.Guests {
+	var guests []models.Guests
+	key := models.NameKey(name)
+	for _, guest := range s.guests() {
+		if models.NameKey(guest.Name) == key {
+			guests = append(guests, guest)
+		}
+	}
+	return guests
+}
+
+func (s *PartyStore) DbAddTable(capacity int64) (int64, error) {
+	defer s.lock()()
+	s.data.lastTableId++
+	s.data.tables = append(s.data.tables, table{
+		event: s.event,
+		Table: models.Table{Id: s.data.lastTableId, Capacity: capacity},
+	})
+	return s.data.lastTableId, nil
+}
+
+func (s *PartyStore) DbCheckTableExists(id int64) (int64, error) {
+	defer s.lock()()
+	if _, ok := s.table(id); ok {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// DbLockTable only reports whether the table exists, transactions already
+// hold the store lock.
+func (s *PartyStore) DbLockTable(id int64) (int64, error) {
+	return s.DbCheckTableExists(id)
+}
+
+// DbLockEvent does nothing, transactions already hold the store lock.
+func (s *PartyStore) DbLockEvent() error {
+	return nil
+}
+
+func (s *PartyStore) DbAddGuestList(g models.Guests) (int64, error) {
+	defer s.lock()()
+	if _, ok := s.table(g.Table); !ok {
+		return 0, fmt.Errorf("table %d does not exist", g.Table)
+	}
+	s.data.lastGuestId++
+	s.data.guests = append(s.data.guests, guest{
+		event: s.event,
+		Guests: models.Guests{
+			Id:                 s.data.lastGuestId,
+			Table:              g.Table,
+			AccompanyingGuests: g.AccompanyingGuests,
+			Status:             models.ALLOTTED,
+			Name:               g.Name,
+		},
+	})
+	return s.data.lastGuestId, nil
+}
+
+func (s *PartyStore) DbUpdateGuestStatus(id int64, status string) error {
+	defer s.lock()()
+	i, ok := s.guest(id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if err := models.GuestStatus.Check(s.data.guests[i].Status, status); err != nil {
+		return err
+	}
+	s.data.guests[i].Status = status
+	if status == models.CHECKEDOUT {
+		s.data.guests[i].TimeLeft = s.Now()
+	}
+	return nil
+}
+
+func (s *PartyStore) DbUpdateGuestList(guest models.Guests) error {
+	defer s.lock()()
+	i, ok := s.guest(guest.Id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if err := models.GuestStatus.Check(s.data.guests[i].Status, guest.Status); err != nil {
+		return err
+	}
+	s.data.guests[i].Status = guest.Status
+	s.data.guests[i].AccompanyingGuests = guest.AccompanyingGuests
+	s.data.guests[i].TimeArrived = s.Now()
+	s.data.guests[i].TimeLeft = time.Time{}
+	return nil
+}
+
+func (s *PartyStore) DbGetGuestInTable(id int64) (string, error) {
+	defer s.lock()()
+	for _, guest := range s.guests() {
+		if guest.Table == id {
+			return guest.Name, nil
+		}
+	}
+	return "", nil
+}
+
+func (s *PartyStore) DbGetGuestStatus(id int64) (string, error) {
+	defer s.lock()()
+	i, ok := s.guest(id)
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return s.data.guests[i].Status, nil
+}
+
+func (s *PartyStore) DbGetGuestList() ([]models.Guests, error) {
+	defer s.lock()()
+	var guestList []models.Guests
+	for _, guest := range s.guests() {
+		guestList = append(guestList, models.Guests{
+			Id:                 guest.Id,
+			Table:              guest.Table,
+			AccompanyingGuests: guest.AccompanyingGuests,
+			Status:             guest.Status,
+			Name:               guest.Name,
+		})
+	}
+	return guestList, nil
+}
+
+func (s *PartyStore) DbQueryGuests(q models.GuestQuery) ([]models.Guests, error) {
+	defer s.lock()()
+	var guests []models.Guests
+	for _, guest := range s.guests() {
+		if q.Match(guest) {
+			guests = append(guests, guest)
+		}
+	}
+	sort.SliceStable(guests, func(i, j int) bool { return q.Less(guests[i], guests[j]) })
+	if q.Limit > 0 && len(guests) > q.Limit {
+		guests = guests[:q.Limit]
+	}
+	return guests, nil
+}
+
+func (s *PartyStore) DbSearchGuests(query string, limit int) ([]models.Guests, error) {
+	defer s.lock()()
+	type candidate struct {
+		models.Guests
+		key    string
+		shared int
+	}
+	var prefixed, similar []candidate
+	trigrams := map[string]bool{}
+	for _, trigram := range models.Trigrams(query) {
+		trigrams[trigram] = true
+	}
+	for _, guest := range s.guests() {
+		c := candidate{Guests: guest, key: models.SearchKey(guest.Name)}
+		if strings.HasPrefix(c.key, query) {
+			prefixed = append(prefixed, c)
+		}
+		for _, trigram := range models.Trigrams(c.key) {
+			if trigrams[trigram] {
+				c.shared++
+			}
+		}
+		if c.shared > 0 {
+			similar = append(similar, c)
+		}
+	}
+	sort.Slice(prefixed, func(i, j int) bool {
+		if prefixed[i].key != prefixed[j].key {
+			return prefixed[i].key < prefixed[j].key
+		}
+		return prefixed[i].Id < prefixed[j].Id
+	})
+	sort.Slice(similar, func(i, j int) bool {
+		if similar[i].shared != similar[j].shared {
+			return similar[i].shared > similar[j].shared
+		}
+		return similar[i].Id < similar[j].Id
+	})
+	var guests []models.Guests
+	found := map[int64]bool{}
+	for _, candidates := range [][]candidate{prefixed, similar} {
+		if len(candidates) > limit {
+			candidates = candidates[:limit]
+		}
+		for _, c := range candidates {
+			if !found[c.Id] {
+				found[c.Id] = true
+				guests = append(guests, c.Guests)
+			}
+		}
+	}
+	return guests, nil
+}
+
+func (s *PartyStore) DbGetTableCapacity(id int64) (int64, error) {
+	defer s.lock()()
+	i, ok := s.table(id)
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return s.data.tables[i].Capacity, nil
+}
+
+func (s *PartyStore) DbGetTableIdOfGuest(id int64) (int64, error) {
+	defer s.lock()()
+	i, ok := s.guest(id)
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return s.data.guests[i].Table, nil
+}
+
+func (s *PartyStore) DbCheckGuestExists(name string) (int64, error) {
+	defer s.lock()()
+	return int64(len(s.guestsNamed(name))), nil
+}
+
+func (s *PartyStore) DbFindGuests(name string) ([]models.Guests, error) {
+	defer s.lock()()
+	return s.guestsNamed(name), nil
+}
+
+func (s *PartyStore) DbGetTables() ([]models.Table, error) {
+	defer s.lock()()
+	return s.tables(), nil
+}
+
+func (s *PartyStore) DbGetTableSeats() ([]models.TableSeats, error) {
+	defer s.lock()()
+	var tables []models.TableSeats
+	guests := s.guests()
+	for _, table := range s.tables() {
+		t := models.TableSeats{Table: table}
+		for _, guest := range guests {
+			if guest.Table != table.Id {
+				continue
+			}
+			switch guest.Status {
+			case models.ALLOTTED:
+				t.AllottedParties++
+				t.AllottedSeats += guest.AccompanyingGuests + 1
+			case models.CHECKEDIN:
+				t.CheckedInParties++
+				t.CheckedInSeats += guest.AccompanyingGuests + 1
+			case models.CHECKEDOUT:
+				t.CheckedOutParties++
+				t.CheckedOutSeats += guest.AccompanyingGuests + 1
+			case models.NOSHOW:
+				t.NoShowParties++
+				t.NoShowSeats += guest.AccompanyingGuests + 1
+			case models.CANCELLED:
+				t.CancelledParties++
+				t.CancelledSeats += guest.AccompanyingGuests + 1
+			}
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+func (s *PartyStore) DbGetGuestsInTable(id int64) ([]models.Guests, error) {
+	defer s.lock()()
+	var guests []models.Guests
+	for _, guest := range s.guests() {
+		if guest.Table == id {
+			guests = append(guests, models.Guests{
+				Id:                 guest.Id,
+				Table:              guest.Table,
+				AccompanyingGuests: guest.AccompanyingGuests,
+				Status:             guest.Status,
+				Name:               guest.Name,
+			})
+		}
+	}
+	sort.SliceStable(guests, func(i, j int) bool { return models.NameKey(guests[i].Name) < models.NameKey(guests[j].Name) })
+	return guests, nil
+}
+
+func (s *PartyStore) DbUpdateTableCapacity(id int64, capacity int64) error {
+	defer s.lock()()
+	if i, ok := s.table(id); ok {
+		s.data.tables[i].Capacity = capacity
+	}
+	return nil
+}
+
+func (s *PartyStore) DbDeleteTable(id int64) error {
+	defer s.lock()()
+	for _, guest := range s.guests() {
+		if guest.Table == id {
+			return fmt.Errorf("table %d is referenced by guest %s", id, guest.Name)
+		}
+	}
+	if i, ok := s.table(id); ok {
+		s.data.tables = append(s.data.tables[:i], s.data.tables[i+1:]...)
+	}
+	var kept []constraint
+	for _, c := range s.data.constraints {
+		if c.event != s.event || c.Table != id {
+			kept = append(kept, c)
+		}
+	}
+	s.data.constraints = kept
+	for i, e := range s.data.waitlist {
+		if e.event == s.event && e.Table == id {
+			s.data.waitlist[i].Table = 0
+		}
+	}
+	return nil
+}
+
+func (s *PartyStore) DbGetGuest(id int64) (models.Guests, error) {
+	defer s.lock()()
+	i, ok := s.guest(id)
+	if !ok {
+		return models.Guests{}, sql.ErrNoRows
+	}
+	return s.data.guests[i].Guests, nil
+}
+
+func (s *PartyStore) DbUpdateGuestAllotment(guest models.Guests) error {
+	defer s.lock()()
+	if _, ok := s.table(guest.Table); !ok {
+		return fmt.Errorf("table %d does not exist", guest.Table)
+	}
+	if i, ok := s.guest(guest.Id); ok {
+		s.data.guests[i].Table = guest.Table
+		s.data.guests[i].AccompanyingGuests = guest.AccompanyingGuests
+	}
+	return nil
+}
+
+func (s *PartyStore) DbDeleteGuest(id int64) error {
+	defer s.lock()()
+	i, ok := s.guest(id)
+	if !ok {
+		return nil
+	}
+	for _, visit := range s.data.visits {
+		if visit.event == s.event && visit.GuestId == id {
+			return fmt.Errorf("guest %d is referenced by a visit", id)
+		}
+	}
+	s.data.guests = append(s.data.guests[:i], s.data.guests[i+1:]...)
+	s.removeCompanions(id)
+	var kept []waitlistEntry
+	for _, e := range s.data.waitlist {
+		if e.event != s.event || e.GuestId != id {
+			kept = append(kept, e)
+		}
+	}
+	s.data.waitlist = kept
+	return nil
+}
+
+func (s *PartyStore) removeCompanions(id int64) {
+	var kept []companion
+	for _, c := range s.data.companions {
+		if c.event != s.event || c.GuestId != id {
+			kept = append(kept, c)
+		}
+	}
+	s.data.companions = kept
+}
+
+func (s *PartyStore) DbGetCompanions(ids ...int64) ([]models.Companion, error) {
+	defer s.lock()()
+	wanted := map[int64]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var companions []models.Companion
+	for _, c := range s.data.companions {
+		if c.event == s.event && (len(ids) == 0 || wanted[c.GuestId]) {
+			companions = append(companions, c.Companion)
+		}
+	}
+	sort.SliceStable(companions, func(i, j int) bool {
+		return companions[i].GuestId < companions[j].GuestId
+	})
+	return companions, nil
+}
+
+func (s *PartyStore) DbSetCompanions(id int64, companions []models.Companion) error {
+	defer s.lock()()
+	if _, ok := s.guest(id); !ok {
+		return fmt.Errorf("guest %d does not exist", id)
+	}
+	s.removeCompanions(id)
+	for _, c := range companions {
+		c.GuestId = id
+		s.data.companions = append(s.data.companions, companion{event: s.event, Companion: c})
+	}
+	return nil
+}
+
+func (s *PartyStore) DbAddVisit(id int64) error {
+	defer s.lock()()
+	i, ok := s.guest(id)
+	if !ok {
+		return fmt.Errorf("guest %d does not exist", id)
+	}
+	s.data.visits = append(s.data.visits, visit{
+		event: s.event,
+		Visit: models.Visit{
+			GuestId:            id,
+			Name:               s.data.guests[i].Name,
+			AccompanyingGuests: s.data.guests[i].AccompanyingGuests,
+			TimeArrived:        s.data.guests[i].TimeArrived,
+		},
+	})
+	return nil
+}
+
+func (s *PartyStore) DbEndVisit(id int64) error {
+	defer s.lock()()
+	g, ok := s.guest(id)
+	if !ok {
+		return nil
+	}
+	for i, visit := range s.data.visits {
+		if visit.event == s.event && visit.GuestId == id && visit.TimeLeft.IsZero() {
+			s.data.visits[i].TimeLeft = s.data.guests[g].TimeLeft
+		}
+	}
+	return nil
+}
+
+func (s *PartyStore) DbGetVisits(ids ...int64) ([]models.Visit, error) {
+	defer s.lock()()
+	wanted := map[int64]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var visits []models.Visit
+	for _, visit := range s.data.visits {
+		if visit.event == s.event && (len(ids) == 0 || wanted[visit.GuestId]) {
+			visits = append(visits, visit.Visit)
+		}
+	}
+	return visits, nil
+}
+
+func (s *PartyStore) DbAddTableMove(move models.TableMove) error {
+	defer s.lock()()
+	if _, ok := s.guest(move.GuestId); !ok {
+		return fmt.Errorf("guest %d does not exist", move.GuestId)
+	}
+	move.TimeMoved = s.Now()
+	s.data.tableMoves = append(s.data.tableMoves, tableMove{event: s.event, TableMove: move})
+	return nil
+}
+
+func (s *PartyStore) DbGetTableMoves() ([]models.TableMove, error) {
+	defer s.lock()()
+	var moves []models.TableMove
+	for _, move := range s.data.tableMoves {
+		if move.event == s.event {
+			moves = append(moves, move.TableMove)
+		}
+	}
+	return moves, nil
+}
+
+func (s *PartyStore) DbAddConstraint(c models.Constraint) (int64, error) {
+	defer s.lock()()
+	if c.Table != 0 {
+		if _, ok := s.table(c.Table); !ok {
+			return 0, fmt.Errorf("table %d does not exist", c.Table)
+		}
+	}
+	s.data.lastConstraintId++
+	c.Id = s.data.lastConstraintId
+	s.data.constraints = append(s.data.constraints, constraint{event: s.event, Constraint: c})
+	return c.Id, nil
+}
+
+func (s *PartyStore) DbGetConstraints() ([]models.Constraint, error) {
+	defer s.lock()()
+	var constraints []models.Constraint
+	for _, c := range s.data.constraints {
+		if c.event == s.event {
+			constraints = append(constraints, c.Constraint)
+		}
+	}
+	return constraints, nil
+}
+
+func (s *PartyStore) DbDeleteConstraint(id int64) (int64, error) {
+	defer s.lock()()
+	for i, c := range s.data.constraints {
+		if c.event == s.event && c.Id == id {
+			s.data.constraints = append(s.data.constraints[:i], s.data.constraints[i+1:]...)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *PartyStore) DbAddWaitlistEntry(e models.WaitlistEntry) (int64, error) {
+	defer s.lock()()
+	if e.Table != 0 {
+		if _, ok := s.table(e.Table); !ok {
+			return 0, fmt.Errorf("table %d does not exist", e.Table)
+		}
+	}
+	s.data.lastWaitlistId++
+	e.Id = s.data.lastWaitlistId
+	e.GuestId = 0
+	s.data.waitlist = append(s.data.waitlist, waitlistEntry{event: s.event, WaitlistEntry: e})
+	return e.Id, nil
+}
+
+func (s *PartyStore) DbGetWaitlist() ([]models.WaitlistEntry, error) {
+	defer s.lock()()
+	var entries []models.WaitlistEntry
+	for _, e := range s.data.waitlist {
+		if e.event == s.event {
+			entries = append(entries, e.WaitlistEntry)
+		}
+	}
+	return entries, nil
+}
+
+func (s *PartyStore) DbAllotWaitlistEntry(id int64, guestId int64) (int64, error) {
+	defer s.lock()()
+	for i, e := range s.data.waitlist {
+		if e.event == s.event && e.Id == id && e.GuestId == 0 {
+			s.data.waitlist[i].GuestId = guestId
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *PartyStore) DbDeleteWaitlistEntry(id int64) (int64, error) {
+	defer s.lock()()
+	for i, e := range s.data.waitlist {
+		if e.event == s.event && e.Id == id {
+			s.data.waitlist = append(s.data.waitlist[:i], s.data.waitlist[i+1:]...)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *PartyStore) DbAddEvent(event models.Event) (int64, error) {
+	defer s.lock()()
+	event.Id = s.data.events[len(s.data.events)-1].Id + 1
+	s.data.events = append(s.data.events, event)
+	return event.Id, nil
+}
+
+func (s *PartyStore) DbGetEvents() ([]models.Event, error) {
+	defer s.lock()()
+	return append([]models.Event(nil), s.data.events...), nil
+}
+
+func (s *PartyStore) DbGetEvent(id int64) (models.Event, error) {
+	defer s.lock()()
+	for _, event := range s.data.events {
+		if event.Id == id {
+			return event, nil
+		}
+	}
+	return models.Event{}, sql.ErrNoRows
+}
+
+func (s *PartyStore) DbCheckEventExists(id int64) (int64, error) {
+	if _, err := s.DbGetEvent(id); err != nil {
+		return 0, nil
+	}
+	return 1, nil
+}
@@ -0,0 +1,243 @@
+package memstore
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	models "github.com/getground/tech-tasks/backend/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddGuestListConstraints(t *testing.T) {
+	s := New()
+	id, err := s.DbAddTable(4)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), id)
+
+	john, err := s.DbAddGuestList(models.Guests{Table: 1, AccompanyingGuests: 1, Name: "john"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), john)
+	_, err = s.DbAddGuestList(models.Guests{Table: 2, Name: "jack"})
+	assert.NotNil(t, err, "unknown table")
+
+	status, err := s.DbGetGuestStatus(john)
+	assert.Nil(t, err)
+	assert.Equal(t, models.ALLOTTED, status)
+
+	_, err = s.DbGetGuestStatus(2)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestSameNames(t *testing.T) {
+	s := New()
+	_, err := s.DbAddTable(4)
+	assert.Nil(t, err)
+	first, err := s.DbAddGuestList(models.Guests{Table: 1, Name: "John"})
+	assert.Nil(t, err)
+	second, err := s.DbAddGuestList(models.Guests{Table: 1, Name: "john"})
+	assert.Nil(t, err)
+
+	guests, err := s.DbFindGuests("JOHN")
+	assert.Nil(t, err)
+	assert.Equal(t, []models.Guests{
+		{Id: first, Table: 1, Status: models.ALLOTTED, Name: "John"},
+		{Id: second, Table: 1, Status: models.ALLOTTED, Name: "john"},
+	}, guests)
+
+	assert.Nil(t, s.DbUpdateGuestStatus(second, models.CANCELLED))
+	status, err := s.DbGetGuestStatus(first)
+	assert.Nil(t, err)
+	assert.Equal(t, models.ALLOTTED, status)
+
+	assert.Nil(t, s.DbDeleteGuest(first))
+	exists, err := s.DbCheckGuestExists("john")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), exists)
+}
+
+func TestCompanions(t *testing.T) {
+	s := New()
+	_, err := s.DbAddTable(4)
+	assert.Nil(t, err)
+	john, err := s.DbAddGuestList(models.Guests{Table: 1, AccompanyingGuests: 1, Name: "john"})
+	assert.Nil(t, err)
+	mary, err := s.DbAddGuestList(models.Guests{Table: 1, AccompanyingGuests: 1, Name: "mary"})
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.DbSetCompanions(mary, []models.Companion{{Name: "Tom"}}))
+	assert.Nil(t, s.DbSetCompanions(john, []models.Companion{{Name: "Anna", Dietary: "vegan"}}))
+	assert.NotNil(t, s.DbSetCompanions(99, []models.Companion{{Name: "Eve"}}))
+	companions, err := s.DbGetCompanions()
+	assert.Nil(t, err)
+	assert.Equal(t, []models.Companion{
+		{GuestId: john, Name: "Anna", Dietary: "vegan"},
+		{GuestId: mary, Name: "Tom"},
+	}, companions)
+
+	err = s.DbTransaction(func(tx models.Party) error {
+		if err := tx.DbSetCompanions(john, nil); err != nil {
+			return err
+		}
+		return fmt.Errorf("abort")
+	})
+	assert.NotNil(t, err)
+	companions, err = s.DbGetCompanions(john)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(companions))
+
+	assert.Nil(t, s.DbDeleteGuest(john))
+	companions, err = s.DbGetCompanions()
+	assert.Nil(t, err)
+	assert.Equal(t, []models.Companion{{GuestId: mary, Name: "Tom"}}, companions)
+}
+
+func TestConstraints(t *testing.T) {
+	s := New()
+	_, err := s.DbAddTable(4)
+	assert.Nil(t, err)
+
+	tag, err := s.DbAddConstraint(models.Constraint{Kind: models.TABLETAG, Table: 1, Tag: "stage"})
+	assert.Nil(t, err)
+	_, err = s.DbAddConstraint(models.Constraint{Kind: models.TABLETAG, Table: 2, Tag: "stage"})
+	assert.NotNil(t, err)
+	together, err := s.DbAddConstraint(models.Constraint{Kind: models.TOGETHER, Name: "john", Other: "mary"})
+	assert.Nil(t, err)
+	constraints, err := s.DbGetConstraints()
+	assert.Nil(t, err)
+	assert.Equal(t, []models.Constraint{
+		{Id: tag, Kind: models.TABLETAG, Table: 1, Tag: "stage"},
+		{Id: together, Kind: models.TOGETHER, Name: "john", Other: "mary"},
+	}, constraints)
+
+	deleted, err := s.DbDeleteConstraint(together)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), deleted)
+	deleted, err = s.DbDeleteConstraint(together)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), deleted)
+
+	assert.Nil(t, s.DbDeleteTable(1))
+	constraints, err = s.DbGetConstraints()
+	assert.Nil(t, err)
+	assert.Empty(t, constraints)
+}
+
+func TestWaitlist(t *testing.T) {
+	s := New()
+	_, err := s.DbAddTable(4)
+	assert.Nil(t, err)
+
+	ann, err := s.DbAddWaitlistEntry(models.WaitlistEntry{Name: "ann", Table: 1})
+	assert.Nil(t, err)
+	_, err = s.DbAddWaitlistEntry(models.WaitlistEntry{Name: "bob", Table: 2})
+	assert.NotNil(t, err)
+	guest, err := s.DbAddGuestList(models.Guests{Table: 1, Name: "ann"})
+	assert.Nil(t, err)
+	allotted, err := s.DbAllotWaitlistEntry(ann, guest)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), allotted)
+	allotted, err = s.DbAllotWaitlistEntry(ann, guest)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), allotted)
+	entries, err := s.DbGetWaitlist()
+	assert.Nil(t, err)
+	assert.Equal(t, []models.WaitlistEntry{{Id: ann, Name: "ann", Table: 1, GuestId: guest}}, entries)
+
+	assert.Nil(t, s.DbDeleteGuest(guest))
+	entries, err = s.DbGetWaitlist()
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+	deleted, err := s.DbDeleteWaitlistEntry(ann)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), deleted)
+}
+
+func TestTableMoves(t *testing.T) {
+	s := New()
+	moved := time.Date(2021, 6, 1, 20, 0, 0, 0, time.UTC)
+	s.Now = func() time.Time { return moved }
+	_, err := s.DbAddTable(4)
+	assert.Nil(t, err)
+	john, err := s.DbAddGuestList(models.Guests{Table: 1, Name: "john"})
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.DbAddTableMove(models.TableMove{GuestId: john, Name: "john", From: 2, To: 1, AccompanyingGuests: 3}))
+	assert.NotNil(t, s.DbAddTableMove(models.TableMove{GuestId: 99, Name: "eve", From: 2, To: 1}))
+	moves, err := s.DbGetTableMoves()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(moves))
+	assert.Equal(t, moved, moves[0].TimeMoved)
+	assert.Equal(t, int64(1), moves[0].To)
+}
+
+func TestTransactionRollback(t *testing.T) {
+	s := New()
+	_, err := s.DbAddTable(4)
+	assert.Nil(t, err)
+
+	txErr := fmt.Errorf("abort")
+	err = s.DbTransaction(func(tx models.Party) error {
+		if _, err := tx.DbAddGuestList(models.Guests{Table: 1, Name: "john"}); err != nil {
+			return err
+		}
+		return txErr
+	})
+	assert.Equal(t, txErr, err)
+	assert.Nil(t, s.Guests())
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	s := New()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.DbTransaction(func(tx models.Party) error {
+				id, err := tx.DbAddTable(2)
+				if err != nil {
+					return err
+				}
+				_, err = tx.DbAddGuestList(models.Guests{Table: id, Name: fmt.Sprintf("guest%d", i)})
+				return err
+			})
+			s.DbGetGuestList()
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 10, len(s.Tables()))
+	assert.Equal(t, 10, len(s.Guests()))
+}
+
+func TestEvents(t *testing.T) {
+	s := New()
+	id, err := s.DbAddEvent(models.Event{Name: "wedding"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), id)
+	wedding := s.DbForEvent(id)
+
+	_, err = s.DbAddTable(4)
+	assert.Nil(t, err)
+	tableId, err := wedding.DbAddTable(6)
+	assert.Nil(t, err)
+	_, err = s.DbAddGuestList(models.Guests{Table: 1, Name: "john"})
+	assert.Nil(t, err)
+	_, err = wedding.DbAddGuestList(models.Guests{Table: 1, Name: "john"})
+	assert.NotNil(t, err, "table of another event")
+	john, err := wedding.DbAddGuestList(models.Guests{Table: tableId, Name: "john"})
+	assert.Nil(t, err)
+
+	assert.Equal(t, []models.Table{{Id: 1, Capacity: 4}}, s.Tables())
+	guests, err := wedding.DbGetGuestList()
+	assert.Nil(t, err)
+	assert.Equal(t, []models.Guests{{Id: john, Table: tableId, Status: models.ALLOTTED, Name: "john"}}, guests)
+	_, err = s.DbGetGuest(john)
+	assert.Equal(t, sql.ErrNoRows, err, "guest of another event")
+
+	exists, err := s.DbCheckEventExists(3)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), exists)
+}
@@ -0,0 +1,290 @@
+// Package migrate applies the versioned schema migrations embedded in the
+// binary. Migrations live in migrations/<dialect>/ as
+// <version>_<name>.up.sql and <version>_<name>.down.sql files and are applied
+// in version order. Applied versions are recorded in the schema_migrations
+// table together with the checksum of their up script, so that a migration
+// edited after it was applied is detected instead of silently skipped.
+package migrate
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	db "github.com/getground/tech-tasks/backend/pkg/db"
+)
+
+//go:embed migrations
+var migrations embed.FS
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status of a migration in the database, AppliedAt is zero for pending
+// migrations.
+type Status struct {
+	Migration
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	DB         *sql.DB
+	Dialect    string
+	Migrations []Migration
+}
+
+// New returns a Migrator for the migrations embedded for dialect.
+func New(sqlDB *sql.DB, dialect string) (*Migrator, error) {
+	dir, err := fs.Sub(migrations, path.Join("migrations", dialect))
+	if err != nil {
+		return nil, err
+	}
+	ms, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(ms) == 0 {
+		return nil, fmt.Errorf("no migrations for %s", dialect)
+	}
+	return &Migrator{DB: sqlDB, Dialect: dialect, Migrations: ms}, nil
+}
+
+// Load reads the migrations in the root of fsys sorted by version. Every
+// version must have an up script, the down script is optional.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", fileName)
+		}
+		content, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d has up and down scripts with different names", version)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+	var ms []Migration
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", m.Version)
+		}
+		ms = append(ms, *m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return ms, nil
+}
+
+func (m *Migrator) placeholder(n int) string {
+	if m.Dialect == db.Postgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+func (m *Migrator) ensureVersionTable() error {
+	_, err := m.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	return err
+}
+
+type applied struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+func (m *Migrator) applied() (map[int64]applied, error) {
+	if err := m.ensureVersionTable(); err != nil {
+		return nil, err
+	}
+	res, err := m.DB.Query("SELECT version, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+	versions := map[int64]applied{}
+	for res.Next() {
+		var version int64
+		var a applied
+		if err := res.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = a
+	}
+	return versions, res.Err()
+}
+
+// Verify checks that every applied migration is still known and unchanged.
+func (m *Migrator) Verify() error {
+	versions, err := m.applied()
+	if err != nil {
+		return err
+	}
+	return m.verify(versions)
+}
+
+func (m *Migrator) verify(versions map[int64]applied) error {
+	known := map[int64]bool{}
+	for _, mig := range m.Migrations {
+		known[mig.Version] = true
+		if a, ok := versions[mig.Version]; ok && a.checksum != mig.Checksum {
+			return fmt.Errorf("checksum mismatch for applied migration %d_%s", mig.Version, mig.Name)
+		}
+	}
+	for version := range versions {
+		if !known[version] {
+			return fmt.Errorf("applied migration %d is unknown to this binary", version)
+		}
+	}
+	return nil
+}
+
+// Up applies all pending migrations in order and returns them.
+func (m *Migrator) Up() ([]Migration, error) {
+	versions, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	if err = m.verify(versions); err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, mig := range m.Migrations {
+		if _, ok := versions[mig.Version]; ok {
+			continue
+		}
+		err := m.run(mig.Up, func(tx *sql.Tx) error {
+			_, err := tx.Exec(fmt.Sprintf(
+				"INSERT INTO schema_migrations(version, name, checksum, applied_at) VALUES (%s, %s, %s, %s)",
+				m.placeholder(1), m.placeholder(2), m.placeholder(3), m.placeholder(4)),
+				mig.Version, mig.Name, mig.Checksum, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s failed: %v", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down rolls back the last steps applied migrations and returns them.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	versions, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	if err = m.verify(versions); err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for i := len(m.Migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		mig := m.Migrations[i]
+		if _, ok := versions[mig.Version]; !ok {
+			continue
+		}
+		if mig.Down == "" {
+			return done, fmt.Errorf("migration %d_%s cannot be rolled back", mig.Version, mig.Name)
+		}
+		err := m.run(mig.Down, func(tx *sql.Tx) error {
+			_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = "+m.placeholder(1), mig.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("rollback of migration %d_%s failed: %v", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Status returns all known migrations with the time they were applied.
+func (m *Migrator) Status() ([]Status, error) {
+	versions, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var statuses []Status
+	for _, mig := range m.Migrations {
+		statuses = append(statuses, Status{Migration: mig, AppliedAt: versions[mig.Version].appliedAt})
+	}
+	return statuses, nil
+}
+
+// run executes the statements of script and record in one transaction. Note
+// that mysql commits DDL statements implicitly.
+func (m *Migrator) run(script string, record func(*sql.Tx) error) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range statements(script) {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if err = record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// statements splits script on the ; ending a statement, the mysql driver
+// only runs one statement per Exec.
+func statements(script string) []string {
+	var stmts []string
+	for _, stmt := range strings.Split(script, ";") {
+		if strings.TrimSpace(stmt) != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
@@ -0,0 +1,112 @@
+package migrate
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	db "github.com/getground/tech-tasks/backend/pkg/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSQLiteDB(t *testing.T) *sql.DB {
+	sqlDB, err := sql.Open(db.SQLite, db.SQLiteDSN(filepath.Join(t.TempDir(), "party.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return sqlDB
+}
+
+var testMigrations = fstest.MapFS{
+	"0002_add_notes.up.sql":       {Data: []byte("ALTER TABLE people ADD COLUMN notes TEXT;")},
+	"0001_create_people.up.sql":   {Data: []byte("CREATE TABLE people (name TEXT);\nCREATE INDEX people_name ON people(name);")},
+	"0001_create_people.down.sql": {Data: []byte("DROP TABLE people;")},
+	"README.md":                   {Data: []byte("not a migration")},
+}
+
+func TestLoad(t *testing.T) {
+	ms, err := Load(testMigrations)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(ms))
+	assert.Equal(t, int64(1), ms[0].Version)
+	assert.Equal(t, "create_people", ms[0].Name)
+	assert.Equal(t, "DROP TABLE people;", ms[0].Down)
+	assert.Equal(t, int64(2), ms[1].Version)
+	assert.Equal(t, "", ms[1].Down)
+
+	_, err = Load(fstest.MapFS{"0001_only_down.down.sql": {Data: []byte("DROP TABLE people;")}})
+	assert.NotNil(t, err)
+	_, err = Load(fstest.MapFS{"first.up.sql": {Data: []byte("SELECT 1;")}})
+	assert.NotNil(t, err)
+}
+
+func TestUpDown(t *testing.T) {
+	sqlDB := newSQLiteDB(t)
+	ms, err := Load(testMigrations)
+	assert.Nil(t, err)
+	m := &Migrator{DB: sqlDB, Dialect: db.SQLite, Migrations: ms}
+
+	done, err := m.Up()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(done))
+	_, err = sqlDB.Exec("INSERT INTO people(name, notes) VALUES ('john', 'vip')")
+	assert.Nil(t, err)
+
+	done, err = m.Up()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(done), "nothing left to apply")
+
+	statuses, err := m.Status()
+	assert.Nil(t, err)
+	assert.False(t, statuses[0].AppliedAt.IsZero())
+	assert.False(t, statuses[1].AppliedAt.IsZero())
+
+	_, err = m.Down(1)
+	assert.NotNil(t, err, "migration 2 has no down script")
+
+	// applied migrations must not change
+	m.Migrations[0].Checksum = "edited"
+	_, err = m.Up()
+	assert.NotNil(t, err)
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	sqlDB := newSQLiteDB(t)
+	m, err := New(sqlDB, db.SQLite)
+	assert.Nil(t, err)
+
+	done, err := m.Up()
+	assert.Nil(t, err)
+	assert.Equal(t, len(m.Migrations), len(done))
+
+	done, err = m.Down(len(m.Migrations))
+	assert.Nil(t, err)
+	assert.Equal(t, len(m.Migrations), len(done))
+
+	statuses, err := m.Status()
+	assert.Nil(t, err)
+	for _, s := range statuses {
+		assert.True(t, s.AppliedAt.IsZero())
+	}
+	_, err = m.Up()
+	assert.Nil(t, err)
+}
+
+func TestEveryDialectHasTheSameVersions(t *testing.T) {
+	var versions []int64
+	for _, dialect := range []string{db.MySQL, db.Postgres, db.SQLite} {
+		m, err := New(nil, dialect)
+		assert.Nil(t, err)
+		var dv []int64
+		for _, mig := range m.Migrations {
+			dv = append(dv, mig.Version)
+			assert.NotEqual(t, "", mig.Down, "%s migration %d has no down script", dialect, mig.Version)
+		}
+		if versions == nil {
+			versions = dv
+		}
+		assert.Equal(t, versions, dv, dialect)
+	}
+}
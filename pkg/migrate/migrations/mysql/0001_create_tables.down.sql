@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `guests`;
+DROP TABLE IF EXISTS `tables`;
@@ -1,12 +1,12 @@
 /* Table to store capacity of tables*/
-CREATE TABLE `tables` (
+CREATE TABLE IF NOT EXISTS `tables` (
   `id` INT UNSIGNED NOT NULL auto_increment,
   `capacity` INT NOT NULL,
   PRIMARY KEY (`id`)
 );
 
-/* Table to stroe guest lists*/
-CREATE TABLE `guests` (
+/* Table to store guest lists*/
+CREATE TABLE IF NOT EXISTS `guests` (
   `id` INT UNSIGNED NOT NULL,
   `name` VARCHAR(100) NOT NULL UNIQUE,
   `accompanying_guests` INT UNSIGNED,
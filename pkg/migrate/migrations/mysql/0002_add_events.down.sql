@@ -0,0 +1,8 @@
+ALTER TABLE `guests` DROP FOREIGN KEY `guests_event_fk`;
+ALTER TABLE `guests`
+  DROP INDEX `guests_event_name`,
+  ADD UNIQUE KEY `name` (`name`),
+  DROP COLUMN `event_id`;
+ALTER TABLE `tables` DROP FOREIGN KEY `tables_event_fk`;
+ALTER TABLE `tables` DROP COLUMN `event_id`;
+DROP TABLE IF EXISTS `events`;
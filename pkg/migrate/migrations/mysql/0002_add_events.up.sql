@@ -0,0 +1,22 @@
+/* Table to store events, every table and guest belongs to one event*/
+CREATE TABLE IF NOT EXISTS `events` (
+  `id` INT UNSIGNED NOT NULL auto_increment,
+  `name` VARCHAR(100) NOT NULL,
+  `event_date` DATE NULL,
+  `venue` VARCHAR(255) NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`)
+);
+
+/* existing tables and guests belong to the default event*/
+INSERT INTO `events` (`id`, `name`, `venue`) VALUES (1, 'Default', '');
+
+ALTER TABLE `tables`
+  ADD COLUMN `event_id` INT UNSIGNED NOT NULL DEFAULT 1,
+  ADD CONSTRAINT `tables_event_fk` FOREIGN KEY (`event_id`) REFERENCES events(`id`);
+
+/* guest names are unique per event*/
+ALTER TABLE `guests`
+  ADD COLUMN `event_id` INT UNSIGNED NOT NULL DEFAULT 1,
+  DROP INDEX `name`,
+  ADD UNIQUE KEY `guests_event_name` (`event_id`, `name`),
+  ADD CONSTRAINT `guests_event_fk` FOREIGN KEY (`event_id`) REFERENCES events(`id`);
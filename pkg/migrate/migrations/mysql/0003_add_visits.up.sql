@@ -0,0 +1,19 @@
+/* Table to store every stay of a guest, time_left is NULL while the guest
+   is present*/
+CREATE TABLE IF NOT EXISTS `visits` (
+  `id` INT UNSIGNED NOT NULL auto_increment,
+  `event_id` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(100) NOT NULL,
+  `accompanying_guests` INT UNSIGNED NOT NULL,
+  `time_arrived` DATETIME NOT NULL,
+  `time_left` DATETIME NULL,
+  PRIMARY KEY (`id`),
+  CONSTRAINT `visits_guest_fk` FOREIGN KEY (`event_id`, `name`) REFERENCES guests(`event_id`, `name`)
+);
+
+/* guests who arrived before visits were recorded, the departure of guests
+   who already left is not known and recorded as the time of the migration*/
+INSERT INTO `visits` (`event_id`, `name`, `accompanying_guests`, `time_arrived`, `time_left`)
+  SELECT `event_id`, `name`, `accompanying_guests`, COALESCE(`time_arrived`, CURRENT_TIMESTAMP),
+    CASE WHEN `status` = 'checked-out' THEN CURRENT_TIMESTAMP END
+  FROM `guests` WHERE `status` IN ('checked-in', 'checked-out');
@@ -0,0 +1,2 @@
+UPDATE `guests` SET `status` = 'allotted' WHERE `status` IN ('no-show', 'cancelled');
+ALTER TABLE `guests` MODIFY `status` ENUM('allotted', 'checked-in', 'checked-out') DEFAULT 'allotted';
@@ -0,0 +1,2 @@
+/* guests can be marked as no-show or cancelled*/
+ALTER TABLE `guests` MODIFY `status` ENUM('allotted', 'checked-in', 'checked-out', 'no-show', 'cancelled') DEFAULT 'allotted';
@@ -0,0 +1 @@
+ALTER TABLE `guests` DROP COLUMN `time_left`;
@@ -0,0 +1,3 @@
+DROP INDEX `guests_event_time_arrived` ON `guests`;
+DROP INDEX `guests_event_status` ON `guests`;
+DROP INDEX `guests_event_table` ON `guests`;
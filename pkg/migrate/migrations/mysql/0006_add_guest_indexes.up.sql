@@ -0,0 +1,5 @@
+/* indexes of the filters and sort keys of the guest list and arrivals, the
+   name is indexed by the unique key of event and name*/
+CREATE INDEX `guests_event_table` ON `guests` (`event_id`, `id`, `name`);
+CREATE INDEX `guests_event_status` ON `guests` (`event_id`, `status`, `name`);
+CREATE INDEX `guests_event_time_arrived` ON `guests` (`event_id`, `time_arrived`, `name`);
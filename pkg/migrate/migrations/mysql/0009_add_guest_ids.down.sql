@@ -0,0 +1,23 @@
+DROP TABLE `guest_trigrams`;
+
+CREATE TABLE IF NOT EXISTS `guest_trigrams` (
+  `event_id` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(100) NOT NULL,
+  `trigram` VARCHAR(3) NOT NULL,
+  PRIMARY KEY (`event_id`, `trigram`, `name`),
+  INDEX `guest_trigrams_event_name` (`event_id`, `name`)
+);
+
+UPDATE `guests` SET `search_key` = NULL;
+
+ALTER TABLE `guests`
+  DROP INDEX `guests_event_key`,
+  ADD UNIQUE INDEX `guests_event_key` (`event_id`, `name_key`),
+  ADD UNIQUE KEY `guests_event_name` (`event_id`, `name`);
+
+ALTER TABLE `visits`
+  DROP FOREIGN KEY `visits_guest_id_fk`,
+  DROP COLUMN `guest_id`,
+  ADD CONSTRAINT `visits_guest_fk` FOREIGN KEY (`event_id`, `name`) REFERENCES guests(`event_id`, `name`);
+
+ALTER TABLE `guests` DROP COLUMN `guest_id`;
@@ -0,0 +1,33 @@
+/* guests are identified by guest_id, several guests of an event can share
+   a name*/
+ALTER TABLE `guests` ADD COLUMN `guest_id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY FIRST;
+
+/* visits reference the guest by id*/
+ALTER TABLE `visits` ADD COLUMN `guest_id` INT UNSIGNED NULL AFTER `event_id`;
+
+UPDATE `visits` JOIN `guests` ON `guests`.`event_id` = `visits`.`event_id` AND `guests`.`name` = `visits`.`name`
+  SET `visits`.`guest_id` = `guests`.`guest_id`;
+
+ALTER TABLE `visits`
+  DROP FOREIGN KEY `visits_guest_fk`,
+  MODIFY `guest_id` INT UNSIGNED NOT NULL,
+  ADD CONSTRAINT `visits_guest_id_fk` FOREIGN KEY (`guest_id`) REFERENCES guests(`guest_id`);
+
+ALTER TABLE `guests`
+  DROP INDEX `guests_event_name`,
+  DROP INDEX `guests_event_key`,
+  ADD INDEX `guests_event_key` (`event_id`, `name_key`);
+
+/* the trigrams reference the guest by id, the server indexes the guests
+   again when it starts as they have no search key*/
+DROP TABLE `guest_trigrams`;
+
+CREATE TABLE IF NOT EXISTS `guest_trigrams` (
+  `event_id` INT UNSIGNED NOT NULL,
+  `guest_id` INT UNSIGNED NOT NULL,
+  `trigram` VARCHAR(3) NOT NULL,
+  PRIMARY KEY (`event_id`, `trigram`, `guest_id`),
+  INDEX `guest_trigrams_guest` (`guest_id`)
+);
+
+UPDATE `guests` SET `search_key` = NULL;
@@ -0,0 +1,14 @@
+/* named accompanying guests of a guest party, arrived tells whether the
+   companion came to the latest check-in of the guest*/
+CREATE TABLE IF NOT EXISTS `companions` (
+  `companion_id` INT UNSIGNED NOT NULL auto_increment,
+  `event_id` INT UNSIGNED NOT NULL,
+  `guest_id` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(100) NOT NULL,
+  `dietary` VARCHAR(255) NOT NULL DEFAULT '',
+  `accessibility` VARCHAR(255) NOT NULL DEFAULT '',
+  `arrived` BOOLEAN NOT NULL DEFAULT FALSE,
+  PRIMARY KEY (`companion_id`),
+  INDEX `companions_guest` (`guest_id`),
+  CONSTRAINT `companions_guest_id_fk` FOREIGN KEY (`guest_id`) REFERENCES guests(`guest_id`)
+);
@@ -0,0 +1,18 @@
+/* seating rules of an event. together and apart pair the guests named name
+   and other_name, requires makes the guests named name need a table tagged
+   tag and table_tag tags table_id with tag. Guests are matched by name key,
+   the guests need not be added yet*/
+CREATE TABLE IF NOT EXISTS `constraints` (
+  `constraint_id` INT UNSIGNED NOT NULL auto_increment,
+  `event_id` INT UNSIGNED NOT NULL,
+  `kind` ENUM('together', 'apart', 'requires', 'table_tag') NOT NULL,
+  `name` VARCHAR(100) NOT NULL DEFAULT '',
+  `name_key` VARCHAR(100) NOT NULL DEFAULT '',
+  `other_name` VARCHAR(100) NOT NULL DEFAULT '',
+  `other_key` VARCHAR(100) NOT NULL DEFAULT '',
+  `table_id` INT UNSIGNED NULL,
+  `tag` VARCHAR(50) NOT NULL DEFAULT '',
+  PRIMARY KEY (`constraint_id`),
+  INDEX `constraints_event` (`event_id`, `kind`),
+  CONSTRAINT `constraints_table_fk` FOREIGN KEY (`table_id`) REFERENCES tables(`id`)
+);
@@ -0,0 +1,16 @@
+/* guest parties waiting for a table in the order they were queued. table_id
+   is the table the party prefers, guest_id the guest added to the guest list
+   once the party got a table*/
+CREATE TABLE IF NOT EXISTS `waitlist` (
+  `waitlist_id` INT UNSIGNED NOT NULL auto_increment,
+  `event_id` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(100) NOT NULL,
+  `accompanying_guests` INT UNSIGNED NOT NULL DEFAULT 0,
+  `priority` INT NOT NULL DEFAULT 0,
+  `table_id` INT UNSIGNED NULL,
+  `guest_id` INT UNSIGNED NULL,
+  PRIMARY KEY (`waitlist_id`),
+  INDEX `waitlist_event` (`event_id`, `guest_id`),
+  CONSTRAINT `waitlist_table_fk` FOREIGN KEY (`table_id`) REFERENCES tables(`id`),
+  CONSTRAINT `waitlist_guest_fk` FOREIGN KEY (`guest_id`) REFERENCES guests(`guest_id`)
+);
@@ -0,0 +1,15 @@
+/* history of the parties moved to another table at check-in because the
+   party grew beyond the seats of its table*/
+CREATE TABLE IF NOT EXISTS `table_moves` (
+  `move_id` INT UNSIGNED NOT NULL auto_increment,
+  `event_id` INT UNSIGNED NOT NULL,
+  `guest_id` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(100) NOT NULL,
+  `from_table` INT UNSIGNED NOT NULL,
+  `to_table` INT UNSIGNED NOT NULL,
+  `accompanying_guests` INT UNSIGNED NOT NULL,
+  `time_moved` DATETIME NOT NULL,
+  PRIMARY KEY (`move_id`),
+  INDEX `table_moves_event` (`event_id`),
+  CONSTRAINT `table_moves_guest_fk` FOREIGN KEY (`guest_id`) REFERENCES guests(`guest_id`)
+);
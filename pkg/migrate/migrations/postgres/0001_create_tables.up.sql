@@ -0,0 +1,14 @@
+/* Table to store capacity of tables*/
+CREATE TABLE IF NOT EXISTS tables (
+  id SERIAL PRIMARY KEY,
+  capacity INTEGER NOT NULL
+);
+
+/* Table to store guest lists*/
+CREATE TABLE IF NOT EXISTS guests (
+  id INTEGER NOT NULL REFERENCES tables(id),
+  name VARCHAR(100) NOT NULL UNIQUE,
+  accompanying_guests INTEGER CHECK (accompanying_guests >= 0),
+  status VARCHAR(16) DEFAULT 'allotted' CHECK (status IN ('allotted', 'checked-in', 'checked-out')),
+  time_arrived TIMESTAMP
+);
@@ -0,0 +1,5 @@
+ALTER TABLE guests DROP CONSTRAINT guests_event_name_key;
+ALTER TABLE guests ADD CONSTRAINT guests_name_key UNIQUE (name);
+ALTER TABLE guests DROP COLUMN event_id;
+ALTER TABLE tables DROP COLUMN event_id;
+DROP TABLE IF EXISTS events;
@@ -0,0 +1,18 @@
+/* Table to store events, every table and guest belongs to one event*/
+CREATE TABLE IF NOT EXISTS events (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(100) NOT NULL,
+  event_date DATE,
+  venue VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+/* existing tables and guests belong to the default event*/
+INSERT INTO events (id, name, venue) VALUES (1, 'Default', '');
+SELECT setval('events_id_seq', (SELECT MAX(id) FROM events));
+
+ALTER TABLE tables ADD COLUMN event_id INTEGER NOT NULL DEFAULT 1 REFERENCES events(id);
+
+/* guest names are unique per event*/
+ALTER TABLE guests ADD COLUMN event_id INTEGER NOT NULL DEFAULT 1 REFERENCES events(id);
+ALTER TABLE guests DROP CONSTRAINT guests_name_key;
+ALTER TABLE guests ADD CONSTRAINT guests_event_name_key UNIQUE (event_id, name);
@@ -0,0 +1,3 @@
+UPDATE guests SET status = 'allotted' WHERE status IN ('no-show', 'cancelled');
+ALTER TABLE guests DROP CONSTRAINT guests_status_check;
+ALTER TABLE guests ADD CONSTRAINT guests_status_check CHECK (status IN ('allotted', 'checked-in', 'checked-out'));
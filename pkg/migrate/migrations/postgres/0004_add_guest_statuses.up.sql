@@ -0,0 +1,3 @@
+/* guests can be marked as no-show or cancelled*/
+ALTER TABLE guests DROP CONSTRAINT guests_status_check;
+ALTER TABLE guests ADD CONSTRAINT guests_status_check CHECK (status IN ('allotted', 'checked-in', 'checked-out', 'no-show', 'cancelled'));
@@ -0,0 +1,8 @@
+/* departure of the latest visit of a guest, NULL while the guest has not
+   checked-out*/
+ALTER TABLE guests ADD COLUMN time_left TIMESTAMP;
+
+UPDATE guests SET time_left = (
+  SELECT MAX(time_left) FROM visits
+  WHERE visits.event_id = guests.event_id AND visits.name = guests.name)
+WHERE status = 'checked-out';
@@ -0,0 +1,3 @@
+DROP INDEX guests_event_time_arrived;
+DROP INDEX guests_event_status;
+DROP INDEX guests_event_table;
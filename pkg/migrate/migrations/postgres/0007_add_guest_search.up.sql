@@ -0,0 +1,16 @@
+/* folded name of a guest for search, lower case and without accents. It is
+   filled for the guests added before by the server when it starts*/
+ALTER TABLE guests ADD COLUMN search_key VARCHAR(100);
+
+/* pattern ops let prefix LIKE use the index whatever the collation*/
+CREATE INDEX guests_event_search_key ON guests (event_id, search_key varchar_pattern_ops);
+
+/* Table to store the trigrams of the words of the search key of guests*/
+CREATE TABLE IF NOT EXISTS guest_trigrams (
+  event_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  trigram VARCHAR(3) NOT NULL,
+  PRIMARY KEY (event_id, trigram, name)
+);
+
+CREATE INDEX guest_trigrams_event_name ON guest_trigrams (event_id, name);
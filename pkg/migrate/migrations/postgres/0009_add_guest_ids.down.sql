@@ -0,0 +1,23 @@
+DROP TABLE guest_trigrams;
+
+CREATE TABLE IF NOT EXISTS guest_trigrams (
+  event_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  trigram VARCHAR(3) NOT NULL,
+  PRIMARY KEY (event_id, trigram, name)
+);
+
+CREATE INDEX guest_trigrams_event_name ON guest_trigrams (event_id, name);
+
+UPDATE guests SET search_key = NULL;
+
+DROP INDEX guests_event_key;
+CREATE UNIQUE INDEX guests_event_key ON guests (event_id, name_key);
+ALTER TABLE guests ADD CONSTRAINT guests_event_name_key UNIQUE (event_id, name);
+
+DROP INDEX visits_guest_id;
+ALTER TABLE visits DROP CONSTRAINT visits_guest_id_fkey;
+ALTER TABLE visits DROP COLUMN guest_id;
+ALTER TABLE visits ADD FOREIGN KEY (event_id, name) REFERENCES guests(event_id, name);
+
+ALTER TABLE guests DROP COLUMN guest_id;
@@ -0,0 +1,33 @@
+/* guests are identified by guest_id, several guests of an event can share
+   a name*/
+ALTER TABLE guests ADD COLUMN guest_id SERIAL PRIMARY KEY;
+
+/* visits reference the guest by id*/
+ALTER TABLE visits ADD COLUMN guest_id INTEGER;
+
+UPDATE visits SET guest_id = guests.guest_id
+  FROM guests WHERE guests.event_id = visits.event_id AND guests.name = visits.name;
+
+ALTER TABLE visits ALTER COLUMN guest_id SET NOT NULL;
+ALTER TABLE visits DROP CONSTRAINT visits_event_id_name_fkey;
+ALTER TABLE visits ADD CONSTRAINT visits_guest_id_fkey FOREIGN KEY (guest_id) REFERENCES guests(guest_id);
+CREATE INDEX visits_guest_id ON visits (guest_id);
+
+ALTER TABLE guests DROP CONSTRAINT guests_event_name_key;
+DROP INDEX guests_event_key;
+CREATE INDEX guests_event_key ON guests (event_id, name_key);
+
+/* the trigrams reference the guest by id, the server indexes the guests
+   again when it starts as they have no search key*/
+DROP TABLE guest_trigrams;
+
+CREATE TABLE IF NOT EXISTS guest_trigrams (
+  event_id INTEGER NOT NULL,
+  guest_id INTEGER NOT NULL,
+  trigram VARCHAR(3) NOT NULL,
+  PRIMARY KEY (event_id, trigram, guest_id)
+);
+
+CREATE INDEX guest_trigrams_guest ON guest_trigrams (guest_id);
+
+UPDATE guests SET search_key = NULL;
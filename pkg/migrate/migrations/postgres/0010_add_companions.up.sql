@@ -0,0 +1,13 @@
+/* named accompanying guests of a guest party, arrived tells whether the
+   companion came to the latest check-in of the guest*/
+CREATE TABLE IF NOT EXISTS companions (
+  companion_id SERIAL PRIMARY KEY,
+  event_id INTEGER NOT NULL,
+  guest_id INTEGER NOT NULL REFERENCES guests(guest_id),
+  name VARCHAR(100) NOT NULL,
+  dietary VARCHAR(255) NOT NULL DEFAULT '',
+  accessibility VARCHAR(255) NOT NULL DEFAULT '',
+  arrived BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX companions_guest ON companions (guest_id);
@@ -0,0 +1,14 @@
+/* history of the parties moved to another table at check-in because the
+   party grew beyond the seats of its table*/
+CREATE TABLE IF NOT EXISTS table_moves (
+  move_id SERIAL PRIMARY KEY,
+  event_id INTEGER NOT NULL,
+  guest_id INTEGER NOT NULL REFERENCES guests(guest_id),
+  name VARCHAR(100) NOT NULL,
+  from_table INTEGER NOT NULL,
+  to_table INTEGER NOT NULL,
+  accompanying_guests INTEGER NOT NULL CHECK (accompanying_guests >= 0),
+  time_moved TIMESTAMP NOT NULL
+);
+
+CREATE INDEX table_moves_event ON table_moves (event_id);
@@ -0,0 +1,13 @@
+CREATE TABLE guests_old (
+  id INTEGER NOT NULL REFERENCES tables(id),
+  name VARCHAR(100) NOT NULL UNIQUE,
+  accompanying_guests INTEGER CHECK (accompanying_guests >= 0),
+  status TEXT DEFAULT 'allotted' CHECK (status IN ('allotted', 'checked-in', 'checked-out')),
+  time_arrived TIMESTAMP
+);
+INSERT INTO guests_old (id, name, accompanying_guests, status, time_arrived)
+  SELECT id, name, accompanying_guests, status, time_arrived FROM guests;
+DROP TABLE guests;
+ALTER TABLE guests_old RENAME TO guests;
+ALTER TABLE tables DROP COLUMN event_id;
+DROP TABLE IF EXISTS events;
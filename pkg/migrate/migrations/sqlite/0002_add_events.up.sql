@@ -0,0 +1,29 @@
+/* Table to store events, every table and guest belongs to one event*/
+CREATE TABLE IF NOT EXISTS events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name VARCHAR(100) NOT NULL,
+  event_date DATE,
+  venue VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+/* existing tables and guests belong to the default event*/
+INSERT INTO events (id, name, venue) VALUES (1, 'Default', '');
+
+/* sqlite cannot add a column with both a foreign key and a default*/
+ALTER TABLE tables ADD COLUMN event_id INTEGER NOT NULL DEFAULT 1;
+
+/* guest names are unique per event, sqlite has to rebuild the table to
+   change the constraint*/
+CREATE TABLE guests_new (
+  id INTEGER NOT NULL REFERENCES tables(id),
+  name VARCHAR(100) NOT NULL,
+  accompanying_guests INTEGER CHECK (accompanying_guests >= 0),
+  status TEXT DEFAULT 'allotted' CHECK (status IN ('allotted', 'checked-in', 'checked-out')),
+  time_arrived TIMESTAMP,
+  event_id INTEGER NOT NULL DEFAULT 1 REFERENCES events(id),
+  UNIQUE (event_id, name)
+);
+INSERT INTO guests_new (id, name, accompanying_guests, status, time_arrived)
+  SELECT id, name, accompanying_guests, status, time_arrived FROM guests;
+DROP TABLE guests;
+ALTER TABLE guests_new RENAME TO guests;
@@ -0,0 +1,18 @@
+/* Table to store every stay of a guest, time_left is NULL while the guest
+   is present*/
+CREATE TABLE IF NOT EXISTS visits (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  event_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  accompanying_guests INTEGER NOT NULL CHECK (accompanying_guests >= 0),
+  time_arrived TIMESTAMP NOT NULL,
+  time_left TIMESTAMP,
+  FOREIGN KEY (event_id, name) REFERENCES guests(event_id, name)
+);
+
+/* guests who arrived before visits were recorded, the departure of guests
+   who already left is not known and recorded as the time of the migration*/
+INSERT INTO visits (event_id, name, accompanying_guests, time_arrived, time_left)
+  SELECT event_id, name, accompanying_guests, COALESCE(time_arrived, CURRENT_TIMESTAMP),
+    CASE WHEN status = 'checked-out' THEN CURRENT_TIMESTAMP END
+  FROM guests WHERE status IN ('checked-in', 'checked-out');
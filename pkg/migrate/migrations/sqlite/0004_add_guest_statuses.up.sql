@@ -0,0 +1,32 @@
+/* guests can be marked as no-show or cancelled*/
+/* sqlite cannot change a CHECK constraint, guests is rebuilt. visits
+   references guests and is rebuilt around it*/
+CREATE TABLE visits_copy AS SELECT * FROM visits;
+DROP TABLE visits;
+
+CREATE TABLE guests_new (
+  id INTEGER NOT NULL REFERENCES tables(id),
+  name VARCHAR(100) NOT NULL,
+  accompanying_guests INTEGER CHECK (accompanying_guests >= 0),
+  status TEXT DEFAULT 'allotted' CHECK (status IN ('allotted', 'checked-in', 'checked-out', 'no-show', 'cancelled')),
+  time_arrived TIMESTAMP,
+  event_id INTEGER NOT NULL DEFAULT 1 REFERENCES events(id),
+  UNIQUE (event_id, name)
+);
+INSERT INTO guests_new (id, name, accompanying_guests, status, time_arrived, event_id)
+  SELECT id, name, accompanying_guests, status, time_arrived, event_id FROM guests;
+DROP TABLE guests;
+ALTER TABLE guests_new RENAME TO guests;
+
+CREATE TABLE visits (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  event_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  accompanying_guests INTEGER NOT NULL CHECK (accompanying_guests >= 0),
+  time_arrived TIMESTAMP NOT NULL,
+  time_left TIMESTAMP,
+  FOREIGN KEY (event_id, name) REFERENCES guests(event_id, name)
+);
+INSERT INTO visits (id, event_id, name, accompanying_guests, time_arrived, time_left)
+  SELECT id, event_id, name, accompanying_guests, time_arrived, time_left FROM visits_copy;
+DROP TABLE visits_copy;
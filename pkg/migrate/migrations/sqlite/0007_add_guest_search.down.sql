@@ -0,0 +1,3 @@
+DROP TABLE guest_trigrams;
+DROP INDEX guests_event_search_key;
+ALTER TABLE guests DROP COLUMN search_key;
@@ -0,0 +1,16 @@
+/* folded name of a guest for search, lower case and without accents. It is
+   filled for the guests added before by the server when it starts. LIKE
+   only uses an index on a NOCASE column*/
+ALTER TABLE guests ADD COLUMN search_key TEXT COLLATE NOCASE;
+
+CREATE INDEX guests_event_search_key ON guests (event_id, search_key);
+
+/* Table to store the trigrams of the words of the search key of guests*/
+CREATE TABLE IF NOT EXISTS guest_trigrams (
+  event_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  trigram TEXT NOT NULL,
+  PRIMARY KEY (event_id, trigram, name)
+);
+
+CREATE INDEX guest_trigrams_event_name ON guest_trigrams (event_id, name);
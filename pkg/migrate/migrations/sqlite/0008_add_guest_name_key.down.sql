@@ -0,0 +1,8 @@
+DROP INDEX guests_event_time_arrived;
+DROP INDEX guests_event_status;
+DROP INDEX guests_event_table;
+CREATE INDEX guests_event_table ON guests (event_id, id, name);
+CREATE INDEX guests_event_status ON guests (event_id, status, name);
+CREATE INDEX guests_event_time_arrived ON guests (event_id, time_arrived, name);
+DROP INDEX guests_event_key;
+ALTER TABLE guests DROP COLUMN name_key;
@@ -0,0 +1,16 @@
+/* guests keep the display form of their name, name_key is the case folded
+   name which identifies a guest. Existing names were stored lower case, the
+   server folds them again when it starts as they have no search key*/
+ALTER TABLE guests ADD COLUMN name_key VARCHAR(100) NOT NULL DEFAULT '';
+
+UPDATE guests SET name_key = name, search_key = NULL;
+
+CREATE UNIQUE INDEX guests_event_key ON guests (event_id, name_key);
+
+/* guests are sorted by name key*/
+DROP INDEX guests_event_time_arrived;
+DROP INDEX guests_event_status;
+DROP INDEX guests_event_table;
+CREATE INDEX guests_event_table ON guests (event_id, id, name_key);
+CREATE INDEX guests_event_status ON guests (event_id, status, name_key);
+CREATE INDEX guests_event_time_arrived ON guests (event_id, time_arrived, name_key);
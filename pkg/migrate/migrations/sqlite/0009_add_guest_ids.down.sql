@@ -0,0 +1,49 @@
+DROP TABLE guest_trigrams;
+
+CREATE TABLE IF NOT EXISTS guest_trigrams (
+  event_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  trigram TEXT NOT NULL,
+  PRIMARY KEY (event_id, trigram, name)
+);
+
+CREATE INDEX guest_trigrams_event_name ON guest_trigrams (event_id, name);
+
+CREATE TABLE visits_copy AS SELECT * FROM visits;
+DROP TABLE visits;
+
+CREATE TABLE guests_new (
+  id INTEGER NOT NULL REFERENCES tables(id),
+  name VARCHAR(100) NOT NULL,
+  accompanying_guests INTEGER CHECK (accompanying_guests >= 0),
+  status TEXT DEFAULT 'allotted' CHECK (status IN ('allotted', 'checked-in', 'checked-out', 'no-show', 'cancelled')),
+  time_arrived TIMESTAMP,
+  event_id INTEGER NOT NULL DEFAULT 1 REFERENCES events(id),
+  time_left TIMESTAMP,
+  search_key TEXT COLLATE NOCASE,
+  name_key VARCHAR(100) NOT NULL DEFAULT '',
+  UNIQUE (event_id, name)
+);
+INSERT INTO guests_new (id, name, accompanying_guests, status, time_arrived, event_id, time_left, name_key)
+  SELECT id, name, accompanying_guests, status, time_arrived, event_id, time_left, name_key FROM guests;
+DROP TABLE guests;
+ALTER TABLE guests_new RENAME TO guests;
+
+CREATE UNIQUE INDEX guests_event_key ON guests (event_id, name_key);
+CREATE INDEX guests_event_table ON guests (event_id, id, name_key);
+CREATE INDEX guests_event_status ON guests (event_id, status, name_key);
+CREATE INDEX guests_event_time_arrived ON guests (event_id, time_arrived, name_key);
+CREATE INDEX guests_event_search_key ON guests (event_id, search_key);
+
+CREATE TABLE visits (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  event_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  accompanying_guests INTEGER NOT NULL CHECK (accompanying_guests >= 0),
+  time_arrived TIMESTAMP NOT NULL,
+  time_left TIMESTAMP,
+  FOREIGN KEY (event_id, name) REFERENCES guests(event_id, name)
+);
+INSERT INTO visits (id, event_id, name, accompanying_guests, time_arrived, time_left)
+  SELECT id, event_id, name, accompanying_guests, time_arrived, time_left FROM visits_copy;
+DROP TABLE visits_copy;
@@ -0,0 +1,60 @@
+/* guests are identified by guest_id, several guests of an event can share
+   a name*/
+/* sqlite cannot add a primary key, guests is rebuilt. visits references
+   guests and is rebuilt around it*/
+CREATE TABLE visits_copy AS SELECT * FROM visits;
+DROP TABLE visits;
+
+CREATE TABLE guests_new (
+  guest_id INTEGER PRIMARY KEY AUTOINCREMENT,
+  id INTEGER NOT NULL REFERENCES tables(id),
+  name VARCHAR(100) NOT NULL,
+  accompanying_guests INTEGER CHECK (accompanying_guests >= 0),
+  status TEXT DEFAULT 'allotted' CHECK (status IN ('allotted', 'checked-in', 'checked-out', 'no-show', 'cancelled')),
+  time_arrived TIMESTAMP,
+  event_id INTEGER NOT NULL DEFAULT 1 REFERENCES events(id),
+  time_left TIMESTAMP,
+  search_key TEXT COLLATE NOCASE,
+  name_key VARCHAR(100) NOT NULL DEFAULT ''
+);
+/* the server indexes the guests again when it starts as they have no search
+   key*/
+INSERT INTO guests_new (id, name, accompanying_guests, status, time_arrived, event_id, time_left, name_key)
+  SELECT id, name, accompanying_guests, status, time_arrived, event_id, time_left, name_key FROM guests;
+DROP TABLE guests;
+ALTER TABLE guests_new RENAME TO guests;
+
+CREATE INDEX guests_event_key ON guests (event_id, name_key);
+CREATE INDEX guests_event_table ON guests (event_id, id, name_key);
+CREATE INDEX guests_event_status ON guests (event_id, status, name_key);
+CREATE INDEX guests_event_time_arrived ON guests (event_id, time_arrived, name_key);
+CREATE INDEX guests_event_search_key ON guests (event_id, search_key);
+
+/* visits reference the guest by id*/
+CREATE TABLE visits (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  event_id INTEGER NOT NULL,
+  guest_id INTEGER NOT NULL REFERENCES guests(guest_id),
+  name VARCHAR(100) NOT NULL,
+  accompanying_guests INTEGER NOT NULL CHECK (accompanying_guests >= 0),
+  time_arrived TIMESTAMP NOT NULL,
+  time_left TIMESTAMP
+);
+INSERT INTO visits (id, event_id, guest_id, name, accompanying_guests, time_arrived, time_left)
+  SELECT v.id, v.event_id, g.guest_id, v.name, v.accompanying_guests, v.time_arrived, v.time_left
+  FROM visits_copy v JOIN guests g ON g.event_id = v.event_id AND g.name = v.name;
+DROP TABLE visits_copy;
+
+CREATE INDEX visits_guest_id ON visits (guest_id);
+
+/* the trigrams reference the guest by id*/
+DROP TABLE guest_trigrams;
+
+CREATE TABLE IF NOT EXISTS guest_trigrams (
+  event_id INTEGER NOT NULL,
+  guest_id INTEGER NOT NULL,
+  trigram TEXT NOT NULL,
+  PRIMARY KEY (event_id, trigram, guest_id)
+);
+
+CREATE INDEX guest_trigrams_guest ON guest_trigrams (guest_id);
@@ -0,0 +1,17 @@
+/* seating rules of an event. together and apart pair the guests named name
+   and other_name, requires makes the guests named name need a table tagged
+   tag and table_tag tags table_id with tag. Guests are matched by name key,
+   the guests need not be added yet*/
+CREATE TABLE IF NOT EXISTS constraints (
+  constraint_id INTEGER PRIMARY KEY AUTOINCREMENT,
+  event_id INTEGER NOT NULL,
+  kind TEXT NOT NULL CHECK (kind IN ('together', 'apart', 'requires', 'table_tag')),
+  name VARCHAR(100) NOT NULL DEFAULT '',
+  name_key VARCHAR(100) NOT NULL DEFAULT '',
+  other_name VARCHAR(100) NOT NULL DEFAULT '',
+  other_key VARCHAR(100) NOT NULL DEFAULT '',
+  table_id INTEGER REFERENCES tables(id),
+  tag VARCHAR(50) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX constraints_event ON constraints (event_id, kind);
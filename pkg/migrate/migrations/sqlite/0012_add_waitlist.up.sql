@@ -0,0 +1,14 @@
+/* guest parties waiting for a table in the order they were queued. table_id
+   is the table the party prefers, guest_id the guest added to the guest list
+   once the party got a table*/
+CREATE TABLE IF NOT EXISTS waitlist (
+  waitlist_id INTEGER PRIMARY KEY AUTOINCREMENT,
+  event_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  accompanying_guests INTEGER NOT NULL DEFAULT 0,
+  priority INTEGER NOT NULL DEFAULT 0,
+  table_id INTEGER REFERENCES tables(id),
+  guest_id INTEGER REFERENCES guests(guest_id)
+);
+
+CREATE INDEX waitlist_event ON waitlist (event_id, guest_id);
@@ -3,7 +3,13 @@ package models
 import (
 	"database/sql"
 	"fmt"
+	db "github.com/getground/tech-tasks/backend/pkg/db"
 	_ "github.com/go-sql-driver/mysql"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,30 +17,472 @@ const (
 	CHECKEDIN  = "checked-in"
 	CHECKEDOUT = "checked-out"
 	ALLOTTED   = "allotted"
+	NOSHOW     = "no-show"
+	CANCELLED  = "cancelled"
 )
 
+// StateMachine maps every status to the statuses a guest can move to from it
+type StateMachine map[string][]string
+
+// GuestStatus is the life cycle of a guest. Guests are allotted a table when
+// they are added to the guest list, check-in when they arrive and check-out
+// when they leave. Guests who left can come back and no-shows can still
+// arrive late, cancelled guests keep their status.
+var GuestStatus = StateMachine{
+	ALLOTTED:   {CHECKEDIN, NOSHOW, CANCELLED},
+	CHECKEDIN:  {CHECKEDOUT},
+	CHECKEDOUT: {CHECKEDIN},
+	NOSHOW:     {CHECKEDIN},
+	CANCELLED:  {},
+}
+
+// TransitionError is returned for a change of the status of a guest the
+// state machine does not allow
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("guest status cannot change from %s to %s", e.From, e.To)
+}
+
+// Check returns a *TransitionError unless a guest can move from status from
+// to status to
+func (m StateMachine) Check(from string, to string) error {
+	for _, status := range m[from] {
+		if status == to {
+			return nil
+		}
+	}
+	return &TransitionError{From: from, To: to}
+}
+
+// Sources returns the sorted statuses a guest can move to status to from
+func (m StateMachine) Sources(to string) []string {
+	var sources []string
+	for from := range m {
+		if m.Check(from, to) == nil {
+			sources = append(sources, from)
+		}
+	}
+	sort.Strings(sources)
+	return sources
+}
+
 type Table struct {
 	Id       int64
 	Capacity int64
 }
 
+// Visit is a stay of a guest at the party, TimeLeft is zero while the guest
+// is present
+type Visit struct {
+	GuestId            int64
+	Name               string
+	AccompanyingGuests int64
+	TimeArrived        time.Time
+	TimeLeft           time.Time
+}
+
+// TableSeats counts the parties allotted to a table and the seats they take,
+// the guest plus the accompanying guests, by the status of the parties
+type TableSeats struct {
+	Table
+	AllottedParties   int64
+	AllottedSeats     int64
+	CheckedInParties  int64
+	CheckedInSeats    int64
+	CheckedOutParties int64
+	CheckedOutSeats   int64
+	NoShowParties     int64
+	NoShowSeats       int64
+	CancelledParties  int64
+	CancelledSeats    int64
+}
+
+// Event is a party hosted by the server, every table and guest belongs to
+// exactly one event. Date is zero when the event has no date.
+type Event struct {
+	Id    int64
+	Name  string
+	Date  time.Time
+	Venue string
+}
+
+// DefaultEvent owns the tables and guests that were added without an event
+const DefaultEvent int64 = 1
+
+// Guests is a guest party. Id identifies the guest, Table is the id of the
+// table allotted to the guest. Name is the display name of the guest, which
+// several guests can share and guests are found by the NameKey of.
 type Guests struct {
+	Id                 int64
 	Table              int64
 	AccompanyingGuests int64
 	Status             string
 	TimeArrived        time.Time
+	TimeLeft           time.Time
+	Name               string
+}
+
+// Companion is a named accompanying guest of the guest GuestId with the
+// dietary and accessibility needs to prepare for. Arrived tells whether the
+// companion came with the guest at the latest check-in.
+type Companion struct {
+	GuestId       int64
+	Name          string
+	Dietary       string
+	Accessibility string
+	Arrived       bool
+}
+
+// kinds of seating constraints
+const (
+	TOGETHER = "together"
+	APART    = "apart"
+	REQUIRES = "requires"
+	TABLETAG = "table_tag"
+)
+
+// Constraint is a seating rule of an event. Together and apart constraints
+// pair the guests named Name and Other, a requires constraint makes the
+// guests named Name need a table tagged Tag and a table tag constraint tags
+// the table Table with Tag. Guests are matched by name key, so constraints
+// can be recorded before the guests are added.
+type Constraint struct {
+	Id    int64
+	Kind  string
+	Name  string
+	Other string
+	Table int64
+	Tag   string
+}
+
+// WaitlistEntry is a guest party waiting for a table. Table is the table
+// the party prefers, 0 when any table will do. GuestId is the guest added
+// to the guest list once the party got a table, 0 while the party waits.
+type WaitlistEntry struct {
+	Id                 int64
 	Name               string
+	AccompanyingGuests int64
+	Priority           int64
+	Table              int64
+	GuestId            int64
+}
+
+// TableMove records that the party of guest GuestId grew to
+// AccompanyingGuests at check-in and was moved from table From to table To
+type TableMove struct {
+	GuestId            int64
+	Name               string
+	From               int64
+	To                 int64
+	AccompanyingGuests int64
+	TimeMoved          time.Time
+}
+
+// DisplayName returns name as it is shown, in Unicode NFC with single spaces
+// between words and the case kept
+func DisplayName(name string) string {
+	return strings.Join(strings.Fields(norm.NFC.String(name)), " ")
+}
+
+// NameKey returns the key of name which identifies a guest: the display name
+// case folded, so names differing only in case or spacing are the same guest
+func NameKey(name string) string {
+	return norm.NFC.String(cases.Fold().String(DisplayName(name)))
+}
+
+// keys guests can be sorted by
+const (
+	SortByName        = "name"
+	SortByTable       = "table"
+	SortByTimeArrived = "time_arrived"
+)
+
+var sortColumns = map[string]string{
+	SortByName:        "name_key",
+	SortByTable:       "id",
+	SortByTimeArrived: "time_arrived",
+}
+
+// escapes the wildcards of a LIKE pattern with '!'
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// GuestQuery selects guests by status, table, name prefix and arrival time,
+// zero fields select all guests. NamePrefix is the start of the name key.
+// Guests are ordered by Sort, the name key by default, and then by name key
+// and id. After starts the result behind the given guest in that order and
+// Limit bounds the number of guests, 0 returns all.
+type GuestQuery struct {
+	Statuses      []string
+	Table         int64
+	NamePrefix    string
+	ArrivedAfter  time.Time
+	ArrivedBefore time.Time
+	Sort          string
+	Desc          bool
+	After         *Guests
+	Limit         int
+}
+
+func (q GuestQuery) sort() string {
+	if q.Sort == "" {
+		return SortByName
+	}
+	return q.Sort
+}
+
+func (q GuestQuery) sortKey(g Guests) interface{} {
+	switch q.sort() {
+	case SortByTable:
+		return g.Table
+	case SortByTimeArrived:
+		return g.TimeArrived
+	}
+	return NameKey(g.Name)
+}
+
+// Less reports whether guest a comes before guest b in the order of q
+func (q GuestQuery) Less(a, b Guests) bool {
+	var cmp int
+	switch q.sort() {
+	case SortByTable:
+		cmp = compareInt(a.Table, b.Table)
+	case SortByTimeArrived:
+		cmp = compareTime(a.TimeArrived, b.TimeArrived)
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(NameKey(a.Name), NameKey(b.Name))
+	}
+	if cmp == 0 {
+		cmp = compareInt(a.Id, b.Id)
+	}
+	if q.Desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Match reports whether q selects guest g, ignoring the limit
+func (q GuestQuery) Match(g Guests) bool {
+	if len(q.Statuses) > 0 {
+		found := false
+		for _, status := range q.Statuses {
+			found = found || status == g.Status
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.Table != 0 && g.Table != q.Table {
+		return false
+	}
+	if !strings.HasPrefix(NameKey(g.Name), q.NamePrefix) {
+		return false
+	}
+	if !q.ArrivedAfter.IsZero() && (g.TimeArrived.IsZero() || g.TimeArrived.Before(q.ArrivedAfter)) {
+		return false
+	}
+	if !q.ArrivedBefore.IsZero() && (g.TimeArrived.IsZero() || !g.TimeArrived.Before(q.ArrivedBefore)) {
+		return false
+	}
+	return q.After == nil || q.Less(*q.After, g)
+}
+
+// Party is the storage interface used by the controller. DbTransaction runs
+// fn against a Party bound to a single transaction which is committed when
+// fn returns nil and rolled back otherwise.
+type Party interface {
+	DbAddTable(int64) (int64, error)
+	DbCheckTableExists(int64) (int64, error)
+	DbLockTable(int64) (int64, error)
+	DbLockEvent() error
+	DbAddGuestList(Guests) (int64, error)
+	DbUpdateGuestStatus(int64, string) error
+	DbUpdateGuestList(Guests) error
+	DbGetGuestInTable(int64) (string, error)
+	DbGetGuestStatus(int64) (string, error)
+	DbGetGuestList() ([]Guests, error)
+	DbQueryGuests(GuestQuery) ([]Guests, error)
+	DbSearchGuests(string, int) ([]Guests, error)
+	DbGetTableCapacity(int64) (int64, error)
+	DbGetTableIdOfGuest(int64) (int64, error)
+	DbCheckGuestExists(string) (int64, error)
+	DbFindGuests(string) ([]Guests, error)
+	DbGetTables() ([]Table, error)
+	DbGetTableSeats() ([]TableSeats, error)
+	DbGetGuestsInTable(int64) ([]Guests, error)
+	DbUpdateTableCapacity(int64, int64) error
+	DbDeleteTable(int64) error
+	DbGetGuest(int64) (Guests, error)
+	DbUpdateGuestAllotment(Guests) error
+	DbDeleteGuest(int64) error
+	DbGetCompanions(...int64) ([]Companion, error)
+	DbSetCompanions(int64, []Companion) error
+	DbAddConstraint(Constraint) (int64, error)
+	DbGetConstraints() ([]Constraint, error)
+	DbDeleteConstraint(int64) (int64, error)
+	DbAddWaitlistEntry(WaitlistEntry) (int64, error)
+	DbGetWaitlist() ([]WaitlistEntry, error)
+	DbAllotWaitlistEntry(int64, int64) (int64, error)
+	DbDeleteWaitlistEntry(int64) (int64, error)
+	DbAddTableMove(TableMove) error
+	DbGetTableMoves() ([]TableMove, error)
+	DbAddVisit(int64) error
+	DbEndVisit(int64) error
+	DbGetVisits(...int64) ([]Visit, error)
+	DbTransaction(func(Party) error) error
+	DbForEvent(int64) Party
+	DbAddEvent(Event) (int64, error)
+	DbGetEvents() ([]Event, error)
+	DbGetEvent(int64) (Event, error)
+	DbCheckEventExists(int64) (int64, error)
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// rebinder rewrites the ? placeholders of queries to the $n placeholders
+// used by postgres
+type rebinder struct {
+	q querier
+}
+
+func rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
 
+func (r rebinder) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return r.q.Exec(rebind(query), args...)
+}
+
+func (r rebinder) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return r.q.Query(rebind(query), args...)
+}
+
+func (r rebinder) QueryRow(query string, args ...interface{}) *sql.Row {
+	return r.q.QueryRow(rebind(query), args...)
+}
+
+// PartyModel stores the party in a sql database. Dialect is one of db.MySQL,
+// db.Postgres or db.SQLite, mysql is assumed when it is empty. Tables and
+// guests are read and written in the event EventId, DefaultEvent when it is
+// zero.
 type PartyModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Dialect string
+	EventId int64
+	tx      *sql.Tx
+}
+
+func (p PartyModel) event() int64 {
+	if p.EventId == 0 {
+		return DefaultEvent
+	}
+	return p.EventId
+}
+
+// DbForEvent returns a PartyModel scoped to event id which shares the
+// connection and transaction of p
+func (p PartyModel) DbForEvent(id int64) Party {
+	p.EventId = id
+	return p
+}
+
+func (p PartyModel) conn() querier {
+	var q querier = p.DB
+	if p.tx != nil {
+		q = p.tx
+	}
+	if p.Dialect == db.Postgres {
+		return rebinder{q}
+	}
+	return q
+}
+
+// forUpdate returns the locking clause for rows read in a transaction.
+// sqlite has no row locks, its transactions take the database write lock
+// when they begin.
+func (p PartyModel) forUpdate() string {
+	if p.Dialect == db.SQLite {
+		return ""
+	}
+	return " FOR UPDATE"
+}
+
+func (p PartyModel) DbTransaction(fn func(Party) error) error {
+	// already inside a transaction, join it
+	if p.tx != nil {
+		return fn(p)
+	}
+	tx, err := p.DB.Begin()
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	// no-op once the transaction is committed
+	defer tx.Rollback()
+	if err = fn(PartyModel{DB: p.DB, Dialect: p.Dialect, EventId: p.EventId, tx: tx}); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		fmt.Println(err)
+		return err
+	}
+	return nil
 }
 
 func (p PartyModel) DbAddTable(capacity int64) (int64, error) {
 	var resId int64
-	res, err := p.DB.Exec(
-		`INSERT INTO tables(capacity) VALUES (?)`,
-		capacity)
+	if p.Dialect == db.Postgres {
+		// lib/pq does not support LastInsertId
+		err := p.conn().QueryRow(
+			`INSERT INTO tables(capacity, event_id) VALUES (?, ?) RETURNING id`,
+			capacity, p.event()).Scan(&resId)
+		if err != nil {
+			fmt.Println(err)
+		}
+		return resId, err
+	}
+	res, err := p.conn().Exec(
+		`INSERT INTO tables(capacity, event_id) VALUES (?, ?)`,
+		capacity, p.event())
 	if err != nil {
 		fmt.Println(err)
 		return resId, err
@@ -47,36 +495,66 @@ func (p PartyModel) DbAddTable(capacity int64) (int64, error) {
 	return resId, nil
 }
 
-func (p PartyModel) DbGetTableIdOfGuest(name string) (int64, error) {
-	var id int64
-	res := p.DB.QueryRow("SELECT id FROM guests WHERE name = ?",
-		name)
-	err := res.Scan(&id)
+func (p PartyModel) DbGetTableIdOfGuest(id int64) (int64, error) {
+	var table int64
+	res := p.conn().QueryRow("SELECT id FROM guests WHERE guest_id = ? AND event_id = ?",
+		id, p.event())
+	err := res.Scan(&table)
 	if err != nil {
 		fmt.Println(err)
-		return id, err
+		return table, err
 	}
-	return id, err
+	return table, err
 
 }
 
-func (p PartyModel) DbAddGuestList(guest Guests) error {
-	_, err := p.DB.Exec(
-		`INSERT INTO guests(id, accompanying_guests, name) VALUES (?, ?, ?)`,
-		guest.Table, guest.AccompanyingGuests, guest.Name)
+// DbAddGuestList adds guest to the guest list and returns the id of the guest
+func (p PartyModel) DbAddGuestList(guest Guests) (int64, error) {
+	var id int64
+	key := SearchKey(guest.Name)
+	if p.Dialect == db.Postgres {
+		err := p.conn().QueryRow(
+			`INSERT INTO guests(id, accompanying_guests, name, name_key, search_key, event_id) VALUES (?, ?, ?, ?, ?, ?) RETURNING guest_id`,
+			guest.Table, guest.AccompanyingGuests, guest.Name, NameKey(guest.Name), key, p.event()).Scan(&id)
+		if err != nil {
+			fmt.Println(err)
+			return id, err
+		}
+	} else {
+		res, err := p.conn().Exec(
+			`INSERT INTO guests(id, accompanying_guests, name, name_key, search_key, event_id) VALUES (?, ?, ?, ?, ?, ?)`,
+			guest.Table, guest.AccompanyingGuests, guest.Name, NameKey(guest.Name), key, p.event())
+		if err != nil {
+			fmt.Println(err)
+			return id, err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return id, err
+		}
+	}
+	return id, p.indexGuest(p.event(), id, key)
+}
+
+// indexGuest replaces the trigrams of guest id of event with those of the
+// search key
+func (p PartyModel) indexGuest(event int64, id int64, key string) error {
+	_, err := p.conn().Exec(`DELETE FROM guest_trigrams WHERE guest_id = ?`, id)
 	if err != nil {
 		fmt.Println(err)
 		return err
 	}
-	return nil
-}
-
-func (p PartyModel) DbUpdateGuestStatus(name string, status string) error {
-	_, err := p.DB.Exec(
-		`UPDATE guests SET 
-		status = ?
-		WHERE name = ?`,
-		status, name)
+	trigrams := Trigrams(key)
+	if len(trigrams) == 0 {
+		return nil
+	}
+	var args []interface{}
+	for _, trigram := range trigrams {
+		args = append(args, event, id, trigram)
+	}
+	_, err = p.conn().Exec(
+		`INSERT INTO guest_trigrams(event_id, guest_id, trigram) VALUES (?, ?, ?)`+
+			strings.Repeat(", (?, ?, ?)", len(trigrams)-1),
+		args...)
 	if err != nil {
 		fmt.Println(err)
 		return err
@@ -84,26 +562,114 @@ func (p PartyModel) DbUpdateGuestStatus(name string, status string) error {
 	return nil
 }
 
-func (p PartyModel) DbUpdateGuestList(guest Guests) error {
-	_, err := p.DB.Exec(
-		`UPDATE guests SET 
-		status = ?,
-		accompanying_guests = ?,
-		time_arrived = ?
-		WHERE name = ?`,
-		guest.Status, guest.AccompanyingGuests,
-		time.Now(), guest.Name)
+// DbIndexGuests fills the name key, search key and trigrams of the guests of
+// every event added before guests were indexed and returns the number of
+// guests indexed
+func (p PartyModel) DbIndexGuests() (int, error) {
+	type unindexed struct {
+		id    int64
+		event int64
+		name  string
+	}
+	var guests []unindexed
+	res, err := p.conn().Query(`SELECT guest_id, event_id, name FROM guests WHERE search_key IS NULL`)
 	if err != nil {
 		fmt.Println(err)
+		return 0, err
+	}
+	for res.Next() {
+		var g unindexed
+		if err := res.Scan(&g.id, &g.event, &g.name); err != nil {
+			res.Close()
+			fmt.Println(err)
+			return 0, err
+		}
+		guests = append(guests, g)
+	}
+	res.Close()
+	if len(guests) == 0 {
+		return 0, nil
+	}
+	err = p.DbTransaction(func(tx Party) error {
+		pm := tx.(PartyModel)
+		for _, g := range guests {
+			key := SearchKey(g.name)
+			_, err := pm.conn().Exec(`UPDATE guests SET name_key = ?, search_key = ? WHERE guest_id = ?`,
+				NameKey(g.name), key, g.id)
+			if err != nil {
+				fmt.Println(err)
+				return err
+			}
+			if err := pm.indexGuest(g.event, g.id, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(guests), nil
+}
+
+// transition moves guest id to status to with the update of the columns in
+// set. Only guests in a status the GuestStatus state machine allows to move
+// to status to are updated, a *TransitionError is returned for the others
+// and sql.ErrNoRows when the guest does not exist.
+func (p PartyModel) transition(id int64, to string, set string, args ...interface{}) error {
+	sources := GuestStatus.Sources(to)
+	var affected int64
+	if len(sources) > 0 {
+		args = append(args, id, p.event())
+		for _, from := range sources {
+			args = append(args, from)
+		}
+		res, err := p.conn().Exec(
+			`UPDATE guests SET `+set+`
+			WHERE guest_id = ? AND event_id = ?
+			AND status IN (?`+strings.Repeat(", ?", len(sources)-1)+`)`,
+			args...)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		if affected, err = res.RowsAffected(); err != nil {
+			fmt.Println(err)
+			return err
+		}
+	}
+	if affected > 0 {
+		return nil
+	}
+	from, err := p.DbGetGuestStatus(id)
+	if err != nil {
 		return err
 	}
-	return nil
+	return &TransitionError{From: from, To: to}
+}
+
+// DbUpdateGuestStatus changes the status of guest id, the departure time is
+// recorded when the guest checks-out
+func (p PartyModel) DbUpdateGuestStatus(id int64, status string) error {
+	if status == CHECKEDOUT {
+		return p.transition(id, status, `status = ?, time_left = ?`, status, time.Now())
+	}
+	return p.transition(id, status, `status = ?`, status)
+}
+
+func (p PartyModel) DbUpdateGuestList(guest Guests) error {
+	return p.transition(guest.Id, guest.Status,
+		`status = ?,
+		accompanying_guests = ?,
+		time_arrived = ?,
+		time_left = NULL`,
+		guest.Status, guest.AccompanyingGuests, time.Now())
 }
 
 func (p PartyModel) DbGetGuestInTable(id int64) (string, error) {
 	var name string
-	res := p.DB.QueryRow("SELECT name FROM guests WHERE id = ?",
-		id)
+	res := p.conn().QueryRow("SELECT name FROM guests WHERE id = ? AND event_id = ?",
+		id, p.event())
 	err := res.Scan(&name)
 	if err != nil && err != sql.ErrNoRows {
 		fmt.Println(err)
@@ -112,10 +678,10 @@ func (p PartyModel) DbGetGuestInTable(id int64) (string, error) {
 	return name, nil
 }
 
-func (p PartyModel) DbGetGuestStatus(name string) (string, error) {
+func (p PartyModel) DbGetGuestStatus(id int64) (string, error) {
 	var status string
-	res := p.DB.QueryRow("SELECT status FROM guests WHERE name = ?",
-		name)
+	res := p.conn().QueryRow("SELECT status FROM guests WHERE guest_id = ? AND event_id = ?",
+		id, p.event())
 	err := res.Scan(&status)
 	if err != nil {
 		fmt.Println(err)
@@ -124,34 +690,10 @@ func (p PartyModel) DbGetGuestStatus(name string) (string, error) {
 	return status, nil
 }
 
-func (p PartyModel) DbGetCapacitySum() (int64, error) {
-	var totalCapacity int64
-	res := p.DB.QueryRow("SELECT SUM(capacity) FROM tables")
-	err := res.Scan(&totalCapacity)
-	if err != nil && err != sql.ErrNoRows {
-		fmt.Println(err)
-		return totalCapacity, err
-	}
-	return totalCapacity, nil
-}
-
-func (p PartyModel) DbGetAccompanyingGuestsSum(status string) (int64, int64, error) {
-	var accompanyingGuests int64
-	var guestsCount int64
-	res := p.DB.QueryRow("SELECT SUM(accompanying_guests), COUNT(name) FROM guests WHERE status = ?",
-		status)
-	err := res.Scan(&accompanyingGuests, &guestsCount)
-	if err != nil && err != sql.ErrNoRows {
-		fmt.Println(err)
-		return accompanyingGuests, guestsCount, err
-	}
-	return accompanyingGuests, guestsCount, nil
-
-}
-
 func (p PartyModel) DbGetGuestList() ([]Guests, error) {
 	var guestList []Guests
-	res, err := p.DB.Query("SELECT id, name, accompanying_guests FROM guests")
+	res, err := p.conn().Query("SELECT guest_id, id, name, accompanying_guests, status FROM guests WHERE event_id = ?",
+		p.event())
 	if err != nil {
 		fmt.Println(err)
 		return guestList, err
@@ -159,7 +701,7 @@ func (p PartyModel) DbGetGuestList() ([]Guests, error) {
 	defer res.Close()
 	for res.Next() {
 		var gl Guests
-		err := res.Scan(&gl.Table, &gl.Name, &gl.AccompanyingGuests)
+		err := res.Scan(&gl.Id, &gl.Table, &gl.Name, &gl.AccompanyingGuests, &gl.Status)
 		if err != nil {
 			fmt.Println(err)
 			return guestList, err
@@ -169,32 +711,163 @@ func (p PartyModel) DbGetGuestList() ([]Guests, error) {
 	return guestList, nil
 }
 
-func (p PartyModel) DbGetArrivedGuests() ([]Guests, error) {
-	var arrivedGuests []Guests
-	res, err := p.DB.Query(`SELECT name, accompanying_guests, time_arrived 
-				   FROM guests 
-				   WHERE (status = ?) OR (status = ?)`,
-		CHECKEDIN, CHECKEDOUT)
+// DbQueryGuests returns the guests selected by q in the order of q
+func (p PartyModel) DbQueryGuests(q GuestQuery) ([]Guests, error) {
+	var guests []Guests
+	where := []string{"event_id = ?"}
+	args := []interface{}{p.event()}
+	if len(q.Statuses) > 0 {
+		where = append(where, "status IN (?"+strings.Repeat(", ?", len(q.Statuses)-1)+")")
+		for _, status := range q.Statuses {
+			args = append(args, status)
+		}
+	}
+	if q.Table != 0 {
+		where = append(where, "id = ?")
+		args = append(args, q.Table)
+	}
+	if q.NamePrefix != "" {
+		where = append(where, "name_key LIKE ? ESCAPE '!'")
+		args = append(args, likeEscaper.Replace(q.NamePrefix)+"%")
+	}
+	if !q.ArrivedAfter.IsZero() {
+		where = append(where, "time_arrived >= ?")
+		args = append(args, q.ArrivedAfter)
+	}
+	if !q.ArrivedBefore.IsZero() {
+		where = append(where, "time_arrived < ?")
+		args = append(args, q.ArrivedBefore)
+	}
+
+	column := sortColumns[q.sort()]
+	op, dir := ">", "ASC"
+	if q.Desc {
+		op, dir = "<", "DESC"
+	}
+	// keyset pagination, the name key and then the guest id break the ties
+	// of the other sort keys
+	columns := []string{"name_key", "guest_id"}
+	if column != "name_key" {
+		columns = append([]string{column}, columns...)
+	}
+	if q.After != nil {
+		values := []interface{}{NameKey(q.After.Name), q.After.Id}
+		if column != "name_key" {
+			values = append([]interface{}{q.sortKey(*q.After)}, values...)
+		}
+		cond, condArgs := keyset(columns, values, op)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+	query := `SELECT guest_id, id, name, accompanying_guests, status, time_arrived, time_left
+		FROM guests
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY ` + strings.Join(columns, " "+dir+", ") + " " + dir
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	res, err := p.conn().Query(query, args...)
 	if err != nil {
 		fmt.Println(err)
-		return arrivedGuests, err
+		return guests, err
 	}
 	defer res.Close()
 	for res.Next() {
-		var ag Guests
-		err := res.Scan(&ag.Name, &ag.AccompanyingGuests, &ag.TimeArrived)
+		var g Guests
+		var timeArrived, timeLeft sql.NullTime
+		err := res.Scan(&g.Id, &g.Table, &g.Name, &g.AccompanyingGuests, &g.Status, &timeArrived, &timeLeft)
 		if err != nil {
 			fmt.Println(err)
-			return arrivedGuests, err
+			return guests, err
+		}
+		g.TimeArrived = timeArrived.Time
+		g.TimeLeft = timeLeft.Time
+		guests = append(guests, g)
+	}
+	return guests, nil
+}
+
+// keyset returns the condition selecting the rows after values in the order
+// of columns, op is > for ascending and < for descending order
+func keyset(columns []string, values []interface{}, op string) (string, []interface{}) {
+	cond := columns[len(columns)-1] + " " + op + " ?"
+	args := []interface{}{values[len(values)-1]}
+	for i := len(columns) - 2; i >= 0; i-- {
+		cond = "(" + columns[i] + " " + op + " ? OR (" + columns[i] + " = ? AND " + cond + "))"
+		args = append([]interface{}{values[i], values[i]}, args...)
+	}
+	return cond, args
+}
+
+// DbSearchGuests returns the search candidates of the search key query, at
+// most limit guests whose search key starts with query followed by at most
+// limit other guests sharing the most trigrams with query
+func (p PartyModel) DbSearchGuests(query string, limit int) ([]Guests, error) {
+	guests, err := p.searchGuests(`SELECT guest_id, id, name, accompanying_guests, status
+		FROM guests
+		WHERE event_id = ? AND search_key LIKE ? ESCAPE '!'
+		ORDER BY search_key, guest_id
+		LIMIT ?`, p.event(), likeEscaper.Replace(query)+"%", limit)
+	if err != nil {
+		return guests, err
+	}
+	trigrams := Trigrams(query)
+	if len(trigrams) == 0 {
+		return guests, nil
+	}
+	args := []interface{}{p.event()}
+	for _, trigram := range trigrams {
+		args = append(args, trigram)
+	}
+	args = append(args, limit)
+	similar, err := p.searchGuests(`SELECT g.guest_id, g.id, g.name, g.accompanying_guests, g.status
+		FROM (SELECT guest_id, COUNT(*) AS shared
+			FROM guest_trigrams
+			WHERE event_id = ? AND trigram IN (?`+strings.Repeat(", ?", len(trigrams)-1)+`)
+			GROUP BY guest_id
+			ORDER BY shared DESC, guest_id
+			LIMIT ?) t
+		JOIN guests g ON g.guest_id = t.guest_id
+		ORDER BY t.shared DESC, g.guest_id`, args...)
+	if err != nil {
+		return guests, err
+	}
+	found := map[int64]bool{}
+	for _, g := range guests {
+		found[g.Id] = true
+	}
+	for _, g := range similar {
+		if !found[g.Id] {
+			guests = append(guests, g)
+		}
+	}
+	return guests, nil
+}
+
+func (p PartyModel) searchGuests(query string, args ...interface{}) ([]Guests, error) {
+	var guests []Guests
+	res, err := p.conn().Query(query, args...)
+	if err != nil {
+		fmt.Println(err)
+		return guests, err
+	}
+	defer res.Close()
+	for res.Next() {
+		var g Guests
+		if err := res.Scan(&g.Id, &g.Table, &g.Name, &g.AccompanyingGuests, &g.Status); err != nil {
+			fmt.Println(err)
+			return guests, err
 		}
-		arrivedGuests = append(arrivedGuests, ag)
+		guests = append(guests, g)
 	}
-	return arrivedGuests, nil
+	return guests, nil
 }
 
 func (p PartyModel) DbGetTableCapacity(id int64) (int64, error) {
 	var capacity int64
-	res := p.DB.QueryRow("SELECT capacity FROM tables WHERE id = ?", id)
+	res := p.conn().QueryRow("SELECT capacity FROM tables WHERE id = ? AND event_id = ?", id, p.event())
 	err := res.Scan(&capacity)
 	if err != nil {
 		fmt.Println(err)
@@ -205,7 +878,7 @@ func (p PartyModel) DbGetTableCapacity(id int64) (int64, error) {
 
 func (p PartyModel) DbCheckTableExists(id int64) (int64, error) {
 	var exists int64
-	res := p.DB.QueryRow("SELECT EXISTS(SELECT * FROM tables WHERE id = ?)", id)
+	res := p.conn().QueryRow("SELECT COUNT(*) FROM tables WHERE id = ? AND event_id = ?", id, p.event())
 	err := res.Scan(&exists)
 	if err != nil && err != sql.ErrNoRows {
 		fmt.Println(err)
@@ -214,9 +887,40 @@ func (p PartyModel) DbCheckTableExists(id int64) (int64, error) {
 	return exists, nil
 }
 
+// DbLockTable locks the row of table id until the end of the current
+// transaction and returns 1 if the table exists, 0 otherwise.
+func (p PartyModel) DbLockTable(id int64) (int64, error) {
+	var lockedId int64
+	res := p.conn().QueryRow("SELECT id FROM tables WHERE id = ? AND event_id = ?"+p.forUpdate(), id, p.event())
+	err := res.Scan(&lockedId)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 0, err
+	}
+	return 1, nil
+}
+
+// DbLockEvent locks the row of the event until the end of the current
+// transaction. Transactions lock the event before any of its tables, so
+// transactions locking several tables or adding rows of the event cannot
+// deadlock each other.
+func (p PartyModel) DbLockEvent() error {
+	var lockedId int64
+	res := p.conn().QueryRow("SELECT id FROM events WHERE id = ?"+p.forUpdate(), p.event())
+	if err := res.Scan(&lockedId); err != nil {
+		fmt.Println(err)
+		return err
+	}
+	return nil
+}
+
+// DbCheckGuestExists returns the number of guests with the name key of name
 func (p PartyModel) DbCheckGuestExists(name string) (int64, error) {
 	var exists int64
-	res := p.DB.QueryRow("SELECT EXISTS(SELECT * FROM guests WHERE name = ?)", name)
+	res := p.conn().QueryRow("SELECT COUNT(*) FROM guests WHERE name_key = ? AND event_id = ?", NameKey(name), p.event())
 	err := res.Scan(&exists)
 	if err != nil && err != sql.ErrNoRows {
 		fmt.Println(err)
@@ -225,30 +929,552 @@ func (p PartyModel) DbCheckGuestExists(name string) (int64, error) {
 	return exists, nil
 }
 
-func (p PartyModel) DbIsTablesEmpty() (bool, error) {
-	var count int64
-	res := p.DB.QueryRow("SELECT COUNT(*) from tables")
-	err := res.Scan(&count)
+// DbFindGuests returns the guests with the name key of name by id
+func (p PartyModel) DbFindGuests(name string) ([]Guests, error) {
+	var guests []Guests
+	res, err := p.conn().Query(`SELECT guest_id, id, name, accompanying_guests, status
+				   FROM guests
+				   WHERE name_key = ? AND event_id = ?
+				   ORDER BY guest_id`, NameKey(name), p.event())
+	if err != nil {
+		fmt.Println(err)
+		return guests, err
+	}
+	defer res.Close()
+	for res.Next() {
+		var g Guests
+		if err := res.Scan(&g.Id, &g.Table, &g.Name, &g.AccompanyingGuests, &g.Status); err != nil {
+			fmt.Println(err)
+			return guests, err
+		}
+		guests = append(guests, g)
+	}
+	return guests, nil
+}
+
+func (p PartyModel) DbGetTables() ([]Table, error) {
+	var tables []Table
+	res, err := p.conn().Query("SELECT id, capacity FROM tables WHERE event_id = ? ORDER BY id", p.event())
+	if err != nil {
+		fmt.Println(err)
+		return tables, err
+	}
+	defer res.Close()
+	for res.Next() {
+		var t Table
+		if err := res.Scan(&t.Id, &t.Capacity); err != nil {
+			fmt.Println(err)
+			return tables, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// DbGetTableSeats returns the seats taken on every table of the event in a
+// single query
+func (p PartyModel) DbGetTableSeats() ([]TableSeats, error) {
+	var tables []TableSeats
+	res, err := p.conn().Query(`SELECT t.id, t.capacity,
+				   COUNT(CASE WHEN g.status = ? THEN 1 END),
+				   COALESCE(SUM(CASE WHEN g.status = ? THEN g.accompanying_guests + 1 END), 0),
+				   COUNT(CASE WHEN g.status = ? THEN 1 END),
+				   COALESCE(SUM(CASE WHEN g.status = ? THEN g.accompanying_guests + 1 END), 0),
+				   COUNT(CASE WHEN g.status = ? THEN 1 END),
+				   COALESCE(SUM(CASE WHEN g.status = ? THEN g.accompanying_guests + 1 END), 0),
+				   COUNT(CASE WHEN g.status = ? THEN 1 END),
+				   COALESCE(SUM(CASE WHEN g.status = ? THEN g.accompanying_guests + 1 END), 0),
+				   COUNT(CASE WHEN g.status = ? THEN 1 END),
+				   COALESCE(SUM(CASE WHEN g.status = ? THEN g.accompanying_guests + 1 END), 0)
+				   FROM tables t
+				   LEFT JOIN guests g ON g.id = t.id AND g.event_id = t.event_id
+				   WHERE t.event_id = ?
+				   GROUP BY t.id, t.capacity
+				   ORDER BY t.id`,
+		ALLOTTED, ALLOTTED, CHECKEDIN, CHECKEDIN, CHECKEDOUT, CHECKEDOUT,
+		NOSHOW, NOSHOW, CANCELLED, CANCELLED, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return tables, err
+	}
+	defer res.Close()
+	for res.Next() {
+		var t TableSeats
+		err := res.Scan(&t.Id, &t.Capacity,
+			&t.AllottedParties, &t.AllottedSeats,
+			&t.CheckedInParties, &t.CheckedInSeats,
+			&t.CheckedOutParties, &t.CheckedOutSeats,
+			&t.NoShowParties, &t.NoShowSeats,
+			&t.CancelledParties, &t.CancelledSeats)
+		if err != nil {
+			fmt.Println(err)
+			return tables, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+func (p PartyModel) DbGetGuestsInTable(id int64) ([]Guests, error) {
+	var guests []Guests
+	res, err := p.conn().Query(`SELECT guest_id, id, name, accompanying_guests, status
+				   FROM guests
+				   WHERE id = ? AND event_id = ?
+				   ORDER BY name_key, guest_id`, id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return guests, err
+	}
+	defer res.Close()
+	for res.Next() {
+		var g Guests
+		if err := res.Scan(&g.Id, &g.Table, &g.Name, &g.AccompanyingGuests, &g.Status); err != nil {
+			fmt.Println(err)
+			return guests, err
+		}
+		guests = append(guests, g)
+	}
+	return guests, nil
+}
+
+func (p PartyModel) DbUpdateTableCapacity(id int64, capacity int64) error {
+	_, err := p.conn().Exec(
+		`UPDATE tables SET capacity = ? WHERE id = ? AND event_id = ?`,
+		capacity, id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	return nil
+}
+
+// DbDeleteTable deletes table id together with its tags, parties waiting
+// for the table take any table
+func (p PartyModel) DbDeleteTable(id int64) error {
+	_, err := p.conn().Exec(`DELETE FROM constraints WHERE table_id = ? AND event_id = ?`, id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	_, err = p.conn().Exec(`UPDATE waitlist SET table_id = NULL WHERE table_id = ? AND event_id = ?`, id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	_, err = p.conn().Exec(`DELETE FROM tables WHERE id = ? AND event_id = ?`, id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (p PartyModel) DbGetGuest(id int64) (Guests, error) {
+	var guest Guests
+	var timeArrived, timeLeft sql.NullTime
+	res := p.conn().QueryRow(`SELECT guest_id, id, name, accompanying_guests, status, time_arrived, time_left
+				   FROM guests
+				   WHERE guest_id = ? AND event_id = ?`, id, p.event())
+	err := res.Scan(&guest.Id, &guest.Table, &guest.Name, &guest.AccompanyingGuests, &guest.Status, &timeArrived, &timeLeft)
+	if err != nil {
+		fmt.Println(err)
+		return guest, err
+	}
+	guest.TimeArrived = timeArrived.Time
+	guest.TimeLeft = timeLeft.Time
+	return guest, nil
+}
+
+// DbUpdateGuestAllotment changes the table and accompanying guests of guest
+func (p PartyModel) DbUpdateGuestAllotment(guest Guests) error {
+	_, err := p.conn().Exec(
+		`UPDATE guests SET
+		id = ?,
+		accompanying_guests = ?
+		WHERE guest_id = ? AND event_id = ?`,
+		guest.Table, guest.AccompanyingGuests, guest.Id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (p PartyModel) DbDeleteGuest(id int64) error {
+	_, err := p.conn().Exec(`DELETE FROM guest_trigrams WHERE guest_id = ? AND event_id = ?`, id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	_, err = p.conn().Exec(`DELETE FROM companions WHERE guest_id = ? AND event_id = ?`, id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	_, err = p.conn().Exec(`DELETE FROM waitlist WHERE guest_id = ? AND event_id = ?`, id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	_, err = p.conn().Exec(`DELETE FROM guests WHERE guest_id = ? AND event_id = ?`, id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	return nil
+}
+
+// DbGetCompanions returns the companions of the guests ids, of every guest
+// when no id is given, in the order of the guests and of the companions of a
+// guest
+func (p PartyModel) DbGetCompanions(ids ...int64) ([]Companion, error) {
+	var companions []Companion
+	query := `SELECT guest_id, name, dietary, accessibility, arrived
+		FROM companions
+		WHERE event_id = ?`
+	args := []interface{}{p.event()}
+	if len(ids) > 0 {
+		query += ` AND guest_id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	res, err := p.conn().Query(query+` ORDER BY guest_id, companion_id`, args...)
+	if err != nil {
+		fmt.Println(err)
+		return companions, err
+	}
+	defer res.Close()
+	for res.Next() {
+		var c Companion
+		if err := res.Scan(&c.GuestId, &c.Name, &c.Dietary, &c.Accessibility, &c.Arrived); err != nil {
+			fmt.Println(err)
+			return companions, err
+		}
+		companions = append(companions, c)
+	}
+	return companions, nil
+}
+
+// DbSetCompanions replaces the companions of guest id with companions
+func (p PartyModel) DbSetCompanions(id int64, companions []Companion) error {
+	_, err := p.conn().Exec(`DELETE FROM companions WHERE guest_id = ? AND event_id = ?`, id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	if len(companions) == 0 {
+		return nil
+	}
+	var args []interface{}
+	for _, c := range companions {
+		args = append(args, p.event(), id, c.Name, c.Dietary, c.Accessibility, c.Arrived)
+	}
+	_, err = p.conn().Exec(
+		`INSERT INTO companions(event_id, guest_id, name, dietary, accessibility, arrived) VALUES (?, ?, ?, ?, ?, ?)`+
+			strings.Repeat(", (?, ?, ?, ?, ?, ?)", len(companions)-1),
+		args...)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	return nil
+}
+
+// DbAddVisit records the arrival of guest id with the accompanying guests
+// and arrival time of the guest
+func (p PartyModel) DbAddVisit(id int64) error {
+	_, err := p.conn().Exec(
+		`INSERT INTO visits(event_id, guest_id, name, accompanying_guests, time_arrived)
+		SELECT event_id, guest_id, name, accompanying_guests, time_arrived
+		FROM guests
+		WHERE guest_id = ? AND event_id = ?`,
+		id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	return nil
+}
+
+// DbEndVisit records the departure time of guest id as the end of the
+// current visit
+func (p PartyModel) DbEndVisit(id int64) error {
+	_, err := p.conn().Exec(
+		`UPDATE visits SET time_left = (
+			SELECT time_left FROM guests WHERE guests.guest_id = visits.guest_id)
+		WHERE guest_id = ? AND event_id = ? AND time_left IS NULL`,
+		id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	return nil
+}
+
+// DbGetVisits returns the visits of the guests ids, of every guest when no
+// id is given, in the order they arrived
+func (p PartyModel) DbGetVisits(ids ...int64) ([]Visit, error) {
+	var visits []Visit
+	query := `SELECT guest_id, name, accompanying_guests, time_arrived, time_left
+		FROM visits
+		WHERE event_id = ?`
+	args := []interface{}{p.event()}
+	if len(ids) > 0 {
+		query += ` AND guest_id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	res, err := p.conn().Query(query+` ORDER BY id`, args...)
+	if err != nil {
+		fmt.Println(err)
+		return visits, err
+	}
+	defer res.Close()
+	for res.Next() {
+		var v Visit
+		var timeLeft sql.NullTime
+		if err := res.Scan(&v.GuestId, &v.Name, &v.AccompanyingGuests, &v.TimeArrived, &timeLeft); err != nil {
+			fmt.Println(err)
+			return visits, err
+		}
+		v.TimeLeft = timeLeft.Time
+		visits = append(visits, v)
+	}
+	return visits, nil
+}
+
+// DbAddTableMove records move at the current time
+func (p PartyModel) DbAddTableMove(move TableMove) error {
+	_, err := p.conn().Exec(
+		`INSERT INTO table_moves(event_id, guest_id, name, from_table, to_table, accompanying_guests, time_moved)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		p.event(), move.GuestId, move.Name, move.From, move.To, move.AccompanyingGuests, time.Now())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	return nil
+}
+
+// DbGetTableMoves returns the table moves of the event in the order they
+// were made
+func (p PartyModel) DbGetTableMoves() ([]TableMove, error) {
+	var moves []TableMove
+	res, err := p.conn().Query(`SELECT guest_id, name, from_table, to_table, accompanying_guests, time_moved
+				   FROM table_moves
+				   WHERE event_id = ?
+				   ORDER BY move_id`, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return moves, err
+	}
+	defer res.Close()
+	for res.Next() {
+		var m TableMove
+		if err := res.Scan(&m.GuestId, &m.Name, &m.From, &m.To, &m.AccompanyingGuests, &m.TimeMoved); err != nil {
+			fmt.Println(err)
+			return moves, err
+		}
+		moves = append(moves, m)
+	}
+	return moves, nil
+}
+
+func (p PartyModel) DbAddConstraint(c Constraint) (int64, error) {
+	var id int64
+	table := sql.NullInt64{Int64: c.Table, Valid: c.Table != 0}
+	args := []interface{}{p.event(), c.Kind, c.Name, NameKey(c.Name), c.Other, NameKey(c.Other), table, c.Tag}
+	query := `INSERT INTO constraints(event_id, kind, name, name_key, other_name, other_key, table_id, tag)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	if p.Dialect == db.Postgres {
+		err := p.conn().QueryRow(query+` RETURNING constraint_id`, args...).Scan(&id)
+		if err != nil {
+			fmt.Println(err)
+		}
+		return id, err
+	}
+	res, err := p.conn().Exec(query, args...)
+	if err != nil {
+		fmt.Println(err)
+		return id, err
+	}
+	return res.LastInsertId()
+}
+
+// DbGetConstraints returns the constraints of the event in the order they
+// were added
+func (p PartyModel) DbGetConstraints() ([]Constraint, error) {
+	var constraints []Constraint
+	res, err := p.conn().Query(`SELECT constraint_id, kind, name, other_name, table_id, tag
+				   FROM constraints
+				   WHERE event_id = ?
+				   ORDER BY constraint_id`, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return constraints, err
+	}
+	defer res.Close()
+	for res.Next() {
+		var c Constraint
+		var table sql.NullInt64
+		if err := res.Scan(&c.Id, &c.Kind, &c.Name, &c.Other, &table, &c.Tag); err != nil {
+			fmt.Println(err)
+			return constraints, err
+		}
+		c.Table = table.Int64
+		constraints = append(constraints, c)
+	}
+	return constraints, nil
+}
+
+// DbDeleteConstraint deletes constraint id and returns the number of
+// constraints deleted
+func (p PartyModel) DbDeleteConstraint(id int64) (int64, error) {
+	res, err := p.conn().Exec(`DELETE FROM constraints WHERE constraint_id = ? AND event_id = ?`, id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (p PartyModel) DbAddWaitlistEntry(entry WaitlistEntry) (int64, error) {
+	var id int64
+	table := sql.NullInt64{Int64: entry.Table, Valid: entry.Table != 0}
+	args := []interface{}{p.event(), entry.Name, entry.AccompanyingGuests, entry.Priority, table}
+	query := `INSERT INTO waitlist(event_id, name, accompanying_guests, priority, table_id)
+		VALUES (?, ?, ?, ?, ?)`
+	if p.Dialect == db.Postgres {
+		err := p.conn().QueryRow(query+` RETURNING waitlist_id`, args...).Scan(&id)
+		if err != nil {
+			fmt.Println(err)
+		}
+		return id, err
+	}
+	res, err := p.conn().Exec(query, args...)
+	if err != nil {
+		fmt.Println(err)
+		return id, err
+	}
+	return res.LastInsertId()
+}
+
+// DbGetWaitlist returns the waiting and allotted parties of the event in the
+// order they were queued
+func (p PartyModel) DbGetWaitlist() ([]WaitlistEntry, error) {
+	var entries []WaitlistEntry
+	res, err := p.conn().Query(`SELECT waitlist_id, name, accompanying_guests, priority, table_id, guest_id
+				   FROM waitlist
+				   WHERE event_id = ?
+				   ORDER BY waitlist_id`, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return entries, err
+	}
+	defer res.Close()
+	for res.Next() {
+		var e WaitlistEntry
+		var table, guest sql.NullInt64
+		if err := res.Scan(&e.Id, &e.Name, &e.AccompanyingGuests, &e.Priority, &table, &guest); err != nil {
+			fmt.Println(err)
+			return entries, err
+		}
+		e.Table = table.Int64
+		e.GuestId = guest.Int64
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// DbAllotWaitlistEntry records guest guestId as the guest of the waiting
+// party id and returns the number of parties updated, 0 when the party is
+// not waiting any more
+func (p PartyModel) DbAllotWaitlistEntry(id int64, guestId int64) (int64, error) {
+	res, err := p.conn().Exec(`UPDATE waitlist SET guest_id = ? WHERE waitlist_id = ? AND event_id = ? AND guest_id IS NULL`,
+		guestId, id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DbDeleteWaitlistEntry deletes the party id from the waitlist and returns
+// the number of parties deleted
+func (p PartyModel) DbDeleteWaitlistEntry(id int64) (int64, error) {
+	res, err := p.conn().Exec(`DELETE FROM waitlist WHERE waitlist_id = ? AND event_id = ?`, id, p.event())
+	if err != nil {
+		fmt.Println(err)
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (p PartyModel) DbAddEvent(event Event) (int64, error) {
+	var resId int64
+	date := sql.NullTime{Time: event.Date, Valid: !event.Date.IsZero()}
+	if p.Dialect == db.Postgres {
+		err := p.conn().QueryRow(
+			`INSERT INTO events(name, event_date, venue) VALUES (?, ?, ?) RETURNING id`,
+			event.Name, date, event.Venue).Scan(&resId)
+		if err != nil {
+			fmt.Println(err)
+		}
+		return resId, err
+	}
+	res, err := p.conn().Exec(
+		`INSERT INTO events(name, event_date, venue) VALUES (?, ?, ?)`,
+		event.Name, date, event.Venue)
+	if err != nil {
+		fmt.Println(err)
+		return resId, err
+	}
+	return res.LastInsertId()
+}
+
+func (p PartyModel) DbGetEvents() ([]Event, error) {
+	var events []Event
+	res, err := p.conn().Query("SELECT id, name, event_date, venue FROM events ORDER BY id")
 	if err != nil {
 		fmt.Println(err)
-		return true, err
+		return events, err
 	}
-	if count > 0 {
-		return false, nil
+	defer res.Close()
+	for res.Next() {
+		var e Event
+		var date sql.NullTime
+		if err := res.Scan(&e.Id, &e.Name, &date, &e.Venue); err != nil {
+			fmt.Println(err)
+			return events, err
+		}
+		e.Date = date.Time
+		events = append(events, e)
 	}
-	return true, nil
+	return events, nil
 }
 
-func (p PartyModel) DbIsGuestsEmpty(status string) (bool, error) {
-	var count int64
-	res := p.DB.QueryRow("SELECT COUNT(*) from guests WHERE status = ?", status)
-	err := res.Scan(&count)
+func (p PartyModel) DbGetEvent(id int64) (Event, error) {
+	var event Event
+	var date sql.NullTime
+	res := p.conn().QueryRow("SELECT id, name, event_date, venue FROM events WHERE id = ?", id)
+	err := res.Scan(&event.Id, &event.Name, &date, &event.Venue)
 	if err != nil {
 		fmt.Println(err)
-		return true, err
+		return event, err
 	}
-	if count > 0 {
-		return false, nil
+	event.Date = date.Time
+	return event, nil
+}
+
+func (p PartyModel) DbCheckEventExists(id int64) (int64, error) {
+	var exists int64
+	res := p.conn().QueryRow("SELECT COUNT(*) FROM events WHERE id = ?", id)
+	err := res.Scan(&exists)
+	if err != nil && err != sql.ErrNoRows {
+		fmt.Println(err)
+		return exists, err
 	}
-	return true, nil
+	return exists, nil
 }
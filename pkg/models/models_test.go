@@ -0,0 +1,656 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	db "github.com/getground/tech-tasks/backend/pkg/db"
+	migrate "github.com/getground/tech-tasks/backend/pkg/migrate"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSQLiteModel(t *testing.T) PartyModel {
+	sqlDB, err := sql.Open(db.SQLite, db.SQLiteDSN(filepath.Join(t.TempDir(), "party.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	migrator, err := migrate.New(sqlDB, db.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return PartyModel{DB: sqlDB, Dialect: db.SQLite}
+}
+
+// adds guest to p and returns the id of the guest
+func addGuest(t *testing.T, p Party, guest Guests) int64 {
+	id, err := p.DbAddGuestList(guest)
+	assert.Nil(t, err)
+	return id
+}
+
+func TestRebind(t *testing.T) {
+	assert.Equal(t, "UPDATE guests SET status = $1 WHERE name = $2",
+		rebind("UPDATE guests SET status = ? WHERE name = ?"))
+}
+
+func TestSQLiteGuestList(t *testing.T) {
+	p := newSQLiteModel(t)
+
+	seats, err := p.DbGetTableSeats()
+	assert.Nil(t, err)
+	assert.Nil(t, seats)
+
+	id, err := p.DbAddTable(4)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), id)
+
+	john := addGuest(t, p, Guests{Table: 1, AccompanyingGuests: 2, Name: "john"})
+	_, err = p.DbAddGuestList(Guests{Table: 2, Name: "jack"})
+	assert.NotNil(t, err, "unknown table")
+
+	exists, err := p.DbCheckGuestExists("john")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), exists)
+
+	name, err := p.DbGetGuestInTable(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "john", name)
+
+	table, err := p.DbGetTableIdOfGuest(john)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), table)
+
+	assert.Nil(t, p.DbUpdateGuestList(Guests{Id: john, Name: "john", AccompanyingGuests: 3, Status: CHECKEDIN}))
+	arrived, err := p.DbQueryGuests(GuestQuery{Statuses: []string{CHECKEDIN, CHECKEDOUT}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(arrived))
+	assert.Equal(t, int64(3), arrived[0].AccompanyingGuests)
+	assert.False(t, arrived[0].TimeArrived.IsZero())
+
+	seats, err = p.DbGetTableSeats()
+	assert.Nil(t, err)
+	assert.Equal(t, []TableSeats{{Table: Table{Id: 1, Capacity: 4}, CheckedInParties: 1, CheckedInSeats: 4}}, seats)
+}
+
+func TestSQLiteTableSeats(t *testing.T) {
+	p := newSQLiteModel(t)
+	for _, capacity := range []int64{10, 4, 6} {
+		_, err := p.DbAddTable(capacity)
+		assert.Nil(t, err)
+	}
+	addGuest(t, p, Guests{Table: 1, AccompanyingGuests: 2, Name: "john"})
+	jack := addGuest(t, p, Guests{Table: 1, AccompanyingGuests: 1, Name: "jack"})
+	jill := addGuest(t, p, Guests{Table: 1, Name: "jill"})
+	addGuest(t, p, Guests{Table: 2, AccompanyingGuests: 3, Name: "akhila"})
+	assert.Nil(t, p.DbUpdateGuestStatus(jack, CHECKEDIN))
+	assert.Nil(t, p.DbUpdateGuestStatus(jill, CHECKEDIN))
+	assert.Nil(t, p.DbUpdateGuestStatus(jill, CHECKEDOUT))
+
+	seats, err := p.DbGetTableSeats()
+	assert.Nil(t, err)
+	assert.Equal(t, []TableSeats{
+		{Table: Table{Id: 1, Capacity: 10}, AllottedParties: 1, AllottedSeats: 3, CheckedInParties: 1, CheckedInSeats: 2, CheckedOutParties: 1, CheckedOutSeats: 1},
+		{Table: Table{Id: 2, Capacity: 4}, AllottedParties: 1, AllottedSeats: 4},
+		{Table: Table{Id: 3, Capacity: 6}},
+	}, seats)
+}
+
+func TestSQLiteTransaction(t *testing.T) {
+	p := newSQLiteModel(t)
+	_, err := p.DbAddTable(4)
+	assert.Nil(t, err)
+
+	txErr := fmt.Errorf("abort")
+	err = p.DbTransaction(func(tx Party) error {
+		if err := tx.DbLockEvent(); err != nil {
+			return err
+		}
+		locked, err := tx.DbLockTable(1)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, int64(1), locked)
+		if _, err = tx.DbAddGuestList(Guests{Table: 1, Name: "john"}); err != nil {
+			return err
+		}
+		return txErr
+	})
+	assert.Equal(t, txErr, err)
+
+	guests, err := p.DbGetGuestList()
+	assert.Nil(t, err)
+	assert.Nil(t, guests)
+
+	err = p.DbTransaction(func(tx Party) error {
+		_, err := tx.DbAddGuestList(Guests{Table: 1, Name: "john"})
+		return err
+	})
+	assert.Nil(t, err)
+	guests, err = p.DbGetGuestList()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(guests))
+}
+
+func TestSQLiteTables(t *testing.T) {
+	p := newSQLiteModel(t)
+	for _, capacity := range []int64{4, 6} {
+		_, err := p.DbAddTable(capacity)
+		assert.Nil(t, err)
+	}
+	john := addGuest(t, p, Guests{Table: 1, AccompanyingGuests: 2, Name: "john"})
+
+	assert.Nil(t, p.DbUpdateTableCapacity(1, 3))
+	tables, err := p.DbGetTables()
+	assert.Nil(t, err)
+	assert.Equal(t, []Table{{Id: 1, Capacity: 3}, {Id: 2, Capacity: 6}}, tables)
+
+	guests, err := p.DbGetGuestsInTable(1)
+	assert.Nil(t, err)
+	assert.Equal(t, []Guests{{Id: john, Table: 1, AccompanyingGuests: 2, Status: ALLOTTED, Name: "john"}}, guests)
+
+	assert.NotNil(t, p.DbDeleteTable(1), "table is referenced by a guest")
+	assert.Nil(t, p.DbDeleteTable(2))
+	tables, err = p.DbGetTables()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(tables))
+}
+
+func TestSQLiteEditGuest(t *testing.T) {
+	p := newSQLiteModel(t)
+	for _, capacity := range []int64{4, 6} {
+		_, err := p.DbAddTable(capacity)
+		assert.Nil(t, err)
+	}
+	john := addGuest(t, p, Guests{Table: 1, AccompanyingGuests: 2, Name: "john"})
+
+	guest, err := p.DbGetGuest(john)
+	assert.Nil(t, err)
+	assert.Equal(t, Guests{Id: john, Table: 1, AccompanyingGuests: 2, Status: ALLOTTED, Name: "john"}, guest)
+
+	guest.Table = 2
+	guest.AccompanyingGuests = 5
+	assert.Nil(t, p.DbUpdateGuestAllotment(guest))
+	guest, err = p.DbGetGuest(john)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), guest.Table)
+	assert.Equal(t, int64(5), guest.AccompanyingGuests)
+
+	assert.Nil(t, p.DbDeleteGuest(john))
+	_, err = p.DbGetGuest(john)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestSQLiteEvents(t *testing.T) {
+	p := newSQLiteModel(t)
+	events, err := p.DbGetEvents()
+	assert.Nil(t, err)
+	assert.Equal(t, []Event{{Id: DefaultEvent, Name: "Default"}}, events)
+
+	date := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	id, err := p.DbAddEvent(Event{Name: "wedding", Date: date, Venue: "garden"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), id)
+	event, err := p.DbGetEvent(id)
+	assert.Nil(t, err)
+	assert.Equal(t, "wedding", event.Name)
+	assert.True(t, date.Equal(event.Date))
+	assert.Equal(t, "garden", event.Venue)
+
+	exists, err := p.DbCheckEventExists(3)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), exists)
+
+	// same guest name in both events, tables are not shared
+	wedding := p.DbForEvent(id)
+	_, err = p.DbAddTable(4)
+	assert.Nil(t, err)
+	tableId, err := wedding.DbAddTable(6)
+	assert.Nil(t, err)
+	addGuest(t, p, Guests{Table: 1, Name: "john"})
+	john := addGuest(t, wedding, Guests{Table: tableId, AccompanyingGuests: 2, Name: "john"})
+
+	locked, err := wedding.DbLockTable(1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), locked, "table of another event")
+
+	seats, err := wedding.DbGetTableSeats()
+	assert.Nil(t, err)
+	assert.Equal(t, []TableSeats{{Table: Table{Id: tableId, Capacity: 6}, AllottedParties: 1, AllottedSeats: 3}}, seats)
+
+	guest, err := wedding.DbGetGuest(john)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), guest.AccompanyingGuests)
+	_, err = p.DbGetGuest(john)
+	assert.Equal(t, sql.ErrNoRows, err, "guest of another event")
+
+	assert.Nil(t, p.DbDeleteGuest(john), "guest of another event is not deleted")
+	assert.Nil(t, wedding.DbDeleteGuest(john))
+	exists, err = p.DbCheckGuestExists("john")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), exists)
+}
+
+func TestSQLiteVisits(t *testing.T) {
+	p := newSQLiteModel(t)
+	_, err := p.DbAddTable(4)
+	assert.Nil(t, err)
+	john := addGuest(t, p, Guests{Table: 1, AccompanyingGuests: 2, Name: "john"})
+
+	assert.Nil(t, p.DbUpdateGuestList(Guests{Id: john, AccompanyingGuests: 1, Status: CHECKEDIN}))
+	assert.Nil(t, p.DbAddVisit(john))
+	assert.Nil(t, p.DbUpdateGuestStatus(john, CHECKEDOUT))
+	assert.Nil(t, p.DbEndVisit(john))
+
+	guests, err := p.DbQueryGuests(GuestQuery{Statuses: []string{CHECKEDIN, CHECKEDOUT}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(guests))
+	assert.False(t, guests[0].TimeLeft.IsZero())
+	timeLeft := guests[0].TimeLeft
+	guest, err := p.DbGetGuest(john)
+	assert.Nil(t, err)
+	assert.True(t, timeLeft.Equal(guest.TimeLeft))
+
+	assert.Nil(t, p.DbUpdateGuestList(Guests{Id: john, AccompanyingGuests: 0, Status: CHECKEDIN}))
+	assert.Nil(t, p.DbAddVisit(john))
+
+	guests, err = p.DbQueryGuests(GuestQuery{Statuses: []string{CHECKEDIN, CHECKEDOUT}})
+	assert.Nil(t, err)
+	assert.True(t, guests[0].TimeLeft.IsZero(), "time_left is cleared on check-in")
+
+	visits, err := p.DbGetVisits()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(visits))
+	assert.Equal(t, john, visits[0].GuestId)
+	assert.Equal(t, int64(1), visits[0].AccompanyingGuests)
+	assert.True(t, timeLeft.Equal(visits[0].TimeLeft), "the visit ends when the guest left")
+	assert.Equal(t, int64(0), visits[1].AccompanyingGuests)
+	assert.True(t, visits[1].TimeLeft.IsZero())
+
+	assert.NotNil(t, p.DbDeleteGuest(john), "guest is referenced by visits")
+}
+
+func TestSQLiteStatusTransitions(t *testing.T) {
+	p := newSQLiteModel(t)
+	_, err := p.DbAddTable(4)
+	assert.Nil(t, err)
+	john := addGuest(t, p, Guests{Table: 1, Name: "john"})
+
+	assert.Equal(t, &TransitionError{From: ALLOTTED, To: CHECKEDOUT}, p.DbUpdateGuestStatus(john, CHECKEDOUT))
+	assert.Nil(t, p.DbUpdateGuestStatus(john, CANCELLED))
+	assert.Equal(t, &TransitionError{From: CANCELLED, To: CHECKEDIN},
+		p.DbUpdateGuestList(Guests{Id: john, Status: CHECKEDIN}))
+	assert.Equal(t, &TransitionError{From: CANCELLED, To: ALLOTTED}, p.DbUpdateGuestStatus(john, ALLOTTED))
+
+	status, err := p.DbGetGuestStatus(john)
+	assert.Nil(t, err)
+	assert.Equal(t, CANCELLED, status)
+
+	assert.Equal(t, sql.ErrNoRows, p.DbUpdateGuestStatus(john+1, CHECKEDIN), "unknown guests are not found")
+	assert.Equal(t, sql.ErrNoRows, p.DbUpdateGuestList(Guests{Id: john + 1, Status: CHECKEDIN}))
+}
+
+func TestStateMachine(t *testing.T) {
+	assert.Nil(t, GuestStatus.Check(ALLOTTED, CHECKEDIN))
+	assert.Nil(t, GuestStatus.Check(CHECKEDOUT, CHECKEDIN))
+	assert.Equal(t, &TransitionError{From: CHECKEDIN, To: CHECKEDIN}, GuestStatus.Check(CHECKEDIN, CHECKEDIN))
+	assert.Equal(t, []string{ALLOTTED, CHECKEDOUT, NOSHOW}, GuestStatus.Sources(CHECKEDIN))
+	assert.Nil(t, GuestStatus.Sources(ALLOTTED))
+}
+
+func TestSQLiteQueryGuests(t *testing.T) {
+	p := newSQLiteModel(t)
+	for _, capacity := range []int64{4, 4, 4} {
+		_, err := p.DbAddTable(capacity)
+		assert.Nil(t, err)
+	}
+	ids := map[string]int64{}
+	for _, g := range []Guests{
+		{Table: 2, Name: "john"},
+		{Table: 1, Name: "jack"},
+		{Table: 3, Name: "jo_e"},
+		{Table: 1, Name: "joxe"},
+		{Table: 2, Name: "akhila"},
+	} {
+		ids[g.Name] = addGuest(t, p, g)
+	}
+	before := time.Now().Add(-time.Minute)
+	assert.Nil(t, p.DbUpdateGuestList(Guests{Id: ids["jack"], Status: CHECKEDIN}))
+	assert.Nil(t, p.DbUpdateGuestList(Guests{Id: ids["akhila"], Status: CHECKEDIN}))
+
+	names := func(q GuestQuery) []string {
+		guests, err := p.DbQueryGuests(q)
+		assert.Nil(t, err)
+		var names []string
+		for _, g := range guests {
+			names = append(names, g.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"akhila", "jack", "jo_e", "john", "joxe"}, names(GuestQuery{}))
+	assert.Equal(t, []string{"joxe", "john", "jo_e", "jack", "akhila"}, names(GuestQuery{Desc: true}))
+	assert.Equal(t, []string{"jack", "joxe", "akhila", "john", "jo_e"}, names(GuestQuery{Sort: SortByTable}))
+	assert.Equal(t, []string{"jo_e"}, names(GuestQuery{NamePrefix: "jo_"}), "_ is not a wildcard")
+	assert.Equal(t, []string{"akhila", "jack"}, names(GuestQuery{Statuses: []string{CHECKEDIN}}))
+	assert.Equal(t, []string{"akhila", "john"}, names(GuestQuery{Table: 2}))
+	assert.Equal(t, []string{"akhila", "jack"}, names(GuestQuery{ArrivedAfter: before}))
+	assert.Nil(t, names(GuestQuery{ArrivedBefore: before}))
+
+	// keyset pages in table order
+	q := GuestQuery{Sort: SortByTable, Limit: 2}
+	assert.Equal(t, []string{"jack", "joxe"}, names(q))
+	q.After = &Guests{Id: ids["joxe"], Table: 1, Name: "joxe"}
+	assert.Equal(t, []string{"akhila", "john"}, names(q))
+	q.After = &Guests{Id: ids["john"], Table: 2, Name: "john"}
+	assert.Equal(t, []string{"jo_e"}, names(q))
+	q = GuestQuery{Sort: SortByTable, Desc: true, After: &Guests{Id: ids["john"], Table: 2, Name: "john"}}
+	assert.Equal(t, []string{"akhila", "joxe", "jack"}, names(q))
+	assert.Equal(t, []string{"john", "joxe"}, names(GuestQuery{After: &Guests{Id: ids["jo_e"], Name: "jo_e"}}))
+
+	// guests sharing a name are paged by id
+	second := addGuest(t, p, Guests{Table: 3, Name: "John"})
+	assert.Equal(t, []string{"john", "John"}, names(GuestQuery{NamePrefix: "john"}))
+	q = GuestQuery{NamePrefix: "john", After: &Guests{Id: ids["john"], Name: "john"}}
+	assert.Equal(t, []string{"John"}, names(q))
+	q.After.Id = second
+	assert.Nil(t, names(q))
+}
+
+func TestSearchKey(t *testing.T) {
+	assert.Equal(t, "zoe muller", SearchKey("  Zoë  MÜLLER "))
+	assert.Equal(t, "jose", SearchKey("José"), "decomposed accent")
+	assert.Equal(t, []string{"  j", " jo", "jo ", "  a", " al", "al "}, Trigrams("jo al"))
+
+	assert.Equal(t, 1.0, SearchScore("jo", "john"))
+	assert.Equal(t, 0.9, SearchScore("smi", "john smith"))
+	assert.InDelta(t, 0.6, SearchScore("jhon", "john"), 0.001, "transposition is one edit")
+	assert.InDelta(t, 0.6, SearchScore("jon", "john"), 0.001)
+	assert.Less(t, SearchScore("jack", "john"), 0.4)
+}
+
+func TestSQLiteSearchGuests(t *testing.T) {
+	p := newSQLiteModel(t)
+	_, err := p.DbAddTable(4)
+	assert.Nil(t, err)
+	ids := map[string]int64{}
+	for _, name := range []string{"zoë", "zoltan", "john", "joan", "jo%e"} {
+		ids[name] = addGuest(t, p, Guests{Table: 1, Name: name})
+	}
+
+	names := func(query string, limit int) []string {
+		guests, err := p.DbSearchGuests(query, limit)
+		assert.Nil(t, err)
+		var names []string
+		for _, g := range guests {
+			names = append(names, g.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"zoë", "zoltan"}, names("zo", 10)[:2], "prefixes first by search key")
+	assert.Equal(t, []string{"jo%e"}, names("jo%", 10)[:1], "% is not a wildcard")
+	assert.Equal(t, []string{"zoë"}, names("zoe", 1))
+	assert.Contains(t, names("jhon", 10), "john")
+
+	// deleted guests leave the index
+	assert.Nil(t, p.DbDeleteGuest(ids["john"]))
+	assert.NotContains(t, names("jhon", 10), "john")
+
+	// guests added before the index are indexed once
+	_, err = p.DB.Exec(`INSERT INTO guests(id, name, accompanying_guests, event_id) VALUES (1, 'renée', 0, 1)`)
+	assert.Nil(t, err)
+	assert.Nil(t, names("rene", 10))
+	indexed, err := p.DbIndexGuests()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, indexed)
+	assert.Equal(t, []string{"renée"}, names("rene", 10))
+	indexed, err = p.DbIndexGuests()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, indexed)
+}
+
+func TestNameKey(t *testing.T) {
+	assert.Equal(t, "Mary-Jane O'Neil", DisplayName(" Mary-Jane \t O'Neil "))
+	assert.Equal(t, "mary-jane o'neil", NameKey("MARY-JANE  O'NEIL"))
+	assert.Equal(t, NameKey("Zo\u00eb"), NameKey("Zoe\u0308"), "NFC")
+	assert.Equal(t, "strasse", NameKey("STRAẞE"), "case folding")
+}
+
+func TestSQLiteDisplayNames(t *testing.T) {
+	p := newSQLiteModel(t)
+	_, err := p.DbAddTable(4)
+	assert.Nil(t, err)
+	id := addGuest(t, p, Guests{Table: 1, Name: "Mary-Jane O'Neil"})
+
+	guests, err := p.DbFindGuests("MARY-JANE  O'NEIL")
+	assert.Nil(t, err)
+	assert.Equal(t, []Guests{{Id: id, Table: 1, Name: "Mary-Jane O'Neil", Status: ALLOTTED}}, guests)
+
+	assert.Nil(t, p.DbUpdateGuestList(Guests{Id: id, Status: CHECKEDIN}))
+	assert.Nil(t, p.DbAddVisit(id))
+	assert.Nil(t, p.DbUpdateGuestStatus(id, CHECKEDOUT))
+	assert.Nil(t, p.DbEndVisit(id))
+	visits, err := p.DbGetVisits()
+	assert.Nil(t, err)
+	assert.Equal(t, "Mary-Jane O'Neil", visits[0].Name)
+	assert.False(t, visits[0].TimeLeft.IsZero())
+
+	guests, err = p.DbQueryGuests(GuestQuery{NamePrefix: "mary-j"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Mary-Jane O'Neil", guests[0].Name)
+
+	// names stored lower case before the name key are folded again
+	_, err = p.DB.Exec(`INSERT INTO guests(id, name, accompanying_guests, event_id) VALUES (1, 'straße', 0, 1)`)
+	assert.Nil(t, err)
+	_, err = p.DbIndexGuests()
+	assert.Nil(t, err)
+	exists, err := p.DbCheckGuestExists("STRASSE")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), exists)
+}
+
+func TestSQLiteSameNames(t *testing.T) {
+	p := newSQLiteModel(t)
+	_, err := p.DbAddTable(4)
+	assert.Nil(t, err)
+	first := addGuest(t, p, Guests{Table: 1, Name: "John Smith"})
+	second := addGuest(t, p, Guests{Table: 1, AccompanyingGuests: 1, Name: "john smith"})
+	assert.NotEqual(t, first, second)
+
+	guests, err := p.DbFindGuests("john smith")
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{first, second}, []int64{guests[0].Id, guests[1].Id})
+	exists, err := p.DbCheckGuestExists("JOHN SMITH")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), exists)
+
+	// guests sharing a name are checked-in and searched separately
+	assert.Nil(t, p.DbUpdateGuestList(Guests{Id: second, AccompanyingGuests: 1, Status: CHECKEDIN}))
+	assert.Nil(t, p.DbAddVisit(second))
+	status, err := p.DbGetGuestStatus(first)
+	assert.Nil(t, err)
+	assert.Equal(t, ALLOTTED, status)
+	visits, err := p.DbGetVisits()
+	assert.Nil(t, err)
+	assert.Equal(t, second, visits[0].GuestId)
+	visits, err = p.DbGetVisits(second)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(visits))
+	visits, err = p.DbGetVisits(first)
+	assert.Nil(t, err)
+	assert.Nil(t, visits, "only the visits of the guests asked for are returned")
+
+	found, err := p.DbSearchGuests("smith", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(found))
+
+	assert.Nil(t, p.DbDeleteGuest(first))
+	found, err = p.DbSearchGuests("smith", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(found))
+	assert.Equal(t, second, found[0].Id)
+}
+
+func TestSQLiteCompanions(t *testing.T) {
+	p := newSQLiteModel(t)
+	_, err := p.DbAddTable(6)
+	assert.Nil(t, err)
+	john := addGuest(t, p, Guests{Table: 1, AccompanyingGuests: 2, Name: "john"})
+	mary := addGuest(t, p, Guests{Table: 1, AccompanyingGuests: 1, Name: "mary"})
+
+	assert.Nil(t, p.DbSetCompanions(mary, []Companion{{Name: "Tom"}}))
+	assert.Nil(t, p.DbSetCompanions(john, []Companion{
+		{Name: "Anna", Dietary: "vegan"},
+		{Name: "Ben", Accessibility: "wheelchair", Arrived: true},
+	}))
+	companions, err := p.DbGetCompanions(john)
+	assert.Nil(t, err)
+	assert.Equal(t, []Companion{
+		{GuestId: john, Name: "Anna", Dietary: "vegan"},
+		{GuestId: john, Name: "Ben", Accessibility: "wheelchair", Arrived: true},
+	}, companions)
+
+	companions, err = p.DbGetCompanions()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Anna", "Ben", "Tom"}, []string{companions[0].Name, companions[1].Name, companions[2].Name})
+
+	// the companions of a guest are replaced as a whole
+	assert.Nil(t, p.DbSetCompanions(john, []Companion{{Name: "Ben", Arrived: true}}))
+	companions, err = p.DbGetCompanions(john, mary)
+	assert.Nil(t, err)
+	assert.Equal(t, []Companion{
+		{GuestId: john, Name: "Ben", Arrived: true},
+		{GuestId: mary, Name: "Tom"},
+	}, companions)
+
+	// companions of other events are not read
+	other, err := p.DbAddEvent(Event{Name: "Wedding"})
+	assert.Nil(t, err)
+	companions, err = p.DbForEvent(other).DbGetCompanions()
+	assert.Nil(t, err)
+	assert.Empty(t, companions)
+
+	assert.Nil(t, p.DbDeleteGuest(john))
+	companions, err = p.DbGetCompanions()
+	assert.Nil(t, err)
+	assert.Equal(t, []Companion{{GuestId: mary, Name: "Tom"}}, companions)
+}
+
+func TestSQLiteConstraints(t *testing.T) {
+	p := newSQLiteModel(t)
+	_, err := p.DbAddTable(6)
+	assert.Nil(t, err)
+	_, err = p.DbAddTable(4)
+	assert.Nil(t, err)
+
+	tag, err := p.DbAddConstraint(Constraint{Kind: TABLETAG, Table: 2, Tag: "accessible"})
+	assert.Nil(t, err)
+	apart, err := p.DbAddConstraint(Constraint{Kind: APART, Name: "John", Other: "Mary"})
+	assert.Nil(t, err)
+	_, err = p.DbAddConstraint(Constraint{Kind: "nearby", Name: "John", Other: "Mary"})
+	assert.NotNil(t, err, "unknown kinds are refused")
+	_, err = p.DbAddConstraint(Constraint{Kind: TABLETAG, Table: 9, Tag: "stage"})
+	assert.NotNil(t, err, "tags of unknown tables are refused")
+
+	constraints, err := p.DbGetConstraints()
+	assert.Nil(t, err)
+	assert.Equal(t, []Constraint{
+		{Id: tag, Kind: TABLETAG, Table: 2, Tag: "accessible"},
+		{Id: apart, Kind: APART, Name: "John", Other: "Mary"},
+	}, constraints)
+
+	// constraints of other events are not read
+	other, err := p.DbAddEvent(Event{Name: "Wedding"})
+	assert.Nil(t, err)
+	constraints, err = p.DbForEvent(other).DbGetConstraints()
+	assert.Nil(t, err)
+	assert.Empty(t, constraints)
+	deleted, err := p.DbForEvent(other).DbDeleteConstraint(apart)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), deleted)
+
+	// the tags of a table are deleted with it
+	assert.Nil(t, p.DbDeleteTable(2))
+	deleted, err = p.DbDeleteConstraint(apart)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), deleted)
+	constraints, err = p.DbGetConstraints()
+	assert.Nil(t, err)
+	assert.Empty(t, constraints)
+}
+
+func TestSQLiteWaitlist(t *testing.T) {
+	p := newSQLiteModel(t)
+	_, err := p.DbAddTable(6)
+	assert.Nil(t, err)
+	_, err = p.DbAddTable(4)
+	assert.Nil(t, err)
+
+	ann, err := p.DbAddWaitlistEntry(WaitlistEntry{Name: "Ann", AccompanyingGuests: 2, Priority: 1, Table: 2})
+	assert.Nil(t, err)
+	bob, err := p.DbAddWaitlistEntry(WaitlistEntry{Name: "Bob"})
+	assert.Nil(t, err)
+	_, err = p.DbAddWaitlistEntry(WaitlistEntry{Name: "Cat", Table: 9})
+	assert.NotNil(t, err, "unknown tables are refused")
+
+	guest := addGuest(t, p, Guests{Table: 1, Name: "Bob"})
+	allotted, err := p.DbAllotWaitlistEntry(bob, guest)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), allotted)
+	allotted, err = p.DbAllotWaitlistEntry(bob, guest)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), allotted, "a party is allotted once")
+
+	entries, err := p.DbGetWaitlist()
+	assert.Nil(t, err)
+	assert.Equal(t, []WaitlistEntry{
+		{Id: ann, Name: "Ann", AccompanyingGuests: 2, Priority: 1, Table: 2},
+		{Id: bob, Name: "Bob", GuestId: guest},
+	}, entries)
+
+	// a party preferring a deleted table takes any table, a party leaves the
+	// waitlist with its guest
+	assert.Nil(t, p.DbDeleteTable(2))
+	assert.Nil(t, p.DbDeleteGuest(guest))
+	entries, err = p.DbGetWaitlist()
+	assert.Nil(t, err)
+	assert.Equal(t, []WaitlistEntry{{Id: ann, Name: "Ann", AccompanyingGuests: 2, Priority: 1}}, entries)
+
+	deleted, err := p.DbForEvent(DefaultEvent + 1).DbDeleteWaitlistEntry(ann)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), deleted)
+	deleted, err = p.DbDeleteWaitlistEntry(ann)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func TestSQLiteTableMoves(t *testing.T) {
+	p := newSQLiteModel(t)
+	_, err := p.DbAddTable(2)
+	assert.Nil(t, err)
+	_, err = p.DbAddTable(6)
+	assert.Nil(t, err)
+	john := addGuest(t, p, Guests{Table: 1, AccompanyingGuests: 1, Name: "john"})
+
+	before := time.Now().Add(-time.Second)
+	assert.Nil(t, p.DbAddTableMove(TableMove{GuestId: john, Name: "john", From: 1, To: 2, AccompanyingGuests: 4}))
+	assert.NotNil(t, p.DbAddTableMove(TableMove{GuestId: 99, Name: "eve", From: 1, To: 2}), "moves of unknown guests are refused")
+	moves, err := p.DbGetTableMoves()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(moves))
+	assert.True(t, moves[0].TimeMoved.After(before))
+	moves[0].TimeMoved = time.Time{}
+	assert.Equal(t, TableMove{GuestId: john, Name: "john", From: 1, To: 2, AccompanyingGuests: 4}, moves[0])
+
+	other, err := p.DbAddEvent(Event{Name: "Wedding"})
+	assert.Nil(t, err)
+	moves, err = p.DbForEvent(other).DbGetTableMoves()
+	assert.Nil(t, err)
+	assert.Empty(t, moves)
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// SearchCandidates bounds the guests read from the search index for each
+// way a name can match, by prefix and by shared trigrams
+const SearchCandidates = 200
+
+// strips the accents of a decomposed name
+var accentRemover = runes.Remove(runes.In(unicode.Mn))
+
+// SearchKey folds name for search: lower case, without accents and with
+// single spaces between words
+func SearchKey(name string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, accentRemover, norm.NFC), name)
+	if err != nil {
+		folded = name
+	}
+	return strings.Join(strings.Fields(strings.ToLower(folded)), " ")
+}
+
+// Trigrams returns the distinct trigrams of the words of a search key. Words
+// are padded with two spaces in front and one behind, so a prefix of a word
+// shares its first trigrams with the word.
+func Trigrams(key string) []string {
+	var trigrams []string
+	seen := map[string]bool{}
+	for _, word := range strings.Fields(key) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			trigram := string(padded[i : i+3])
+			if !seen[trigram] {
+				seen[trigram] = true
+				trigrams = append(trigrams, trigram)
+			}
+		}
+	}
+	return trigrams
+}
+
+// SearchScore rates how well the search key of a name matches the search
+// key query, from 0 for no match to 1 for a name starting with the query.
+// Names with a word starting with the query follow, then the names closest
+// by trigram similarity or edit distance of the whole name or a word.
+func SearchScore(query string, key string) float64 {
+	if query == "" {
+		return 0
+	}
+	if strings.HasPrefix(key, query) {
+		return 1
+	}
+	words := strings.Fields(key)
+	for _, word := range words {
+		if strings.HasPrefix(word, query) {
+			return 0.9
+		}
+	}
+	score := trigramSimilarity(query, key)
+	for _, word := range append(words, key) {
+		if s := editSimilarity(query, word); s > score {
+			score = s
+		}
+	}
+	// fuzzy matches rank below every prefix match
+	return score * 0.8
+}
+
+// share of the trigrams of a and b they have in common
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := Trigrams(a), Trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	inB := map[string]bool{}
+	for _, t := range tb {
+		inB[t] = true
+	}
+	shared := 0
+	for _, t := range ta {
+		if inB[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// 1 less the edit distance of a and b relative to the longer of the two
+func editSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// Damerau-Levenshtein distance restricted to adjacent transpositions, so
+// that swapped letters count as a single edit
+func editDistance(a, b []rune) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}